@@ -1,20 +1,15 @@
 package alice
 
 import (
-	"crypto/rand"
 	"errors"
-	"fmt"
 	"math/big"
-	"time"
 
-	"github.com/HyperspaceApp/ed25519"
-	"github.com/google/uuid"
 	"gitlab.com/NebulousLabs/Sia/types"
 
 	"github.com/javgh/roadie/blockchain/ethereum"
 	"github.com/javgh/roadie/blockchain/sia"
+	"github.com/javgh/roadie/contract/retryinghub"
 	"github.com/javgh/roadie/frontend"
-	"github.com/javgh/roadie/keypair"
 	"github.com/javgh/roadie/rpc"
 	"github.com/javgh/roadie/trader"
 )
@@ -28,8 +23,11 @@ const (
 var (
 	ErrNoServers         = errors.New("no server available")
 	ErrNoOffers          = errors.New("no offers received")
+	ErrBudgetExceeded    = errors.New("binding offer exceeds ether budget")
 	ErrTimelockTooShort  = errors.New("proposed timelock is too short")
 	ErrInvalidAdaptorSig = errors.New("unable to verify adaptor signature")
+	ErrWrongState        = errors.New("atomic swap is in a state where this action is not permitted")
+	ErrOfferDeclined     = errors.New("binding offer was declined")
 	ErrInvalidClaimSig   = errors.New(
 		"unable to use adaptor secret to build a valid claim transaction - we were tricked somehow")
 
@@ -38,308 +36,172 @@ var (
 )
 
 type (
-	confirmationDisplay struct {
+	// Order describes what Alice would like to buy: either a fixed amount of
+	// siacoins or as many siacoins as a fixed amount of ether will buy.
+	// Claimed siacoins go to ClaimAddress or, if it is nil, to the local
+	// wallet.
+	Order struct {
+		Siacoin      types.Currency
+		EtherBudget  *big.Int
+		ClaimAddress *types.UnlockHash
+	}
+
+	// Result summarizes a completed swap. The Ethereum receipts are nil if
+	// the transactions could not be determined. For a swap that failed, it
+	// only covers what was paid so far: Ether is zero unless the deposit was
+	// made and ClaimTxID is not set.
+	Result struct {
+		Server         string
+		Siacoin        types.Currency
+		Ether          big.Int
+		AntiSpamFee    big.Int
+		AntiSpamID     big.Int
+		ClaimTxID      types.TransactionID
+		BurnReceipt    *retryinghub.Receipt
+		DepositReceipt *retryinghub.Receipt
+		Credit         *big.Int // nil if the anti-spam fee was burned
+	}
+
+	// confirmationTracker reports confirmations to the event sink, but only
+	// when their number actually changes.
+	confirmationTracker struct {
+		phase   Phase
 		current int64
 		total   int64
+		sink    EventSink
 	}
 )
 
-func (d *confirmationDisplay) show(current int64) {
-	if d.current == current {
+func (t *confirmationTracker) update(current int64) {
+	if t.current == current {
 		return
 	}
 
-	d.current = current
-	fmt.Printf("%d/%d", d.current, d.total)
-	if d.current < d.total {
-		fmt.Printf(".. ")
+	t.current = current
+	t.sink.HandleEvent(Event{Phase: t.phase, Confirmations: t.current, Required: t.total})
+}
+
+func (o Order) requestNonBindingOffer(roadieClient *rpc.Client) (
+	*rpc.Session, *types.Currency, *trader.Offer, error) {
+	if o.EtherBudget != nil {
+		return roadieClient.RequestNonBindingOfferForEther(*o.EtherBudget)
 	}
+
+	session, offer, err := roadieClient.RequestNonBindingOffer(o.Siacoin)
+	return session, &o.Siacoin, offer, err
 }
 
-func PerformSwap(siacoin types.Currency, serverDetails []ethereum.ServerDetails,
+func (o Order) exceedsBudget(offer trader.Offer) bool {
+	return o.EtherBudget != nil && offer.Ether.Cmp(o.EtherBudget) == 1
+}
+
+// PerformSwap collects offers from all servers, lets the frontend choose one of
+// them and runs through an atomic swap with that server. If no offer is chosen
+// by the frontend, it returns without a result and without an error. If the
+// swap fails or the binding offer is declined after the anti-spam fee was paid,
+// the error is returned together with a result describing what was spent.
+func PerformSwap(order Order, serverDetails []ethereum.ServerDetails,
 	maxAntiSpamFee *big.Int, fundingConfirmations int64,
-	frontend frontend.Frontend, ethChain ethereum.Blockchain, siaChain sia.Blockchain) error {
+	selectedFrontend frontend.Frontend, ethChain ethereum.Blockchain, siaChain sia.Blockchain,
+	sink EventSink) (*Result, error) {
 	if len(serverDetails) == 0 {
-		return ErrNoServers
+		return nil, ErrNoServers
 	}
 
-	var id *uuid.UUID
-	var nonBindingOffer *trader.Offer
+	var quotes []frontend.Quote
+	var sessions []rpc.Session
+	var quotedServers []ethereum.ServerDetails
 	var roadieClient *rpc.Client
-	var bestIdx int
 	var err error
 	for i := range serverDetails {
-		fmt.Printf("Requesting offer from %s: ", serverDetails[i].Target)
+		server := serverDetails[i].Target
+		sink.HandleEvent(Event{Phase: PhaseRequestingOffer, Server: server})
 		roadieClient, err = rpc.Dial(serverDetails[i].Target, serverDetails[i].Cert)
 		if err != nil {
-			fmt.Printf("error encountered\n")
+			sink.HandleEvent(Event{Phase: PhaseOfferError, Server: server, Err: err})
 			continue
 		}
 
-		currentID, currentNonBindingOffer, err := roadieClient.RequestNonBindingOffer(siacoin)
+		currentSession, currentSiacoin, currentNonBindingOffer, err := order.requestNonBindingOffer(roadieClient)
 		if err != nil {
-			fmt.Printf("error encountered\n")
+			sink.HandleEvent(Event{Phase: PhaseOfferError, Server: server, Err: err})
 			continue
 		}
 
 		err = roadieClient.Close()
 		if err != nil {
-			fmt.Printf("error encountered\n")
+			sink.HandleEvent(Event{Phase: PhaseOfferError, Server: server, Err: err})
 			continue
 		}
 
-		if !currentNonBindingOffer.Available {
-			fmt.Printf("no offer available\n")
-			fmt.Printf("-----BEGIN MESSAGE-----\n")
-			fmt.Println(currentNonBindingOffer.Msg)
-			fmt.Printf("-----END MESSAGE-----\n\n")
-			continue
+		event := Event{
+			Phase:   PhaseOfferReceived,
+			Server:  server,
+			Siacoin: *currentSiacoin,
+			Offer:   currentNonBindingOffer,
 		}
-
-		if currentNonBindingOffer.AntiSpamFee.Cmp(maxAntiSpamFee) == 1 {
-			fmt.Printf("excessive anti spam fee\n")
-			continue
+		if currentNonBindingOffer.Available && currentNonBindingOffer.AntiSpamFee.Cmp(maxAntiSpamFee) == 1 {
+			event.Rejection = RejectionExcessiveAntiSpamFee
+		} else if currentNonBindingOffer.Available && order.exceedsBudget(*currentNonBindingOffer) {
+			event.Rejection = RejectionExceedsBudget
 		}
+		sink.HandleEvent(event)
 
-		if nonBindingOffer == nil {
-			bestIdx = i
-			id = currentID
-			nonBindingOffer = currentNonBindingOffer
-			fmt.Printf("offer received\n")
+		if !currentNonBindingOffer.Available || event.Rejection != "" {
 			continue
 		}
 
-		bestTotalAmount := new(big.Int).Add(&nonBindingOffer.Ether, &nonBindingOffer.AntiSpamFee)
-		totalAmount := new(big.Int).Add(&currentNonBindingOffer.Ether, &currentNonBindingOffer.AntiSpamFee)
-		if totalAmount.Cmp(bestTotalAmount) == -1 {
-			bestIdx = i
-			id = currentID
-			nonBindingOffer = currentNonBindingOffer
-		}
-
-		fmt.Printf("offer received\n")
-	}
-	fmt.Printf("\n")
-
-	if nonBindingOffer == nil {
-		return ErrNoOffers
-	}
-
-	roadieClient, err = rpc.Dial(serverDetails[bestIdx].Target, serverDetails[bestIdx].Cert)
-	if err != nil {
-		return err
-	}
-
-	approved, err := frontend.ApproveOffer(siacoin, *nonBindingOffer, false)
-	if err != nil {
-		return err
-	}
-	if !approved {
-		fmt.Printf("Offer not suitable.\n")
-		return nil
-	}
-
-	antiSpamID, err := rand.Int(rand.Reader, maxAntiSpamID)
-	if err != nil {
-		return err
-	}
-	fmt.Printf("Burning anti-spam fee (id %s) and waiting for Ethereum confirmations.\n", antiSpamID)
-
-	err = ethChain.BurnAntiSpamFee(*antiSpamID, nonBindingOffer.AntiSpamFee)
-	if err != nil {
-		return err
-	}
-
-	confDisplay := confirmationDisplay{current: -1, total: antiSpamConfirmations}
-	for {
-		confs, err := ethChain.CheckAntiSpamConfirmations(*antiSpamID, nonBindingOffer.AntiSpamFee)
-		if err != nil {
-			return err
-		}
-
-		confDisplay.show(confs)
-		if confs < antiSpamConfirmations {
-			time.Sleep(10 * time.Second)
-		} else {
-			fmt.Printf("\n")
-			break
-		}
-	}
-
-	bindingOffer, err := roadieClient.RequestBindingOffer(*id, *antiSpamID)
-	if err != nil {
-		return err
-	}
-
-	if !frontend.CheckSimilarity(*nonBindingOffer, *bindingOffer) {
-		approved, err = frontend.ApproveOffer(siacoin, *bindingOffer, true)
-		if err != nil {
-			return err
-		}
-		if !approved {
-			fmt.Printf("Offer not suitable.\n")
-			return nil
-		}
-	}
-
-	aliceKeypair, err := keypair.Generate()
-	if err != nil {
-		return err
-	}
-
-	refundDetails, err := roadieClient.AcceptOffer(*id, aliceKeypair.PubKey)
-	if err != nil {
-		return err
-	}
-
-	height, err := siaChain.Height()
-	if err != nil {
-		return err
-	}
-
-	minTimelock := *height + minTimelockOffset
-	if refundDetails.Timelock < minTimelock {
-		return ErrTimelockTooShort
-	}
-
-	jointPubKey, jointPrimeKeys, err := ed25519.GenerateJointKey(
-		[]ed25519.PublicKey{aliceKeypair.PubKey, refundDetails.BobPubKey})
-	if err != nil {
-		return err
-	}
-	jointUnlockConditions := sia.PubKeyUnlockConditions(jointPubKey)
-
-	refundTx := sia.BuildRefundTransaction(
-		refundDetails.FundingOutputID, jointUnlockConditions, refundDetails.BobRefundUnlockHash,
-		siacoin, defaultMinerFee, refundDetails.Timelock)
-
-	refundSigHash := sia.WholeSigHash(refundTx, *height)
-	aliceRefundNoncePoint := ed25519.GenerateNoncePoint(aliceKeypair.PrivKey, refundSigHash)
-	refundSigAlice, err := keypair.JointSignAlice(aliceKeypair, refundDetails.BobPubKey,
-		[]ed25519.CurvePoint{aliceRefundNoncePoint, refundDetails.BobRefundNoncePoint}, refundSigHash)
-	if err != nil {
-		return err
-	}
-
-	fundingTxID, err := roadieClient.EnableFunding(*id, aliceRefundNoncePoint, refundSigAlice)
-	if err != nil {
-		return err
-	}
-
-	fmt.Printf("\nWaiting for Sia confirmations for funding transaction %s .\n", fundingTxID)
-	confDisplay = confirmationDisplay{current: -1, total: fundingConfirmations}
-	for {
-		confs, err := siaChain.ConfsOfRecentUnlockHash(jointUnlockConditions.UnlockHash(), siacoin.Add(defaultMinerFee))
-		if err != nil {
-			return err
-		}
-
-		confDisplay.show(confs)
-		if confs < fundingConfirmations {
-			time.Sleep(10 * time.Second)
-		} else {
-			fmt.Printf("\n\n")
-			break
-		}
+		quotes = append(quotes, frontend.Quote{
+			Server:  server,
+			Siacoin: *currentSiacoin,
+			Offer:   *currentNonBindingOffer,
+		})
+		sessions = append(sessions, *currentSession)
+		quotedServers = append(quotedServers, serverDetails[i])
 	}
+	sink.HandleEvent(Event{Phase: PhaseOffersCollected})
 
-	aliceClaimUnlockHash, err := siaChain.NextWalletUnlockHash()
-	if err != nil {
-		return err
+	if len(quotes) == 0 {
+		return nil, ErrNoOffers
 	}
-	claimTx := sia.BuildClaimTransaction(
-		refundDetails.FundingOutputID, jointUnlockConditions, *aliceClaimUnlockHash,
-		siacoin, defaultMinerFee)
-	claimSigHash := sia.WholeSigHash(claimTx, *height)
-	aliceClaimNoncePoint := ed25519.GenerateNoncePoint(aliceKeypair.PrivKey, claimSigHash)
 
-	adaptorDetails, err := roadieClient.RequestAdaptorDetails(*id, *aliceClaimUnlockHash, aliceClaimNoncePoint)
+	choice, err := selectedFrontend.ChooseOffer(quotes)
 	if err != nil {
-		return err
+		return nil, err
 	}
-
-	adaptorSigOK := keypair.VerifyBobsAdaptorSignature(
-		jointPrimeKeys, jointPubKey, []ed25519.CurvePoint{aliceClaimNoncePoint, adaptorDetails.BobClaimNoncePoint},
-		adaptorDetails.AdaptorPubKey, claimSigHash, adaptorDetails.AdaptorSigBob)
-	if !adaptorSigOK {
-		return ErrInvalidAdaptorSig
+	if choice == frontend.NoChoice {
+		sink.HandleEvent(Event{Phase: PhaseOfferDeclined})
+		return nil, nil
 	}
 
-	fmt.Printf("Depositing payment and waiting for Ethereum confirmations.\n")
-	err = ethChain.DepositEther(adaptorDetails.DepositRecipient, adaptorDetails.AdaptorPubKey, bindingOffer.Ether, *antiSpamID)
+	roadieClient, err = rpc.Dial(quotedServers[choice].Target, quotedServers[choice].Cert)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	defer roadieClient.Close()
 
-	confDisplay = confirmationDisplay{current: -1, total: depositConfirmations}
-	for {
-		confs, err := ethChain.CheckDepositConfirmations(
-			adaptorDetails.DepositRecipient, adaptorDetails.AdaptorPubKey, bindingOffer.Ether, *antiSpamID)
-		if err != nil {
-			return err
-		}
-
-		confDisplay.show(confs)
-		if confs < depositConfirmations {
-			time.Sleep(10 * time.Second)
-		} else {
-			fmt.Printf("\n\n")
-			break
-		}
-	}
-
-	fmt.Printf("Should anything go wrong after this point, you can reclaim your deposit in about\n"+
-		"2 hours by running 'roadie reclaim %s'.\n\n", antiSpamID)
-
-	fmt.Printf("Announcing deposit and waiting for other party to claim it and reveal adaptor secret.\n")
-	err = roadieClient.AnnounceDeposit(*id)
+	atomicSwap, err := NewAtomicSwap(quotedServers[choice], sessions[choice], quotes[choice].Siacoin, order,
+		quotes[choice].Offer, fundingConfirmations, roadieClient, ethChain, siaChain, sink)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	var ok bool
-	var adaptorPrivKey *ed25519.Adaptor
-	for {
-		ok, adaptorPrivKey, err = ethChain.LookupAdaptorPrivKey(adaptorDetails.AdaptorPubKey)
-		if err != nil {
-			return err
-		}
-
-		if !ok {
-			time.Sleep(10 * time.Second)
-		} else {
-			break
-		}
-	}
-
-	fmt.Printf("Using adaptor secret to build a valid claim transaction and to broadcast it.\n")
-
-	noncePoints := []ed25519.CurvePoint{aliceClaimNoncePoint, adaptorDetails.BobClaimNoncePoint}
-	adaptorSigAlice, err := keypair.JointSignWithAdaptorAlice(
-		aliceKeypair, refundDetails.BobPubKey, noncePoints, adaptorDetails.AdaptorPubKey, claimSigHash)
+	result, err := atomicSwap.Run(selectedFrontend, defaultPollInterval, nil)
 	if err != nil {
-		return err
+		return atomicSwap.spent(), err
 	}
 
-	claimSig := ed25519.AddSignature(adaptorSigAlice, adaptorDetails.AdaptorSigBob)
-	claimSig = ed25519.AddSignature(claimSig, append(adaptorDetails.AdaptorPubKey, *adaptorPrivKey...))
+	return result, nil
+}
 
-	claimSigOK := ed25519.Verify(jointPubKey, claimSigHash, claimSig)
-	if !claimSigOK {
-		return ErrInvalidClaimSig
-	}
+func ReclaimDeposit(ethChain ethereum.Blockchain, antiSpamID big.Int, sink EventSink) error {
+	sink.HandleEvent(Event{Phase: PhaseReclaiming, AntiSpamID: &antiSpamID})
 
-	claimTx = sia.AddSignature(claimTx, claimSig)
-	err = siaChain.BroadcastTransaction(claimTx)
+	err := ethChain.ReclaimDeposit(antiSpamID)
 	if err != nil {
 		return err
 	}
 
-	fmt.Printf("Swap completed successfully with Sia claim transaction %s .\n", claimTx.ID())
-	return roadieClient.Close()
-}
-
-func ReclaimDeposit(ethChain ethereum.Blockchain, antiSpamID big.Int) error {
-	fmt.Printf("Attempting to reclaim deposit with id %s.\n", &antiSpamID)
-	return ethChain.ReclaimDeposit(antiSpamID)
+	sink.HandleEvent(Event{Phase: PhaseReclaimed, AntiSpamID: &antiSpamID})
+	return nil
 }
@@ -0,0 +1,351 @@
+package alice
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"gitlab.com/NebulousLabs/Sia/types"
+
+	"github.com/javgh/roadie/blockchain/ethereum"
+	"github.com/javgh/roadie/output"
+	"github.com/javgh/roadie/trader"
+)
+
+type (
+	Phase int
+
+	// Event describes the progress of a swap. Only the fields relevant to the
+	// phase are set.
+	Event struct {
+		Phase         Phase
+		Server        string
+		Siacoin       types.Currency
+		Offer         *trader.Offer
+		Rejection     string
+		Binding       bool
+		Confirmations int64
+		Required      int64
+		AntiSpamID    *big.Int
+		Height        types.BlockHeight
+		Timelock      types.BlockHeight
+		Recipient     common.Address
+		TxID          string
+		Credit        *big.Int
+		Result        *Result
+		Err           error
+	}
+
+	// EventSink receives events while a swap is in progress. HandleEvent is
+	// called synchronously, so it should not block for long.
+	EventSink interface {
+		HandleEvent(event Event)
+	}
+
+	// EventSinkFunc adapts an ordinary function to the EventSink interface.
+	EventSinkFunc func(event Event)
+
+	// ChannelSink delivers events to a channel. The channel needs to be
+	// drained, otherwise the swap will block.
+	ChannelSink chan<- Event
+
+	// Sinks passes events on to several sinks.
+	Sinks []EventSink
+
+	// ConsoleSink shows the progress of a swap to the user of the command line
+	// tool, either as text or as JSON events.
+	ConsoleSink struct {
+		out *output.Output
+	}
+)
+
+const (
+	PhaseRequestingOffer Phase = iota
+	PhaseOfferError
+	PhaseOfferReceived
+	PhaseOffersCollected
+	PhaseOfferDeclined
+	PhaseBurningAntiSpamFee
+	PhaseAntiSpamConfirmations
+	PhaseBindingOfferReceived
+	PhaseOfferAccepted
+	PhaseRefundSigned
+	PhaseFundingBroadcast
+	PhaseFundingConfirmations
+	PhaseFundingDetected
+	PhaseDepositing
+	PhaseDepositMade
+	PhaseDepositConfirmations
+	PhaseDepositConfirmed
+	PhaseAnnouncingDeposit
+	PhaseAdaptorRevealed
+	PhaseClaimBroadcast
+	PhaseCompleted
+	PhaseReclaiming
+	PhaseReclaimed
+	PhaseCreditWithdrawn
+
+	RejectionExcessiveAntiSpamFee = "excessive_anti_spam_fee"
+	RejectionExceedsBudget        = "exceeds_budget"
+)
+
+func (p Phase) String() string {
+	switch p {
+	case PhaseRequestingOffer:
+		return "requesting_offer"
+	case PhaseOfferError:
+		return "offer_error"
+	case PhaseOfferReceived:
+		return "offer_received"
+	case PhaseOffersCollected:
+		return "offers_collected"
+	case PhaseOfferDeclined:
+		return "offer_declined"
+	case PhaseBurningAntiSpamFee:
+		return "burning_anti_spam_fee"
+	case PhaseAntiSpamConfirmations:
+		return "anti_spam_confirmations"
+	case PhaseBindingOfferReceived:
+		return "binding_offer_received"
+	case PhaseOfferAccepted:
+		return "offer_accepted"
+	case PhaseRefundSigned:
+		return "refund_signed"
+	case PhaseFundingBroadcast:
+		return "funding_broadcast"
+	case PhaseFundingConfirmations:
+		return "funding_confirmations"
+	case PhaseFundingDetected:
+		return "funding_detected"
+	case PhaseDepositing:
+		return "depositing"
+	case PhaseDepositMade:
+		return "deposit_made"
+	case PhaseDepositConfirmations:
+		return "deposit_confirmations"
+	case PhaseDepositConfirmed:
+		return "deposit_confirmed"
+	case PhaseAnnouncingDeposit:
+		return "announcing_deposit"
+	case PhaseAdaptorRevealed:
+		return "adaptor_revealed"
+	case PhaseClaimBroadcast:
+		return "claim_broadcast"
+	case PhaseCompleted:
+		return "completed"
+	case PhaseReclaiming:
+		return "reclaiming"
+	case PhaseReclaimed:
+		return "reclaimed"
+	case PhaseCreditWithdrawn:
+		return "credit_withdrawn"
+	default:
+		return fmt.Sprintf("phase_%d", p)
+	}
+}
+
+// EventName returns the name under which events of this phase are reported in
+// JSON output and to webhooks. These names predate the phases, so several
+// phases share a name: progress towards the required confirmations, for
+// example, is always reported as "confirmations", with the phase in a field.
+func (p Phase) EventName() string {
+	switch p {
+	case PhaseBurningAntiSpamFee:
+		return "anti_spam_fee_burn"
+	case PhaseAntiSpamConfirmations, PhaseFundingConfirmations, PhaseDepositConfirmations:
+		return "confirmations"
+	case PhaseBindingOfferReceived:
+		return "offer_received"
+	case PhaseFundingBroadcast:
+		return "funding_transaction"
+	case PhaseDepositing:
+		return "deposit"
+	case PhaseDepositConfirmed:
+		return "reclaim_available"
+	case PhaseClaimBroadcast:
+		return "claim_transaction"
+	case PhaseCompleted:
+		return "swap_completed"
+	case PhaseReclaiming:
+		return "reclaim"
+	case PhaseReclaimed:
+		return "reclaim_completed"
+	default:
+		return p.String()
+	}
+}
+
+func (f EventSinkFunc) HandleEvent(event Event) {
+	f(event)
+}
+
+func (c ChannelSink) HandleEvent(event Event) {
+	c <- event
+}
+
+func (s Sinks) HandleEvent(event Event) {
+	for _, sink := range s {
+		sink.HandleEvent(event)
+	}
+}
+
+func NewConsoleSink(out *output.Output) *ConsoleSink {
+	sink := ConsoleSink{out: out}
+	return &sink
+}
+
+func (s *ConsoleSink) HandleEvent(event Event) {
+	s.printText(event)
+	s.out.Event(event.Phase.EventName(), event.Fields())
+}
+
+func (s *ConsoleSink) printText(event Event) {
+	switch event.Phase {
+	case PhaseRequestingOffer:
+		s.out.Printf("Requesting offer from %s: ", event.Server)
+	case PhaseOfferError:
+		s.out.Printf("error encountered\n")
+	case PhaseOfferReceived:
+		if !event.Offer.Available {
+			s.out.Printf("no offer available\n")
+			s.out.Printf("-----BEGIN MESSAGE-----\n")
+			s.out.Println(event.Offer.Msg)
+			s.out.Printf("-----END MESSAGE-----\n\n")
+		} else if event.Rejection == RejectionExcessiveAntiSpamFee {
+			s.out.Printf("excessive anti spam fee\n")
+		} else if event.Rejection == RejectionExceedsBudget {
+			s.out.Printf("offer exceeds budget\n")
+		} else {
+			s.out.Printf("offer received\n")
+		}
+	case PhaseOffersCollected:
+		s.out.Printf("\n")
+	case PhaseOfferDeclined:
+		s.out.Printf("Offer not suitable.\n")
+	case PhaseBurningAntiSpamFee:
+		if event.Offer.Escrow != nil {
+			s.out.Printf("Escrowing anti-spam fee (id %s) and waiting for Ethereum confirmations.\n", event.AntiSpamID)
+			break
+		}
+		s.out.Printf("Burning anti-spam fee (id %s) and waiting for Ethereum confirmations.\n", event.AntiSpamID)
+	case PhaseAntiSpamConfirmations:
+		s.printConfirmations(event, false)
+	case PhaseFundingBroadcast:
+		s.out.Printf("\nWaiting for Sia confirmations for funding transaction %s .\n", event.TxID)
+	case PhaseFundingConfirmations:
+		s.printConfirmations(event, true)
+	case PhaseDepositing:
+		s.out.Printf("Depositing payment and waiting for Ethereum confirmations.\n")
+	case PhaseDepositConfirmations:
+		s.printConfirmations(event, true)
+	case PhaseDepositConfirmed:
+		s.out.Printf("Should anything go wrong after this point, you can reclaim your deposit in about\n"+
+			"2 hours by running 'roadie reclaim %s'.\n\n", event.AntiSpamID)
+	case PhaseAnnouncingDeposit:
+		s.out.Printf("Announcing deposit and waiting for other party to claim it and reveal adaptor secret.\n")
+	case PhaseAdaptorRevealed:
+		s.out.Printf("Using adaptor secret to build a valid claim transaction and to broadcast it.\n")
+	case PhaseClaimBroadcast:
+		s.out.Printf("Swap completed successfully with Sia claim transaction %s .\n", event.TxID)
+	case PhaseReclaiming:
+		s.out.Printf("Attempting to reclaim deposit with id %s.\n", event.AntiSpamID)
+	case PhaseCreditWithdrawn:
+		s.out.Printf("Withdrew %s of escrowed anti-spam fees.\n", ethereum.FormatEther(event.Credit))
+	}
+}
+
+// printConfirmations shows the progress towards the required confirmations
+// and ends the line once they are reached, followed by a blank line if
+// requested.
+func (s *ConsoleSink) printConfirmations(event Event, blankLine bool) {
+	s.out.Printf("%d/%d", event.Confirmations, event.Required)
+	if event.Confirmations < event.Required {
+		s.out.Printf(".. ")
+		return
+	}
+
+	s.out.Println()
+	if blankLine {
+		s.out.Println()
+	}
+}
+
+func (e Event) Fields() output.Fields {
+	fields := output.Fields{}
+	if e.Server != "" {
+		fields["server"] = e.Server
+	}
+	if e.Offer != nil {
+		fields["siacoin"] = e.Siacoin.String()
+		fields["available"] = e.Offer.Available
+		fields["ether"] = e.Offer.Ether.String()
+		fields["anti_spam_fee"] = e.Offer.AntiSpamFee.String()
+		fields["msg"] = e.Offer.Msg
+		if e.Offer.Escrow != nil {
+			fields["escrow"] = e.Offer.Escrow.Hex()
+		}
+	}
+	switch e.Phase {
+	case PhaseOfferReceived:
+		fields["binding"] = false
+	case PhaseBindingOfferReceived:
+		fields["binding"] = true
+	case PhaseOfferDeclined:
+		fields["binding"] = e.Binding
+	case PhaseAntiSpamConfirmations:
+		fields["phase"] = "anti_spam_fee"
+	case PhaseFundingConfirmations:
+		fields["phase"] = "funding"
+	case PhaseDepositConfirmations:
+		fields["phase"] = "deposit"
+	}
+	if e.Rejection != "" {
+		fields["rejected"] = e.Rejection
+	}
+	if e.Required != 0 {
+		fields["current"] = e.Confirmations
+		fields["required"] = e.Required
+	}
+	if e.AntiSpamID != nil {
+		fields["anti_spam_id"] = e.AntiSpamID.String()
+	}
+	if e.Timelock != 0 {
+		fields["height"] = e.Height
+		fields["timelock"] = e.Timelock
+	}
+	if e.Phase == PhaseDepositConfirmed {
+		fields["command"] = fmt.Sprintf("roadie reclaim %s", e.AntiSpamID)
+	}
+	if e.Recipient != (common.Address{}) {
+		fields["recipient"] = e.Recipient.Hex()
+	}
+	if e.TxID != "" {
+		fields["txid"] = e.TxID
+	}
+	if e.Credit != nil {
+		fields["credit"] = e.Credit.String()
+	}
+	if e.Result != nil {
+		fields["server"] = e.Result.Server
+		fields["siacoin"] = e.Result.Siacoin.String()
+		fields["ether"] = e.Result.Ether.String()
+		fields["anti_spam_fee"] = e.Result.AntiSpamFee.String()
+		fields["anti_spam_id"] = e.Result.AntiSpamID.String()
+		fields["claim_txid"] = e.Result.ClaimTxID.String()
+		if e.Result.BurnReceipt != nil {
+			fields["burn_tx_hash"] = e.Result.BurnReceipt.TxHash.Hex()
+			fields["burn_fee"] = e.Result.BurnReceipt.Fee.String()
+		}
+		if e.Result.DepositReceipt != nil {
+			fields["deposit_tx_hash"] = e.Result.DepositReceipt.TxHash.Hex()
+			fields["deposit_fee"] = e.Result.DepositReceipt.Fee.String()
+		}
+		if e.Result.Credit != nil {
+			fields["credit"] = e.Result.Credit.String()
+		}
+	}
+	if e.Err != nil {
+		fields["message"] = e.Err.Error()
+	}
+	return fields
+}
@@ -0,0 +1,69 @@
+package alice
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/javgh/roadie/output"
+)
+
+func TestConfirmationTracker(t *testing.T) {
+	events := make(chan Event, 10)
+	tracker := confirmationTracker{phase: PhaseDepositConfirmations, current: -1, total: 2, sink: ChannelSink(events)}
+
+	tracker.update(0)
+	tracker.update(0)
+	tracker.update(1)
+	tracker.update(2)
+	close(events)
+
+	var confirmations []int64
+	for event := range events {
+		assert.Equal(t, PhaseDepositConfirmations, event.Phase)
+		assert.Equal(t, int64(2), event.Required)
+		confirmations = append(confirmations, event.Confirmations)
+	}
+	assert.Equal(t, []int64{0, 1, 2}, confirmations, "should only report changes")
+}
+
+func TestConsoleSink(t *testing.T) {
+	var text bytes.Buffer
+	var jsonLines bytes.Buffer
+	var phases []Phase
+	sink := Sinks{
+		NewConsoleSink(output.New(output.FormatText, &text)),
+		NewConsoleSink(output.New(output.FormatJSON, &jsonLines)),
+		EventSinkFunc(func(event Event) { phases = append(phases, event.Phase) }),
+	}
+
+	sink.HandleEvent(Event{Phase: PhaseAntiSpamConfirmations, Confirmations: 9, Required: 10})
+	sink.HandleEvent(Event{Phase: PhaseAntiSpamConfirmations, Confirmations: 10, Required: 10})
+	sink.HandleEvent(Event{Phase: PhaseReclaiming, AntiSpamID: big.NewInt(42)})
+
+	assert.Equal(t, "9/10.. 10/10\nAttempting to reclaim deposit with id 42.\n", text.String())
+	assert.Equal(t, []Phase{PhaseAntiSpamConfirmations, PhaseAntiSpamConfirmations, PhaseReclaiming}, phases)
+
+	lines := strings.Split(strings.TrimSpace(jsonLines.String()), "\n")
+	assert.Equal(t, 3, len(lines))
+
+	var event map[string]interface{}
+	err := json.Unmarshal([]byte(lines[0]), &event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "confirmations", event["event"])
+	assert.Equal(t, "anti_spam_fee", event["phase"])
+
+	event = nil
+	err = json.Unmarshal([]byte(lines[2]), &event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "reclaim", event["event"])
+	assert.Equal(t, "42", event["anti_spam_id"])
+}
@@ -0,0 +1,653 @@
+package alice
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"math/big"
+	"time"
+
+	"github.com/HyperspaceApp/ed25519"
+	"github.com/google/uuid"
+	"gitlab.com/NebulousLabs/Sia/types"
+
+	"github.com/javgh/roadie/blockchain/ethereum"
+	"github.com/javgh/roadie/blockchain/sia"
+	"github.com/javgh/roadie/bob"
+	"github.com/javgh/roadie/contract/retryinghub"
+	"github.com/javgh/roadie/frontend"
+	"github.com/javgh/roadie/keypair"
+	"github.com/javgh/roadie/rpc"
+	"github.com/javgh/roadie/trader"
+)
+
+type (
+	state int
+
+	// Server is the part of the Roadie RPC interface that Alice needs once she
+	// has picked an offer. It is implemented by rpc.Client.
+	Server interface {
+		RequestBindingOffer(session rpc.Session, antiSpamID big.Int) (*trader.Offer, error)
+		AcceptOffer(session rpc.Session, alicePubKey ed25519.PublicKey) (*bob.RefundDetails, error)
+		EnableFunding(session rpc.Session,
+			aliceRefundNoncePoint ed25519.CurvePoint, refundSigAlice []byte) (*types.TransactionID, error)
+		RequestAdaptorDetails(session rpc.Session,
+			aliceClaimUnlockHash types.UnlockHash, aliceClaimNoncePoint ed25519.CurvePoint) (*bob.AdaptorDetails, error)
+		AnnounceDeposit(session rpc.Session) error
+	}
+
+	// AtomicSwap is Alice's side of a swap, starting with an approved
+	// non-binding offer. All fields needed to continue the swap are exported,
+	// so that it can be serialized as JSON and resumed later on with
+	// LoadAtomicSwap. Note that the serialized form contains private keys.
+	AtomicSwap struct {
+		State                state
+		Server               ethereum.ServerDetails
+		ID                   uuid.UUID
+		SessionKeypair       keypair.Keypair
+		Siacoin              types.Currency
+		EtherBudget          *big.Int
+		FundingConfirmations int64
+		NonBindingOffer      trader.Offer
+		BindingOffer         trader.Offer
+		AntiSpamID           big.Int
+		BurnReceipt          *retryinghub.Receipt // nil if unknown
+		AliceKeypair         keypair.Keypair
+		RefundDetails        bob.RefundDetails
+		Height               types.BlockHeight
+		FundingTxID          types.TransactionID
+		ClaimUnlockHash      types.UnlockHash
+		ClaimNoncePoint      ed25519.CurvePoint
+		AdaptorDetails       bob.AdaptorDetails
+		AdaptorPrivKey       ed25519.Adaptor
+		DepositReceipt       *retryinghub.Receipt // nil if unknown
+		ClaimTxID            types.TransactionID
+		Credit               *big.Int // escrowed anti-spam fee that was credited back, nil if burned
+
+		server   Server
+		ethChain ethereum.Blockchain
+		siaChain sia.Blockchain
+		sink     EventSink
+		tracker  *confirmationTracker
+	}
+)
+
+const (
+	stateInitialized state = iota
+	stateBurnedAntiSpamFee
+	stateAntiSpamConfirmed
+	stateBindingOfferApproved
+	stateOfferAccepted
+	stateFundingEnabled
+	stateFunded
+	stateReceivedAdaptorDetails
+	stateDeposited
+	stateDepositConfirmed
+	stateDepositAnnounced
+	stateAdaptorRevealed
+	stateCompleted
+	stateDeclined
+	stateClaimed
+
+	defaultPollInterval = 10 * time.Second
+)
+
+func NewAtomicSwap(details ethereum.ServerDetails, session rpc.Session, siacoin types.Currency, order Order,
+	nonBindingOffer trader.Offer, fundingConfirmations int64,
+	server Server, ethChain ethereum.Blockchain, siaChain sia.Blockchain, sink EventSink) (*AtomicSwap, error) {
+	antiSpamID, err := rand.Int(rand.Reader, maxAntiSpamID)
+	if err != nil {
+		return nil, err
+	}
+
+	atomicSwap := AtomicSwap{
+		State:                stateInitialized,
+		Server:               details,
+		ID:                   session.ID,
+		SessionKeypair:       session.Keypair,
+		Siacoin:              siacoin,
+		EtherBudget:          order.EtherBudget,
+		FundingConfirmations: fundingConfirmations,
+		NonBindingOffer:      nonBindingOffer,
+		AntiSpamID:           *antiSpamID,
+		server:               server,
+		ethChain:             ethChain,
+		siaChain:             siaChain,
+		sink:                 sink,
+	}
+	if order.ClaimAddress != nil {
+		atomicSwap.ClaimUnlockHash = *order.ClaimAddress
+	}
+	return &atomicSwap, nil
+}
+
+// LoadAtomicSwap restores a swap that was serialized as JSON, so that it can
+// be continued with Run or Step.
+func LoadAtomicSwap(data []byte,
+	server Server, ethChain ethereum.Blockchain, siaChain sia.Blockchain, sink EventSink) (*AtomicSwap, error) {
+	var atomicSwap AtomicSwap
+	err := json.Unmarshal(data, &atomicSwap)
+	if err != nil {
+		return nil, err
+	}
+
+	atomicSwap.server = server
+	atomicSwap.ethChain = ethChain
+	atomicSwap.siaChain = siaChain
+	atomicSwap.sink = sink
+	return &atomicSwap, nil
+}
+
+// Run drives the swap to completion by calling Step, sleeping for
+// pollInterval while waiting for confirmations or for the other party. If
+// checkpoint is not nil, it is called initially and after every change of
+// state, which allows persisting the swap. A declined binding offer results
+// in ErrOfferDeclined together with the anti-spam fee that was spent.
+func (s *AtomicSwap) Run(frontend frontend.Frontend, pollInterval time.Duration,
+	checkpoint func(*AtomicSwap) error) (*Result, error) {
+	if checkpoint != nil {
+		err := checkpoint(s)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for !s.Done() {
+		previousState := s.State
+		waiting, err := s.Step(frontend)
+		if err != nil {
+			return nil, err
+		}
+
+		if checkpoint != nil && s.State != previousState {
+			err = checkpoint(s)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		if waiting {
+			time.Sleep(pollInterval)
+		}
+	}
+
+	if s.State == stateDeclined {
+		return s.spent(), ErrOfferDeclined
+	}
+
+	return s.Result(), nil
+}
+
+func (s *AtomicSwap) Done() bool {
+	return s.State == stateCompleted || s.State == stateDeclined
+}
+
+// Result returns the outcome of a completed swap or nil otherwise.
+func (s *AtomicSwap) Result() *Result {
+	if s.State != stateCompleted {
+		return nil
+	}
+
+	return &Result{
+		Server:         s.Server.Target,
+		Siacoin:        s.Siacoin,
+		Ether:          s.BindingOffer.Ether,
+		AntiSpamFee:    s.NonBindingOffer.AntiSpamFee,
+		AntiSpamID:     s.AntiSpamID,
+		ClaimTxID:      s.ClaimTxID,
+		BurnReceipt:    s.BurnReceipt,
+		DepositReceipt: s.DepositReceipt,
+		Credit:         s.Credit,
+	}
+}
+
+// spent returns what a swap that did not complete has paid so far: the
+// anti-spam fee once it was burned or escrowed and the Ether once it was
+// deposited. It returns nil if nothing was paid yet.
+func (s *AtomicSwap) spent() *Result {
+	if s.State == stateInitialized {
+		return nil
+	}
+
+	result := Result{
+		Server:      s.Server.Target,
+		Siacoin:     s.Siacoin,
+		AntiSpamFee: s.NonBindingOffer.AntiSpamFee,
+		AntiSpamID:  s.AntiSpamID,
+		BurnReceipt: s.BurnReceipt,
+	}
+	if s.State >= stateDeposited && s.State != stateDeclined {
+		result.Ether = s.BindingOffer.Ether
+		result.DepositReceipt = s.DepositReceipt
+	}
+	return &result
+}
+
+// Step performs the action appropriate for the current state. If it returns
+// waiting, nothing has changed and the caller should try again later.
+func (s *AtomicSwap) Step(frontend frontend.Frontend) (waiting bool, err error) {
+	switch s.State {
+	case stateInitialized:
+		return false, s.burnAntiSpamFee()
+	case stateBurnedAntiSpamFee:
+		return s.checkAntiSpamConfirmations()
+	case stateAntiSpamConfirmed:
+		return false, s.requestBindingOffer(frontend)
+	case stateBindingOfferApproved:
+		return false, s.acceptOffer()
+	case stateOfferAccepted:
+		return false, s.enableFunding()
+	case stateFundingEnabled:
+		return s.checkFundingConfirmations()
+	case stateFunded:
+		return false, s.requestAdaptorDetails()
+	case stateReceivedAdaptorDetails:
+		return false, s.deposit()
+	case stateDeposited:
+		return s.checkDepositConfirmations()
+	case stateDepositConfirmed:
+		return false, s.announceDeposit()
+	case stateDepositAnnounced:
+		return s.lookupAdaptorPrivKey()
+	case stateAdaptorRevealed:
+		return false, s.claim()
+	case stateClaimed:
+		return false, s.withdrawCredit()
+	default:
+		return false, ErrWrongState
+	}
+}
+
+func (s *AtomicSwap) burnAntiSpamFee() error {
+	s.sink.HandleEvent(Event{Phase: PhaseBurningAntiSpamFee, Siacoin: s.Siacoin,
+		Offer: &s.NonBindingOffer, AntiSpamID: &s.AntiSpamID})
+
+	var receipt *retryinghub.Receipt
+	var err error
+	if s.NonBindingOffer.Escrow != nil {
+		receipt, err = s.ethChain.EscrowAntiSpamFee(
+			s.AntiSpamID, s.NonBindingOffer.AntiSpamFee, *s.NonBindingOffer.Escrow)
+	} else {
+		receipt, err = s.ethChain.BurnAntiSpamFee(s.AntiSpamID, s.NonBindingOffer.AntiSpamFee)
+	}
+	if err != nil {
+		return err
+	}
+	s.BurnReceipt = receipt
+
+	s.State = stateBurnedAntiSpamFee
+	return nil
+}
+
+func (s *AtomicSwap) checkAntiSpamConfirmations() (bool, error) {
+	var confs int64
+	var err error
+	if s.NonBindingOffer.Escrow != nil {
+		confs, err = s.ethChain.CheckEscrowConfirmations(
+			s.AntiSpamID, s.NonBindingOffer.AntiSpamFee, *s.NonBindingOffer.Escrow)
+	} else {
+		confs, err = s.ethChain.CheckAntiSpamConfirmations(s.AntiSpamID, s.NonBindingOffer.AntiSpamFee)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	s.trackConfirmations(PhaseAntiSpamConfirmations, confs, antiSpamConfirmations)
+	if confs < antiSpamConfirmations {
+		return true, nil
+	}
+
+	s.State = stateAntiSpamConfirmed
+	return false, nil
+}
+
+func (s *AtomicSwap) requestBindingOffer(frontend frontend.Frontend) error {
+	bindingOffer, err := s.server.RequestBindingOffer(s.session(), s.AntiSpamID)
+	if err != nil {
+		return err
+	}
+
+	s.sink.HandleEvent(Event{Phase: PhaseBindingOfferReceived, Server: s.Server.Target,
+		Siacoin: s.Siacoin, Offer: bindingOffer})
+
+	order := Order{Siacoin: s.Siacoin, EtherBudget: s.EtherBudget}
+	if order.exceedsBudget(*bindingOffer) {
+		return ErrBudgetExceeded
+	}
+
+	if !frontend.CheckSimilarity(s.NonBindingOffer, *bindingOffer) {
+		approved, err := frontend.ApproveOffer(s.Siacoin, *bindingOffer, true)
+		if err != nil {
+			return err
+		}
+		if !approved {
+			s.sink.HandleEvent(Event{Phase: PhaseOfferDeclined, Binding: true})
+			s.State = stateDeclined
+			return nil
+		}
+	}
+
+	s.BindingOffer = *bindingOffer
+	s.State = stateBindingOfferApproved
+	return nil
+}
+
+func (s *AtomicSwap) acceptOffer() error {
+	aliceKeypair, err := keypair.Generate()
+	if err != nil {
+		return err
+	}
+
+	refundDetails, err := s.server.AcceptOffer(s.session(), aliceKeypair.PubKey)
+	if err != nil {
+		return err
+	}
+
+	height, err := s.siaChain.Height()
+	if err != nil {
+		return err
+	}
+
+	minTimelock := *height + minTimelockOffset
+	if refundDetails.Timelock < minTimelock {
+		return ErrTimelockTooShort
+	}
+
+	s.sink.HandleEvent(Event{Phase: PhaseOfferAccepted, Height: *height, Timelock: refundDetails.Timelock})
+
+	s.AliceKeypair = aliceKeypair
+	s.RefundDetails = *refundDetails
+	s.Height = *height
+	s.State = stateOfferAccepted
+	return nil
+}
+
+func (s *AtomicSwap) jointKey() (ed25519.PublicKey, []ed25519.PublicKey, types.UnlockConditions, error) {
+	jointPubKey, jointPrimeKeys, err := ed25519.GenerateJointKey(
+		[]ed25519.PublicKey{s.AliceKeypair.PubKey, s.RefundDetails.BobPubKey})
+	if err != nil {
+		return nil, nil, types.UnlockConditions{}, err
+	}
+
+	return jointPubKey, jointPrimeKeys, sia.PubKeyUnlockConditions(jointPubKey), nil
+}
+
+func (s *AtomicSwap) enableFunding() error {
+	_, _, jointUnlockConditions, err := s.jointKey()
+	if err != nil {
+		return err
+	}
+
+	refundTx := sia.BuildRefundTransaction(
+		s.RefundDetails.FundingOutputID, jointUnlockConditions, s.RefundDetails.BobRefundUnlockHash,
+		s.Siacoin, defaultMinerFee, s.RefundDetails.Timelock)
+
+	refundSigHash := sia.WholeSigHash(refundTx, s.Height)
+	aliceRefundNoncePoint := ed25519.GenerateNoncePoint(s.AliceKeypair.PrivKey, refundSigHash)
+	refundSigAlice, err := keypair.JointSignAlice(s.AliceKeypair, s.RefundDetails.BobPubKey,
+		[]ed25519.CurvePoint{aliceRefundNoncePoint, s.RefundDetails.BobRefundNoncePoint}, refundSigHash)
+	if err != nil {
+		return err
+	}
+	s.sink.HandleEvent(Event{Phase: PhaseRefundSigned})
+
+	fundingTxID, err := s.server.EnableFunding(s.session(), aliceRefundNoncePoint, refundSigAlice)
+	if err != nil {
+		return err
+	}
+	s.sink.HandleEvent(Event{Phase: PhaseFundingBroadcast, TxID: fundingTxID.String()})
+
+	s.FundingTxID = *fundingTxID
+	s.State = stateFundingEnabled
+	return nil
+}
+
+func (s *AtomicSwap) checkFundingConfirmations() (bool, error) {
+	_, _, jointUnlockConditions, err := s.jointKey()
+	if err != nil {
+		return false, err
+	}
+
+	confs, err := s.siaChain.ConfsOfRecentUnlockHash(
+		jointUnlockConditions.UnlockHash(), s.Siacoin.Add(defaultMinerFee))
+	if err != nil {
+		return false, err
+	}
+
+	s.trackConfirmations(PhaseFundingConfirmations, confs, s.FundingConfirmations)
+	if confs < s.FundingConfirmations {
+		return true, nil
+	}
+
+	s.sink.HandleEvent(Event{Phase: PhaseFundingDetected, TxID: s.FundingTxID.String()})
+	s.State = stateFunded
+	return false, nil
+}
+
+func (s *AtomicSwap) buildClaimTransaction() (types.Transaction, []byte, error) {
+	_, _, jointUnlockConditions, err := s.jointKey()
+	if err != nil {
+		return types.Transaction{}, nil, err
+	}
+
+	claimTx := sia.BuildClaimTransaction(
+		s.RefundDetails.FundingOutputID, jointUnlockConditions, s.ClaimUnlockHash,
+		s.Siacoin, defaultMinerFee)
+	return claimTx, sia.WholeSigHash(claimTx, s.Height), nil
+}
+
+func (s *AtomicSwap) requestAdaptorDetails() error {
+	if s.ClaimUnlockHash == (types.UnlockHash{}) {
+		claimUnlockHash, err := s.siaChain.NextWalletUnlockHash()
+		if err != nil {
+			return err
+		}
+		s.ClaimUnlockHash = *claimUnlockHash
+	}
+
+	_, claimSigHash, err := s.buildClaimTransaction()
+	if err != nil {
+		return err
+	}
+	claimNoncePoint := ed25519.GenerateNoncePoint(s.AliceKeypair.PrivKey, claimSigHash)
+
+	adaptorDetails, err := s.server.RequestAdaptorDetails(s.session(), s.ClaimUnlockHash, claimNoncePoint)
+	if err != nil {
+		return err
+	}
+
+	jointPubKey, jointPrimeKeys, _, err := s.jointKey()
+	if err != nil {
+		return err
+	}
+
+	adaptorSigOK := keypair.VerifyBobsAdaptorSignature(
+		jointPrimeKeys, jointPubKey, []ed25519.CurvePoint{claimNoncePoint, adaptorDetails.BobClaimNoncePoint},
+		adaptorDetails.AdaptorPubKey, claimSigHash, adaptorDetails.AdaptorSigBob)
+	if !adaptorSigOK {
+		return ErrInvalidAdaptorSig
+	}
+
+	s.ClaimNoncePoint = claimNoncePoint
+	s.AdaptorDetails = *adaptorDetails
+	s.State = stateReceivedAdaptorDetails
+	return nil
+}
+
+func (s *AtomicSwap) deposit() error {
+	s.sink.HandleEvent(Event{Phase: PhaseDepositing, Siacoin: s.Siacoin, Offer: &s.BindingOffer,
+		Recipient: s.AdaptorDetails.DepositRecipient, AntiSpamID: &s.AntiSpamID})
+
+	receipt, err := s.ethChain.DepositEther(
+		s.AdaptorDetails.DepositRecipient, s.AdaptorDetails.AdaptorPubKey, s.BindingOffer.Ether, s.AntiSpamID)
+	if err != nil {
+		return err
+	}
+	s.DepositReceipt = receipt
+
+	txID := ""
+	if receipt != nil {
+		txID = receipt.TxHash.Hex()
+	}
+	s.sink.HandleEvent(Event{Phase: PhaseDepositMade,
+		Recipient: s.AdaptorDetails.DepositRecipient, AntiSpamID: &s.AntiSpamID, TxID: txID})
+
+	s.State = stateDeposited
+	return nil
+}
+
+func (s *AtomicSwap) checkDepositConfirmations() (bool, error) {
+	confs, err := s.ethChain.CheckDepositConfirmations(
+		s.AdaptorDetails.DepositRecipient, s.AdaptorDetails.AdaptorPubKey, s.BindingOffer.Ether, s.AntiSpamID)
+	if err != nil {
+		return false, err
+	}
+
+	s.trackConfirmations(PhaseDepositConfirmations, confs, depositConfirmations)
+	if confs < depositConfirmations {
+		return true, nil
+	}
+
+	s.sink.HandleEvent(Event{Phase: PhaseDepositConfirmed, AntiSpamID: &s.AntiSpamID})
+	s.State = stateDepositConfirmed
+	return false, nil
+}
+
+func (s *AtomicSwap) announceDeposit() error {
+	s.sink.HandleEvent(Event{Phase: PhaseAnnouncingDeposit})
+
+	err := s.server.AnnounceDeposit(s.session())
+	if err != nil {
+		return err
+	}
+
+	s.State = stateDepositAnnounced
+	return nil
+}
+
+func (s *AtomicSwap) lookupAdaptorPrivKey() (bool, error) {
+	ok, adaptorPrivKey, err := s.ethChain.LookupAdaptorPrivKey(s.AdaptorDetails.AdaptorPubKey)
+	if err != nil {
+		return false, err
+	}
+
+	if !ok {
+		return true, nil
+	}
+
+	s.sink.HandleEvent(Event{Phase: PhaseAdaptorRevealed})
+	s.AdaptorPrivKey = *adaptorPrivKey
+	s.State = stateAdaptorRevealed
+	return false, nil
+}
+
+func (s *AtomicSwap) claim() error {
+	claimTx, claimSigHash, err := s.buildClaimTransaction()
+	if err != nil {
+		return err
+	}
+
+	noncePoints := []ed25519.CurvePoint{s.ClaimNoncePoint, s.AdaptorDetails.BobClaimNoncePoint}
+	adaptorSigAlice, err := keypair.JointSignWithAdaptorAlice(
+		s.AliceKeypair, s.RefundDetails.BobPubKey, noncePoints, s.AdaptorDetails.AdaptorPubKey, claimSigHash)
+	if err != nil {
+		return err
+	}
+
+	claimSig := ed25519.AddSignature(adaptorSigAlice, s.AdaptorDetails.AdaptorSigBob)
+	claimSig = ed25519.AddSignature(claimSig, append(s.AdaptorDetails.AdaptorPubKey, s.AdaptorPrivKey...))
+
+	jointPubKey, _, _, err := s.jointKey()
+	if err != nil {
+		return err
+	}
+
+	claimSigOK := ed25519.Verify(jointPubKey, claimSigHash, claimSig)
+	if !claimSigOK {
+		return ErrInvalidClaimSig
+	}
+
+	claimTx = sia.AddSignature(claimTx, claimSig)
+	err = s.siaChain.BroadcastTransaction(claimTx)
+	if err != nil {
+		return err
+	}
+	s.sink.HandleEvent(Event{Phase: PhaseClaimBroadcast, TxID: claimTx.ID().String()})
+
+	s.ClaimTxID = claimTx.ID()
+	if s.NonBindingOffer.Escrow != nil {
+		s.State = stateClaimed // the anti-spam fee has been credited back
+		return nil
+	}
+
+	s.complete()
+	return nil
+}
+
+func (s *AtomicSwap) withdrawCredit() error {
+	// The balance may include fees of other swaps, or the fee of this swap
+	// may already have been withdrawn by another one, so only the fee we
+	// escrowed is attributed to this swap.
+	withdrawn, err := s.ethChain.WithdrawCredit()
+	if err != nil {
+		return err
+	}
+	if withdrawn.Sign() == 1 {
+		s.sink.HandleEvent(Event{Phase: PhaseCreditWithdrawn, Credit: withdrawn})
+	}
+	s.Credit = new(big.Int).Set(&s.NonBindingOffer.AntiSpamFee)
+
+	s.complete()
+	return nil
+}
+
+func (s *AtomicSwap) complete() {
+	s.State = stateCompleted
+	s.sink.HandleEvent(Event{Phase: PhaseCompleted, Result: s.Result()})
+}
+
+func (s *AtomicSwap) session() rpc.Session {
+	return rpc.Session{ID: s.ID, Keypair: s.SessionKeypair}
+}
+
+func (s *AtomicSwap) trackConfirmations(phase Phase, confs int64, required int64) {
+	if s.tracker == nil || s.tracker.phase != phase {
+		s.tracker = &confirmationTracker{phase: phase, current: -1, total: required, sink: s.sink}
+	}
+
+	s.tracker.update(confs)
+}
+
+func (s *AtomicSwap) StateText() string {
+	switch s.State {
+	case stateInitialized:
+		return "stateInitialized"
+	case stateBurnedAntiSpamFee:
+		return "stateBurnedAntiSpamFee"
+	case stateAntiSpamConfirmed:
+		return "stateAntiSpamConfirmed"
+	case stateBindingOfferApproved:
+		return "stateBindingOfferApproved"
+	case stateOfferAccepted:
+		return "stateOfferAccepted"
+	case stateFundingEnabled:
+		return "stateFundingEnabled"
+	case stateFunded:
+		return "stateFunded"
+	case stateReceivedAdaptorDetails:
+		return "stateReceivedAdaptorDetails"
+	case stateDeposited:
+		return "stateDeposited"
+	case stateDepositConfirmed:
+		return "stateDepositConfirmed"
+	case stateDepositAnnounced:
+		return "stateDepositAnnounced"
+	case stateAdaptorRevealed:
+		return "stateAdaptorRevealed"
+	case stateClaimed:
+		return "stateClaimed"
+	case stateCompleted:
+		return "stateCompleted"
+	default:
+		return "stateDeclined"
+	}
+}
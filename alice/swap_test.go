@@ -0,0 +1,158 @@
+package alice
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"gitlab.com/NebulousLabs/Sia/types"
+
+	"github.com/javgh/roadie/blockchain/ethereum"
+	"github.com/javgh/roadie/contract/retryinghub"
+	"github.com/javgh/roadie/frontend"
+	"github.com/javgh/roadie/rpc"
+	"github.com/javgh/roadie/trader"
+)
+
+type (
+	// the embedded interfaces are left nil; only the methods used by the
+	// tested steps are implemented
+	fakeEthChain struct {
+		ethereum.Blockchain
+		burned   int
+		escrowed int
+		confs    int64
+	}
+
+	fakeServer struct {
+		Server
+		offer trader.Offer
+	}
+
+	decliningFrontend struct{}
+)
+
+func (c *fakeEthChain) BurnAntiSpamFee(antiSpamID big.Int, antiSpamFee big.Int) (*retryinghub.Receipt, error) {
+	c.burned++
+	return &retryinghub.Receipt{Fee: *big.NewInt(21000)}, nil
+}
+
+func (c *fakeEthChain) CheckAntiSpamConfirmations(antiSpamID big.Int, antiSpamFee big.Int) (int64, error) {
+	return c.confs, nil
+}
+
+func (c *fakeEthChain) EscrowAntiSpamFee(antiSpamID big.Int, antiSpamFee big.Int,
+	server common.Address) (*retryinghub.Receipt, error) {
+	c.escrowed++
+	return &retryinghub.Receipt{Fee: *big.NewInt(42000)}, nil
+}
+
+func (c *fakeEthChain) CheckEscrowConfirmations(antiSpamID big.Int, antiSpamFee big.Int,
+	server common.Address) (int64, error) {
+	return c.confs, nil
+}
+
+func (s *fakeServer) RequestBindingOffer(session rpc.Session, antiSpamID big.Int) (*trader.Offer, error) {
+	return &s.offer, nil
+}
+
+func (f *decliningFrontend) ChooseOffer(quotes []frontend.Quote) (int, error) {
+	return frontend.NoChoice, nil
+}
+
+func (f *decliningFrontend) ApproveOffer(siacoin types.Currency, offer trader.Offer, binding bool) (bool, error) {
+	return false, nil
+}
+
+func (f *decliningFrontend) CheckSimilarity(a trader.Offer, b trader.Offer) bool {
+	return false
+}
+
+func TestAtomicSwap(t *testing.T) {
+	ethChain := &fakeEthChain{}
+	offer := trader.Offer{Available: true, Ether: *big.NewInt(1e18), AntiSpamFee: *big.NewInt(1e14)}
+	server := &fakeServer{offer: offer}
+	sink := EventSinkFunc(func(event Event) {})
+	details := ethereum.ServerDetails{Target: "localhost:9001"}
+
+	swap, err := NewAtomicSwap(details, rpc.Session{ID: uuid.Must(uuid.NewRandom())}, types.SiacoinPrecision, Order{},
+		offer, 6, server, ethChain, nil, sink)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Nil(t, swap.spent(), "should not have spent anything yet")
+
+	waiting, err := swap.Step(&decliningFrontend{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.False(t, waiting)
+	assert.Equal(t, 1, ethChain.burned, "should burn anti-spam fee")
+	assert.Equal(t, "stateBurnedAntiSpamFee", swap.StateText())
+	spent := swap.spent()
+	assert.Equal(t, big.NewInt(1e14), &spent.AntiSpamFee, "should have spent anti-spam fee")
+	assert.Equal(t, swap.AntiSpamID, spent.AntiSpamID)
+	assert.Equal(t, 0, spent.Ether.Sign(), "should not have deposited yet")
+
+	waiting, err = swap.Step(&decliningFrontend{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, waiting, "should wait for confirmations")
+	assert.Equal(t, "stateBurnedAntiSpamFee", swap.StateText())
+
+	data, err := json.Marshal(swap)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resumed, err := LoadAtomicSwap(data, server, ethChain, nil, sink)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, swap.AntiSpamID, resumed.AntiSpamID, "should restore anti-spam id")
+	assert.Equal(t, swap.BurnReceipt, resumed.BurnReceipt, "should restore receipt")
+	assert.Equal(t, "stateBurnedAntiSpamFee", resumed.StateText(), "should restore state")
+
+	ethChain.confs = antiSpamConfirmations
+	var checkpoints []string
+	result, err := resumed.Run(&decliningFrontend{}, 0, func(s *AtomicSwap) error {
+		checkpoints = append(checkpoints, s.StateText())
+		return nil
+	})
+	assert.Equal(t, ErrOfferDeclined, err, "should report declined binding offer")
+	if assert.NotNil(t, result, "should report spent anti-spam fee") {
+		assert.Equal(t, swap.NonBindingOffer.AntiSpamFee, result.AntiSpamFee)
+		assert.Equal(t, 0, result.Ether.Sign(), "should not report ether as spent")
+	}
+	assert.Equal(t, 1, ethChain.burned, "should not burn anti-spam fee again")
+	assert.Equal(t, []string{"stateBurnedAntiSpamFee", "stateAntiSpamConfirmed", "stateDeclined"}, checkpoints)
+
+	_, err = resumed.Step(&decliningFrontend{})
+	assert.Equal(t, ErrWrongState, err, "should not continue after swap is done")
+}
+
+func TestAtomicSwapEscrow(t *testing.T) {
+	ethChain := &fakeEthChain{confs: antiSpamConfirmations}
+	escrow := common.HexToAddress("0x44f1911Df3E915b21F385892B75E36002A859dF7")
+	offer := trader.Offer{Available: true, Ether: *big.NewInt(1e18), AntiSpamFee: *big.NewInt(1e14), Escrow: &escrow}
+	server := &fakeServer{offer: offer}
+	sink := EventSinkFunc(func(event Event) {})
+	details := ethereum.ServerDetails{Target: "localhost:9001"}
+
+	swap, err := NewAtomicSwap(details, rpc.Session{ID: uuid.Must(uuid.NewRandom())}, types.SiacoinPrecision, Order{},
+		offer, 6, server, ethChain, nil, sink)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = swap.Run(&decliningFrontend{}, 0, nil)
+	assert.Equal(t, ErrOfferDeclined, err)
+	assert.Equal(t, 1, ethChain.escrowed, "should escrow anti-spam fee")
+	assert.Equal(t, 0, ethChain.burned, "should not burn anti-spam fee")
+	assert.Equal(t, big.NewInt(42000), &swap.BurnReceipt.Fee)
+}
@@ -0,0 +1,488 @@
+// Package backtest replays historical exchange rates and a stream of swap
+// requests against a pricing strategy, so that changes to a strategy can be
+// evaluated before they are deployed.
+package backtest
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gitlab.com/NebulousLabs/Sia/modules"
+	"gitlab.com/NebulousLabs/Sia/types"
+
+	"github.com/javgh/roadie/blockchain/ethereum"
+	"github.com/javgh/roadie/blockchain/sia"
+	"github.com/javgh/roadie/output"
+	"github.com/javgh/roadie/trader"
+)
+
+type (
+	// RatePoint holds the USD prices of one ETH and one SC from a certain
+	// time on.
+	RatePoint struct {
+		Time       time.Time
+		USDEther   *big.Rat
+		USDSiacoin *big.Rat
+	}
+
+	// Request is a buyer asking to buy siacoins at a certain time.
+	Request struct {
+		Time    time.Time
+		Siacoin types.Currency
+	}
+
+	// Config describes the simulated server and its buyers. Buyers accept an
+	// offer if it exceeds the market value of the siacoins by at most
+	// Tolerance (0.03 is 3 %). A swap takes SwapDuration from the binding
+	// offer until the ether is claimed. As on a real server, order
+	// preparation is paused after each accepted offer until the swap has been
+	// funded, which takes FundingDuration.
+	Config struct {
+		Balance         types.Currency
+		GasPrice        *big.Int
+		MinerFee        types.Currency
+		Tolerance       *big.Rat
+		SwapDuration    time.Duration
+		FundingDuration time.Duration
+	}
+
+	// Environment provides simulated stand-ins for the exchange rates and
+	// blockchains a strategy depends on. It implements trader.RateFetcher
+	// and trader.VolumeTracker. Only the methods of EthChain and SiaChain
+	// relevant for pricing are simulated; all others panic.
+	Environment struct {
+		EthChain ethereum.Blockchain
+		SiaChain sia.Blockchain
+
+		rates    []RatePoint
+		now      time.Time
+		balance  types.Currency
+		gasPrice *big.Int
+		fills    []Request
+	}
+
+	// Report sums up a backtest. Amounts in USD are valued at the rates at
+	// the time of each swap. Exposure is the USD value of siacoins in swaps
+	// that have been offered but not yet completed. The worst fill is the
+	// lowest profit of a single swap, taking into account that the ether
+	// might lose value until the swap completes.
+	Report struct {
+		Requests       int
+		Offers         int
+		Fills          int
+		Errors         int
+		InitialBalance types.Currency
+		FinalBalance   types.Currency
+		SiacoinSold    types.Currency
+		MinerFees      types.Currency
+		Revenue        big.Int
+		ClaimFees      big.Int
+		ProfitUSD      big.Rat
+		MaxOpenSiacoin types.Currency
+		MaxExposureUSD big.Rat
+		WorstFillUSD   *big.Rat // nil if there were no fills
+	}
+
+	simEthChain struct {
+		ethereum.Blockchain
+		env *Environment
+	}
+
+	simSiaChain struct {
+		sia.Blockchain
+		env *Environment
+	}
+
+	openSwap struct {
+		completion time.Time
+		siacoin    types.Currency
+	}
+)
+
+const (
+	claimGasEstimate = 100000
+)
+
+var (
+	ErrNoRates     = errors.New("no exchange rates to replay")
+	ErrInvalidRate = errors.New("unable to parse exchange rate")
+	ErrInvalidTime = errors.New("unable to parse time - expected RFC 3339 or Unix time")
+)
+
+func NewEnvironment(rates []RatePoint, balance types.Currency, gasPrice *big.Int) (*Environment, error) {
+	if len(rates) == 0 {
+		return nil, ErrNoRates
+	}
+
+	sorted := append([]RatePoint(nil), rates...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Time.Before(sorted[j].Time) })
+
+	env := Environment{rates: sorted, balance: balance, gasPrice: gasPrice}
+	env.EthChain = simEthChain{env: &env}
+	env.SiaChain = simSiaChain{env: &env}
+	return &env, nil
+}
+
+// Fetch returns the most recent rate at the current simulated time, or the
+// first rate if the simulation starts before it.
+func (e *Environment) Fetch(id string) (*big.Rat, error) {
+	point := e.rate(e.now)
+	switch id {
+	case "ethereum":
+		return new(big.Rat).Set(point.USDEther), nil
+	case "siacoin":
+		return new(big.Rat).Set(point.USDSiacoin), nil
+	default:
+		return nil, trader.ErrExchangeRateNotFound
+	}
+}
+
+// Volume sums up the siacoins sold since the given time.
+func (e *Environment) Volume(since time.Time) (types.Currency, error) {
+	volume := types.ZeroCurrency
+	for _, fill := range e.fills {
+		if fill.Time.After(since) {
+			volume = volume.Add(fill.Siacoin)
+		}
+	}
+	return volume, nil
+}
+
+func (e *Environment) rate(at time.Time) RatePoint {
+	i := sort.Search(len(e.rates), func(i int) bool { return e.rates[i].Time.After(at) })
+	if i == 0 {
+		return e.rates[0]
+	}
+	return e.rates[i-1]
+}
+
+func (c simEthChain) SuggestGasPrice() (*big.Int, error) {
+	return new(big.Int).Set(c.env.gasPrice), nil
+}
+
+func (c simSiaChain) FetchUsableOutputs() ([]sia.UsableOutput, error) {
+	if c.env.balance.IsZero() {
+		return nil, nil
+	}
+
+	usableOutput := sia.UsableOutput{UnspentOutput: modules.UnspentOutput{Value: c.env.balance}}
+	return []sia.UsableOutput{usableOutput}, nil
+}
+
+// Run replays the requests in order of time against the strategy returned by
+// newTrader, which should use the environment for rates and blockchains.
+func Run(rates []RatePoint, requests []Request, config Config,
+	newTrader func(env *Environment) (trader.Trader, error)) (*Report, error) {
+	env, err := NewEnvironment(rates, config.Balance, config.GasPrice)
+	if err != nil {
+		return nil, err
+	}
+
+	strategy, err := newTrader(env)
+	if err != nil {
+		return nil, err
+	}
+
+	sorted := append([]Request(nil), requests...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Time.Before(sorted[j].Time) })
+
+	report := Report{InitialBalance: config.Balance, FinalBalance: config.Balance}
+	claimFee := new(big.Int).Mul(big.NewInt(claimGasEstimate), config.GasPrice)
+	var open []openSwap
+	var funded *time.Time
+
+	for _, req := range sorted {
+		env.now = req.Time
+		report.Requests++
+
+		if funded != nil && !req.Time.Before(*funded) {
+			strategy.ResumeOrderPreparation()
+			funded = nil
+		}
+
+		remaining := open[:0]
+		for _, swap := range open {
+			if swap.completion.After(req.Time) {
+				remaining = append(remaining, swap)
+			}
+		}
+		open = remaining
+
+		offer, _, err := strategy.PrepareBindingOffer(req.Siacoin, config.MinerFee, req.Time)
+		if err != nil {
+			report.Errors++
+			continue
+		}
+
+		if !offer.Available {
+			continue
+		}
+		report.Offers++
+
+		point := env.rate(req.Time)
+		marketUSD := sia.ApplyRate(req.Siacoin, point.USDSiacoin)
+		offerUSD := ethereum.ApplyRate(&offer.Ether, point.USDEther)
+		limitUSD := new(big.Rat).Set(marketUSD)
+		if config.Tolerance != nil {
+			limitUSD.Mul(limitUSD, new(big.Rat).Add(big.NewRat(1, 1), config.Tolerance))
+		}
+		if offerUSD.Cmp(limitUSD) == 1 {
+			continue
+		}
+
+		// The buyer accepts.
+		report.Fills++
+		strategy.PauseOrderPreparation(req.Time)
+		fundingDone := req.Time.Add(config.FundingDuration)
+		funded = &fundingDone
+
+		spent := req.Siacoin.Add(config.MinerFee).Add(config.MinerFee)
+		if spent.Cmp(env.balance) == 1 {
+			spent = env.balance
+		}
+		env.balance = env.balance.Sub(spent)
+		env.fills = append(env.fills, req)
+
+		report.SiacoinSold = report.SiacoinSold.Add(req.Siacoin)
+		report.MinerFees = report.MinerFees.Add(config.MinerFee).Add(config.MinerFee)
+		report.Revenue.Add(&report.Revenue, &offer.Ether)
+		report.ClaimFees.Add(&report.ClaimFees, claimFee)
+
+		received := new(big.Int).Sub(&offer.Ether, claimFee)
+		costUSD := sia.ApplyRate(spent, point.USDSiacoin)
+		profitUSD := new(big.Rat).Sub(ethereum.ApplyRate(received, point.USDEther), costUSD)
+		report.ProfitUSD.Add(&report.ProfitUSD, profitUSD)
+
+		completion := req.Time.Add(config.SwapDuration)
+		settledUSD := new(big.Rat).Sub(ethereum.ApplyRate(received, env.rate(completion).USDEther), costUSD)
+		worst := profitUSD
+		if settledUSD.Cmp(worst) == -1 {
+			worst = settledUSD
+		}
+		if report.WorstFillUSD == nil || worst.Cmp(report.WorstFillUSD) == -1 {
+			report.WorstFillUSD = worst
+		}
+
+		open = append(open, openSwap{completion: completion, siacoin: spent})
+		openSiacoin := types.ZeroCurrency
+		for _, swap := range open {
+			openSiacoin = openSiacoin.Add(swap.siacoin)
+		}
+		if openSiacoin.Cmp(report.MaxOpenSiacoin) == 1 {
+			report.MaxOpenSiacoin = openSiacoin
+		}
+		exposureUSD := sia.ApplyRate(openSiacoin, point.USDSiacoin)
+		if exposureUSD.Cmp(&report.MaxExposureUSD) == 1 {
+			report.MaxExposureUSD.Set(exposureUSD)
+		}
+	}
+
+	report.FinalBalance = env.balance
+	return &report, nil
+}
+
+func (r Report) FillRate() float64 {
+	if r.Requests == 0 {
+		return 0
+	}
+	return float64(r.Fills) / float64(r.Requests)
+}
+
+// InventoryUsage returns the share of the initial balance that was spent.
+func (r Report) InventoryUsage() float64 {
+	if r.InitialBalance.IsZero() {
+		return 0
+	}
+	usage, _ := new(big.Rat).SetFrac(r.InitialBalance.Sub(r.FinalBalance).Big(), r.InitialBalance.Big()).Float64()
+	return usage
+}
+
+func (r Report) Fields() output.Fields {
+	fields := output.Fields{
+		"requests":         r.Requests,
+		"offers":           r.Offers,
+		"fills":            r.Fills,
+		"errors":           r.Errors,
+		"fill_rate":        r.FillRate(),
+		"siacoin_sold":     r.SiacoinSold.String(),
+		"miner_fees":       r.MinerFees.String(),
+		"revenue":          r.Revenue.String(),
+		"claim_fees":       r.ClaimFees.String(),
+		"profit_usd":       r.ProfitUSD.FloatString(4),
+		"inventory_usage":  r.InventoryUsage(),
+		"max_open_siacoin": r.MaxOpenSiacoin.String(),
+		"max_exposure_usd": r.MaxExposureUSD.FloatString(4),
+	}
+	if r.WorstFillUSD != nil {
+		fields["worst_fill_usd"] = r.WorstFillUSD.FloatString(4)
+	}
+	return fields
+}
+
+func (r Report) String() string {
+	worstFill := "n/a"
+	if r.WorstFillUSD != nil {
+		worstFill = trader.FormatUSD(r.WorstFillUSD)
+	}
+
+	lines := []string{
+		fmt.Sprintf("Requests:        %d (%d offers, %d errors)", r.Requests, r.Offers, r.Errors),
+		fmt.Sprintf("Fills:           %d (%.1f %%)", r.Fills, 100*r.FillRate()),
+		fmt.Sprintf("Sold:            %s (+ %s miner fees)", r.SiacoinSold.HumanString(), r.MinerFees.HumanString()),
+		fmt.Sprintf("Revenue:         %s (- %s claim fees)", ethereum.FormatEther(&r.Revenue), ethereum.FormatEther(&r.ClaimFees)),
+		fmt.Sprintf("Profit:          %s", trader.FormatUSD(&r.ProfitUSD)),
+		fmt.Sprintf("Inventory usage: %.1f %% (%s left)", 100*r.InventoryUsage(), r.FinalBalance.HumanString()),
+		fmt.Sprintf("Max exposure:    %s (%s in open swaps)", trader.FormatUSD(&r.MaxExposureUSD), r.MaxOpenSiacoin.HumanString()),
+		fmt.Sprintf("Worst fill:      %s", worstFill),
+	}
+	return strings.Join(lines, "\n")
+}
+
+// LoadRates reads a rate series in CSV format with the columns time, USD per
+// ETH and USD per SC. A header line is skipped.
+func LoadRates(reader io.Reader) ([]RatePoint, error) {
+	records, err := readCSV(reader, 3)
+	if err != nil {
+		return nil, err
+	}
+
+	var rates []RatePoint
+	for _, record := range records {
+		t, err := parseTime(record[0])
+		if err != nil {
+			return nil, err
+		}
+
+		usdEther, ok := new(big.Rat).SetString(record[1])
+		if !ok || usdEther.Sign() != 1 {
+			return nil, ErrInvalidRate
+		}
+
+		usdSiacoin, ok := new(big.Rat).SetString(record[2])
+		if !ok || usdSiacoin.Sign() != 1 {
+			return nil, ErrInvalidRate
+		}
+
+		rates = append(rates, RatePoint{Time: t, USDEther: usdEther, USDSiacoin: usdSiacoin})
+	}
+
+	if len(rates) == 0 {
+		return nil, ErrNoRates
+	}
+	return rates, nil
+}
+
+// LoadRequests reads requests in CSV format with the columns time and amount
+// in SC. A header line is skipped.
+func LoadRequests(reader io.Reader) ([]Request, error) {
+	records, err := readCSV(reader, 2)
+	if err != nil {
+		return nil, err
+	}
+
+	var requests []Request
+	for _, record := range records {
+		t, err := parseTime(record[0])
+		if err != nil {
+			return nil, err
+		}
+
+		siacoin, err := sia.ParseSiacoin(record[1])
+		if err != nil {
+			return nil, err
+		}
+
+		requests = append(requests, Request{Time: t, Siacoin: siacoin})
+	}
+
+	return requests, nil
+}
+
+// GenerateRequests returns a synthetic request stream between start and end.
+// Requests arrive at random with the given average rate per hour; their
+// amounts are spread evenly on a logarithmic scale between min and max.
+func GenerateRequests(start time.Time, end time.Time, perHour float64,
+	min types.Currency, max types.Currency, seed int64) []Request {
+	var requests []Request
+	if perHour <= 0 {
+		return requests
+	}
+
+	random := rand.New(rand.NewSource(seed))
+	minFloat, _ := new(big.Rat).SetInt(min.Big()).Float64()
+	maxFloat, _ := new(big.Rat).SetInt(max.Big()).Float64()
+	if minFloat < 1 {
+		minFloat = 1
+	}
+	if maxFloat < minFloat {
+		maxFloat = minFloat
+	}
+
+	t := start
+	for {
+		hours := random.ExpFloat64() / perHour
+		t = t.Add(time.Duration(hours * float64(time.Hour)))
+		if !t.Before(end) {
+			return requests
+		}
+
+		hastings := math.Exp(math.Log(minFloat) + random.Float64()*(math.Log(maxFloat)-math.Log(minFloat)))
+		amount, _ := new(big.Float).SetFloat64(hastings).Int(nil)
+		requests = append(requests, Request{Time: t, Siacoin: types.NewCurrency(amount)})
+	}
+}
+
+// Span returns the time of the first and the last rate.
+func Span(rates []RatePoint) (time.Time, time.Time) {
+	start, end := rates[0].Time, rates[0].Time
+	for _, point := range rates {
+		if point.Time.Before(start) {
+			start = point.Time
+		}
+		if point.Time.After(end) {
+			end = point.Time
+		}
+	}
+	return start, end
+}
+
+func readCSV(reader io.Reader, columns int) ([][]string, error) {
+	csvReader := csv.NewReader(reader)
+	csvReader.FieldsPerRecord = columns
+	csvReader.TrimLeadingSpace = true
+
+	records, err := csvReader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(records) > 0 {
+		_, err := parseTime(records[0][0])
+		if err != nil {
+			records = records[1:] // header
+		}
+	}
+	return records, nil
+}
+
+func parseTime(value string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339, value)
+	if err == nil {
+		return t, nil
+	}
+
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return time.Time{}, ErrInvalidTime
+	}
+	return time.Unix(seconds, 0).UTC(), nil
+}
@@ -0,0 +1,131 @@
+package backtest
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gitlab.com/NebulousLabs/Sia/types"
+
+	"github.com/javgh/roadie/trader"
+)
+
+const (
+	testRates = `time,usd_per_eth,usd_per_sc
+2019-09-01T00:00:00Z,200,0.001
+2019-09-01T12:00:00Z,100,0.001
+`
+	testRequests = `time,sc
+2019-09-01T01:00:00Z,1000
+2019-09-01T02:00:00Z,0.5
+2019-09-01T03:00:00Z,2000
+2019-09-01T04:00:00Z,50000
+1567339200,1000
+`
+)
+
+func TestBacktest(t *testing.T) {
+	rates, err := LoadRates(strings.NewReader(testRates))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, rates, 2)
+
+	requests, err := LoadRequests(strings.NewReader(testRequests))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, requests, 5)
+	assert.Equal(t, time.Date(2019, 9, 1, 12, 0, 0, 0, time.UTC), requests[4].Time)
+
+	config := Config{
+		Balance:      types.SiacoinPrecision.Mul64(10000),
+		GasPrice:     big.NewInt(1e9),
+		MinerFee:     types.SiacoinPrecision,
+		Tolerance:    big.NewRat(3, 1),
+		SwapDuration: 12 * time.Hour,
+	}
+	report, err := Run(rates, requests, config, func(env *Environment) (trader.Trader, error) {
+		fixedPremiumTrader := trader.NewFixedPremiumTrader(nil, *big.NewInt(1e14), env.EthChain, env.SiaChain)
+		fixedPremiumTrader.SetRates(env)
+		return &fixedPremiumTrader, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// too small and too large requests are declined
+	assert.Equal(t, 5, report.Requests)
+	assert.Equal(t, 3, report.Fills)
+	assert.Equal(t, 0, report.Errors)
+	assert.Equal(t, types.SiacoinPrecision.Mul64(4000), report.SiacoinSold)
+	assert.Equal(t, config.Balance.Sub(types.SiacoinPrecision.Mul64(4006)), report.FinalBalance)
+	assert.True(t, report.ProfitUSD.Sign() == 1, "expected gas surcharge to exceed claim fees")
+
+	// the ether of the first swaps halves in value until they complete
+	assert.True(t, report.WorstFillUSD.Sign() == -1)
+	assert.Equal(t, types.SiacoinPrecision.Mul64(4006), report.MaxOpenSiacoin)
+
+	// buyers without tolerance decline every offer
+	config.Tolerance = nil
+	report, err = Run(rates, requests, config, func(env *Environment) (trader.Trader, error) {
+		inventoryTrader := trader.NewInventoryTrader(
+			trader.InventoryConfig{BasePremium: big.NewRat(1, 100)}, env, env, env.EthChain, env.SiaChain)
+		return &inventoryTrader, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 3, report.Offers)
+	assert.Equal(t, 0, report.Fills)
+	assert.Nil(t, report.WorstFillUSD)
+}
+
+func TestBacktestPausesUntilFunded(t *testing.T) {
+	rates, err := LoadRates(strings.NewReader(testRates))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Date(2019, 9, 1, 1, 0, 0, 0, time.UTC)
+	requests := []Request{
+		{Time: start, Siacoin: types.SiacoinPrecision.Mul64(1000)},
+		{Time: start.Add(30 * time.Second), Siacoin: types.SiacoinPrecision.Mul64(1000)},
+		{Time: start.Add(2 * time.Minute), Siacoin: types.SiacoinPrecision.Mul64(1000)},
+	}
+	config := Config{
+		Balance:         types.SiacoinPrecision.Mul64(10000),
+		GasPrice:        big.NewInt(1e9),
+		MinerFee:        types.SiacoinPrecision,
+		Tolerance:       big.NewRat(3, 1),
+		SwapDuration:    time.Hour,
+		FundingDuration: time.Minute,
+	}
+	report, err := Run(rates, requests, config, func(env *Environment) (trader.Trader, error) {
+		fixedPremiumTrader := trader.NewFixedPremiumTrader(nil, *big.NewInt(1e14), env.EthChain, env.SiaChain)
+		fixedPremiumTrader.SetRates(env)
+		return &fixedPremiumTrader, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 2, report.Offers, "expected no offer while the first swap is being funded")
+	assert.Equal(t, 2, report.Fills)
+}
+
+func TestGenerateRequests(t *testing.T) {
+	start := time.Date(2019, 9, 1, 0, 0, 0, 0, time.UTC)
+	min, max := types.SiacoinPrecision.Mul64(100), types.SiacoinPrecision.Mul64(10000)
+
+	requests := GenerateRequests(start, start.Add(100*time.Hour), 2, min, max, 1)
+	assert.InDelta(t, 200, len(requests), 50)
+	for _, req := range requests {
+		assert.True(t, req.Time.After(start))
+		assert.True(t, req.Siacoin.Cmp(min) != -1 && req.Siacoin.Cmp(max) != 1)
+	}
+
+	assert.Equal(t, requests, GenerateRequests(start, start.Add(100*time.Hour), 2, min, max, 1))
+}
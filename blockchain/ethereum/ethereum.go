@@ -26,6 +26,7 @@ import (
 
 	contract "github.com/javgh/roadie/contract/hub"
 	"github.com/javgh/roadie/contract/retryinghub"
+	"github.com/javgh/roadie/output"
 )
 
 const (
@@ -49,6 +50,11 @@ var (
 	ErrIncompatibleVersion = errors.New("smart contract has an incompatible version - please upgrade")
 	ErrDeprecated          = errors.New("smart contract is marked as deprecated - please check for updates")
 	ErrUnexpectedDirectory = errors.New("keystore location appears to be a directory")
+	ErrInvalidAmount       = errors.New("unable to parse ether amount")
+	ErrInvalidAddress      = errors.New("unable to parse Ethereum address")
+	ErrPayoutUnsupported   = errors.New("smart contract does not support a separate payout address")
+	ErrEscrowUnsupported   = errors.New("smart contract does not support escrowed anti-spam fees")
+	ErrEscrowPending       = errors.New("escrowed anti-spam fee cannot be collected yet")
 	ErrLowBalance          = fmt.Errorf("Please deposit funds into the address listed above. "+
 		"A minimum of %s is needed to proceed.", FormatEther(minimumBalance))
 
@@ -58,11 +64,14 @@ var (
 	simulatedBalance   = new(big.Int).Mul(big.NewInt(100), oneEther)
 	simulatedGasLimit  = uint64(10000000)
 	minimumBalance     = big.NewInt(1e16) // 0.01 ETH
+	payoutVersion      = semver.MustParse("0.2.0")
+	escrowVersion      = semver.MustParse("0.3.0")
 )
 
 type (
 	GethBlockchain struct {
 		walletAddress  common.Address
+		payoutAddress  *common.Address
 		initialBalance *big.Int
 		retryingHub    retryinghub.RetryingHub
 	}
@@ -74,17 +83,26 @@ type (
 
 	Blockchain interface {
 		CheckSmartContract() error
-		CheckBalance() error
-		BurnAntiSpamFee(antiSpamID big.Int, antiSpamFee big.Int) error
+		CheckBalance(out *output.Output) error
+		BurnAntiSpamFee(antiSpamID big.Int, antiSpamFee big.Int) (*retryinghub.Receipt, error)
 		CheckAntiSpamConfirmations(antiSpamID big.Int, antiSpamFee big.Int) (int64, error)
-		DepositEther(recipient common.Address, adaptorPubKey ed25519.CurvePoint, ether big.Int, antiSpamID big.Int) error
+		CheckEscrowSupport() error
+		EscrowAntiSpamFee(antiSpamID big.Int, antiSpamFee big.Int, server common.Address) (*retryinghub.Receipt, error)
+		CheckEscrowConfirmations(antiSpamID big.Int, antiSpamFee big.Int, server common.Address) (int64, error)
+		CollectAntiSpamFee(antiSpamID big.Int) (*big.Int, error)
+		WithdrawCredit() (*big.Int, error)
+		DepositEther(recipient common.Address, adaptorPubKey ed25519.CurvePoint, ether big.Int, antiSpamID big.Int) (*retryinghub.Receipt, error)
 		CheckDepositConfirmations(recipient common.Address, adaptorPubKey ed25519.CurvePoint, ether big.Int, antiSpamID big.Int) (int64, error)
-		ClaimDeposit(adaptorPrivKey ed25519.Adaptor, antiSpamID big.Int) error
+		DepositExists(antiSpamID big.Int) (bool, error)
+		SetPayoutAddress(payoutAddress common.Address) error
+		ClaimDeposit(adaptorPrivKey ed25519.Adaptor, antiSpamID big.Int) (*retryinghub.Receipt, error)
 		LookupAdaptorPrivKey(adaptorPubKey ed25519.CurvePoint) (bool, *ed25519.Adaptor, error)
 		ReclaimDeposit(antiSpamID big.Int) error
 		RegisterServer(target string, cert []byte) error
 		FetchServers(maxAge big.Int) ([]ServerDetails, error)
 		WalletAddress() common.Address
+		Balance() (*big.Int, error)
+		Transfer(recipient common.Address, ether big.Int) error
 		SuggestGasPrice() (*big.Int, error)
 	}
 )
@@ -134,9 +152,16 @@ func NewGanacheBlockchain(contractAddress *common.Address) (*GethBlockchain, err
 }
 
 func NewSimulatedBlockchain() (*GethBlockchain, error) {
+	c, _, _, err := newSimulatedBlockchain()
+	return c, err
+}
+
+// newSimulatedBlockchain also returns the backend and the contract address,
+// so that tests can add further wallets and adjust the clock.
+func newSimulatedBlockchain() (*GethBlockchain, *backends.SimulatedBackend, *common.Address, error) {
 	privKeyECDSA, err := crypto.HexToECDSA(simulatedPrivKey)
 	if err != nil {
-		return nil, err
+		return nil, nil, nil, err
 	}
 	walletAddress := crypto.PubkeyToAddress(privKeyECDSA.PublicKey)
 
@@ -150,9 +175,9 @@ func NewSimulatedBlockchain() (*GethBlockchain, error) {
 	}()
 
 	auth := bind.NewKeyedTransactor(privKeyECDSA)
-	_, _, hub, err := contract.DeployHub(auth, backend)
+	contractAddress, _, hub, err := contract.DeployHub(auth, backend)
 	if err != nil {
-		return nil, err
+		return nil, nil, nil, err
 	}
 	backend.Commit()
 
@@ -164,7 +189,7 @@ func NewSimulatedBlockchain() (*GethBlockchain, error) {
 		initialBalance: simulatedBalance,
 		retryingHub:    retryingHub,
 	}
-	return &c, nil
+	return &c, backend, &contractAddress, nil
 }
 
 func NewLocalNodeBlockchain(endpoint string, keystoreFile string, contractAddress *common.Address,
@@ -241,9 +266,10 @@ func (c *GethBlockchain) CheckSmartContract() error {
 	return nil
 }
 
-func (c *GethBlockchain) CheckBalance() error {
-	fmt.Printf("Ethereum address: %s\n", c.walletAddress.String())
-	fmt.Printf("Ethereum balance: %s\n\n", FormatEther(c.initialBalance))
+func (c *GethBlockchain) CheckBalance(out *output.Output) error {
+	out.Printf("Ethereum address: %s\n", c.walletAddress.String())
+	out.Printf("Ethereum balance: %s\n\n", FormatEther(c.initialBalance))
+	out.Event("balance", output.Fields{"address": c.walletAddress.String(), "balance": c.initialBalance.String()})
 
 	if c.initialBalance.Cmp(minimumBalance) == -1 {
 		return ErrLowBalance
@@ -252,26 +278,102 @@ func (c *GethBlockchain) CheckBalance() error {
 	return nil
 }
 
-func (c *GethBlockchain) BurnAntiSpamFee(antiSpamID big.Int, antiSpamFee big.Int) error {
+// BurnAntiSpamFee returns a receipt for the burn transaction, if known. The
+// same applies to DepositEther and ClaimDeposit.
+func (c *GethBlockchain) BurnAntiSpamFee(antiSpamID big.Int, antiSpamFee big.Int) (*retryinghub.Receipt, error) {
 	hashedID := hash(antiSpamID)
-	c.retryingHub.BurnAntiSpamFee(hashedID, &antiSpamFee, smallGasLimit)
-	return nil
+	receipt := c.retryingHub.BurnAntiSpamFee(hashedID, &antiSpamFee, smallGasLimit)
+	return receipt, nil
 }
 
 func (c *GethBlockchain) CheckAntiSpamConfirmations(antiSpamID big.Int, antiSpamFee big.Int) (int64, error) {
 	confs := c.retryingHub.CheckAntiSpamConfirmations(&antiSpamID, &antiSpamFee)
 	return confs.Int64(), nil
 }
+
+func (c *GethBlockchain) CheckEscrowSupport() error {
+	supported, err := c.supports(escrowVersion)
+	if err != nil {
+		return err
+	}
+	if !supported {
+		return ErrEscrowUnsupported
+	}
+
+	return nil
+}
+
+// EscrowAntiSpamFee pays the anti-spam fee into escrow instead of burning it.
+// It is credited back to the wallet once the server claims the deposit with
+// the same ID. If the deposit is reclaimed instead, the fee is credited to
+// the server. Without a deposit the server can collect it after a while.
+func (c *GethBlockchain) EscrowAntiSpamFee(antiSpamID big.Int, antiSpamFee big.Int,
+	server common.Address) (*retryinghub.Receipt, error) {
+	err := c.CheckEscrowSupport()
+	if err != nil {
+		return nil, err
+	}
+
+	hashedID := hash(antiSpamID)
+	receipt := c.retryingHub.EscrowAntiSpamFee(hashedID, server, &antiSpamFee, mediumGasLimit)
+	return receipt, nil
+}
+
+func (c *GethBlockchain) CheckEscrowConfirmations(antiSpamID big.Int, antiSpamFee big.Int,
+	server common.Address) (int64, error) {
+	confs := c.retryingHub.CheckEscrowConfirmations(&antiSpamID, &antiSpamFee, server)
+	return confs.Int64(), nil
+}
+
+// CollectAntiSpamFee collects an anti-spam fee that was escrowed for us, if
+// no deposit followed. It returns the amount collected, which is zero if
+// there is nothing to collect, or ErrEscrowPending if it is too early.
+func (c *GethBlockchain) CollectAntiSpamFee(antiSpamID big.Int) (*big.Int, error) {
+	hashedID := hash(antiSpamID)
+	escrow := c.retryingHub.Escrow(hashedID)
+	if escrow.Server != c.walletAddress || escrow.Fee.Sign() == 0 || escrow.Deposit {
+		return big.NewInt(0), nil
+	}
+
+	if escrow.Deadline.Int64() >= time.Now().Unix() {
+		return nil, ErrEscrowPending
+	}
+
+	c.retryingHub.CollectAntiSpamFee(hashedID, big.NewInt(0), smallGasLimit)
+	return escrow.Fee, nil
+}
+
+// WithdrawCredit withdraws anti-spam fees that were credited back to the
+// wallet. It returns the amount withdrawn, which may be zero.
+func (c *GethBlockchain) WithdrawCredit() (*big.Int, error) {
+	supported, err := c.supports(escrowVersion)
+	if err != nil {
+		return nil, err
+	}
+	if !supported {
+		return big.NewInt(0), nil
+	}
+
+	credit := c.retryingHub.Credits(c.walletAddress)
+	if credit.Sign() == 0 {
+		return credit, nil
+	}
+
+	c.retryingHub.WithdrawCredit(big.NewInt(0), smallGasLimit)
+	return credit, nil
+}
+
 func (c *GethBlockchain) DepositEther(
-	recipient common.Address, adaptorPubKey ed25519.CurvePoint, ether big.Int, antiSpamID big.Int) error {
+	recipient common.Address, adaptorPubKey ed25519.CurvePoint, ether big.Int,
+	antiSpamID big.Int) (*retryinghub.Receipt, error) {
 	hashedID := hash(antiSpamID)
 
 	adaptorPubKeyBytes := switchEndianness(adaptorPubKey[:])
 	adaptorPubKeyBytes[0] &= 127 // clear sign bit
 	adaptorPubKeyBigInt := new(big.Int).SetBytes(adaptorPubKeyBytes)
 
-	c.retryingHub.DepositEther(recipient, adaptorPubKeyBigInt, hashedID, &ether, mediumGasLimit)
-	return nil
+	receipt := c.retryingHub.DepositEther(recipient, adaptorPubKeyBigInt, hashedID, &ether, mediumGasLimit)
+	return receipt, nil
 }
 
 func (c *GethBlockchain) CheckDepositConfirmations(
@@ -286,12 +388,41 @@ func (c *GethBlockchain) CheckDepositConfirmations(
 	return confs.Int64(), nil
 }
 
-func (c *GethBlockchain) ClaimDeposit(adaptorPrivKey ed25519.Adaptor, antiSpamID big.Int) error {
-	adaptorPrivKeyBigInt := new(big.Int).SetBytes(switchEndianness(adaptorPrivKey[:]))
-	c.retryingHub.ClaimDeposit(adaptorPrivKeyBigInt, &antiSpamID, big.NewInt(0), largeGasLimit)
+// DepositExists reports whether a deposit with the given anti-spam ID is
+// currently held by the contract, regardless of its recipient.
+func (c *GethBlockchain) DepositExists(antiSpamID big.Int) (bool, error) {
+	hashedID := hash(antiSpamID)
+	exists := c.retryingHub.DepositExists(hashedID)
+	return exists, nil
+}
+
+// SetPayoutAddress makes ClaimDeposit pay claimed deposits to the given
+// address instead of the wallet address, which then only pays for gas.
+func (c *GethBlockchain) SetPayoutAddress(payoutAddress common.Address) error {
+	supported, err := c.supports(payoutVersion)
+	if err != nil {
+		return err
+	}
+	if !supported {
+		return ErrPayoutUnsupported
+	}
+
+	c.payoutAddress = &payoutAddress
 	return nil
 }
 
+func (c *GethBlockchain) ClaimDeposit(adaptorPrivKey ed25519.Adaptor, antiSpamID big.Int) (*retryinghub.Receipt, error) {
+	adaptorPrivKeyBigInt := new(big.Int).SetBytes(switchEndianness(adaptorPrivKey[:]))
+	if c.payoutAddress != nil {
+		receipt := c.retryingHub.ClaimDepositTo(
+			adaptorPrivKeyBigInt, &antiSpamID, *c.payoutAddress, big.NewInt(0), largeGasLimit)
+		return receipt, nil
+	}
+
+	receipt := c.retryingHub.ClaimDeposit(adaptorPrivKeyBigInt, &antiSpamID, big.NewInt(0), largeGasLimit)
+	return receipt, nil
+}
+
 func (c *GethBlockchain) LookupAdaptorPrivKey(adaptorPubKey ed25519.CurvePoint) (bool, *ed25519.Adaptor, error) {
 	adaptorPubKeyBytes := switchEndianness(adaptorPubKey[:])
 	adaptorPubKeyBytes[0] &= 127 // clear sign bit
@@ -339,11 +470,32 @@ func (c *GethBlockchain) WalletAddress() common.Address {
 	return c.walletAddress
 }
 
+func (c *GethBlockchain) Balance() (*big.Int, error) {
+	balance := c.retryingHub.Balance()
+	return balance, nil
+}
+
+func (c *GethBlockchain) Transfer(recipient common.Address, ether big.Int) error {
+	c.retryingHub.Transfer(recipient, &ether, smallGasLimit)
+	return nil
+}
+
 func (c *GethBlockchain) SuggestGasPrice() (*big.Int, error) {
 	gasPrice := c.retryingHub.SuggestGasPrice()
 	return gasPrice, nil
 }
 
+// supports reports whether the smart contract is at least at the given
+// version.
+func (c *GethBlockchain) supports(version semver.Version) (bool, error) {
+	semVersion, err := semver.Make(c.retryingHub.Version())
+	if err != nil {
+		return false, err
+	}
+
+	return semVersion.GTE(version), nil
+}
+
 func switchEndianness(in []byte) []byte {
 	out := make([]byte, len(in))
 	for i := range in {
@@ -356,18 +508,47 @@ func hash(id big.Int) [32]byte {
 	return sha256.Sum256(math.PaddedBigBytes(&id, 32))
 }
 
+// ToEther converts an amount in wei to ether.
+func ToEther(wei *big.Int) *big.Rat {
+	return new(big.Rat).SetFrac(wei, oneEther)
+}
+
 func FormatEther(ether *big.Int) string {
-	r := new(big.Rat).SetFrac(ether, oneEther)
+	r := ToEther(ether)
 	return fmt.Sprintf("%s ETH", r.FloatString(formatEtherPrecision))
 }
 
+// FormatEtherExact formats an amount in ether with full precision and without
+// a unit, as needed for exported data.
+func FormatEtherExact(ether *big.Int) string {
+	return ToEther(ether).FloatString(18)
+}
+
 func FormatGwei(ether *big.Int) string {
 	r := new(big.Rat).SetFrac(ether, gwei)
 	return fmt.Sprintf("%s Gwei", r.FloatString(formatGweiPrecision))
 }
 
+func ParseEther(amount string) (*big.Int, error) {
+	etherRat, ok := new(big.Rat).SetString(amount)
+	if !ok || etherRat.Sign() == -1 {
+		return nil, ErrInvalidAmount
+	}
+
+	weiRat := new(big.Rat).Mul(etherRat, new(big.Rat).SetInt(oneEther))
+	wei := new(big.Int).Quo(weiRat.Num(), weiRat.Denom())
+	return wei, nil
+}
+
+func ParseAddress(address string) (common.Address, error) {
+	if !common.IsHexAddress(address) {
+		return common.Address{}, ErrInvalidAddress
+	}
+	return common.HexToAddress(address), nil
+}
+
 func ApplyRate(ether *big.Int, rate *big.Rat) *big.Rat {
-	etherRat := new(big.Rat).SetFrac(ether, oneEther)
+	etherRat := ToEther(ether)
 	result := new(big.Rat).Mul(etherRat, rate)
 	return result
 }
@@ -376,7 +557,7 @@ func ApplyRate(ether *big.Int, rate *big.Rat) *big.Rat {
 // Otherwise it will create a fresh key. The key will be 'encrypted' with an
 // empty password. This provides no protection, but will make the keystore
 // compatible with other Ethereum wallets.
-func EnsureKeystoreExists(path string) error {
+func EnsureKeystoreExists(path string, out *output.Output) error {
 	info, err := os.Stat(path)
 	if err != nil && os.IsExist(err) {
 		return err
@@ -387,11 +568,13 @@ func EnsureKeystoreExists(path string) error {
 	}
 
 	if info != nil {
-		fmt.Printf("Using Ethereum keystore %s\n", path)
+		out.Printf("Using Ethereum keystore %s\n", path)
+		out.Event("keystore", output.Fields{"path": path, "created": false})
 		return nil
 	}
 
-	fmt.Printf("Creating new Ethereum keystore %s\n", path)
+	out.Printf("Creating new Ethereum keystore %s\n", path)
+	out.Event("keystore", output.Fields{"path": path, "created": true})
 	err = os.MkdirAll(filepath.Dir(path), 0700)
 	if err != nil {
 		return err
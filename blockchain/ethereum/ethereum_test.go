@@ -1,11 +1,24 @@
 package ethereum
 
 import (
+	"context"
+	"crypto/rand"
+	"io/ioutil"
 	"math/big"
+	"regexp"
 	"testing"
+	"time"
 
+	"github.com/HyperspaceApp/ed25519"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind/backends"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	contract "github.com/javgh/roadie/contract/hub"
+	"github.com/javgh/roadie/contract/retryinghub"
 )
 
 var (
@@ -78,4 +91,323 @@ func TestEthereum(t *testing.T) {
 
 		assert.Equal(t, 0, len(serverDetails), "expected no server details")
 	})
+
+	t.Run("CanTransfer", func(t *testing.T) {
+		balanceBefore, err := ethChain.Balance()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = ethChain.Transfer(common.HexToAddress("0x44f1911Df3E915b21F385892B75E36002A859dF7"), *oneEther)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		balanceAfter, err := ethChain.Balance()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		spent := new(big.Int).Sub(balanceBefore, balanceAfter)
+		assert.True(t, spent.Cmp(oneEther) >= 0, "expected balance to drop by at least the amount sent")
+	})
+}
+
+func TestParseEther(t *testing.T) {
+	wei, err := ParseEther("1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, oneEther, wei, "expected one ether")
+
+	wei, err = ParseEther("0.001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, big.NewInt(1e15), wei, "expected fractional ether amount")
+
+	wei, err = ParseEther("0.0000000000000000001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 0, wei.Sign(), "expected amounts below one wei to be rounded down")
+
+	_, err = ParseEther("-1")
+	assert.Equal(t, ErrInvalidAmount, err, "expected negative amount to be rejected")
+
+	_, err = ParseEther("abc")
+	assert.Equal(t, ErrInvalidAmount, err, "expected invalid amount to be rejected")
+}
+
+func TestParseAddress(t *testing.T) {
+	address, err := ParseAddress("0x44f1911Df3E915b21F385892B75E36002A859dF7")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "0x44f1911Df3E915b21F385892B75E36002A859dF7", address.String())
+
+	_, err = ParseAddress("0x44f1911Df3E915b21F385892B75E36002A859d")
+	assert.Equal(t, ErrInvalidAddress, err, "expected short address to be rejected")
+}
+
+// newSimulatedWallet funds a fresh wallet and connects it to the contract of
+// the given simulated blockchain.
+func newSimulatedWallet(t *testing.T, ethChain *GethBlockchain, backend *backends.SimulatedBackend,
+	contractAddress common.Address) *GethBlockchain {
+	privKeyECDSA, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	walletAddress := crypto.PubkeyToAddress(privKeyECDSA.PublicKey)
+
+	err = ethChain.Transfer(walletAddress, *oneEther)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hub, err := contract.NewHub(contractAddress, backend)
+	if err != nil {
+		t.Fatal(err)
+	}
+	retryingHub := retryinghub.New(
+		*ganacheMaxGasPrice, ganacheBoostInterval, ganacheTxCheckInterval, backend, *privKeyECDSA, walletAddress, hub)
+
+	return &GethBlockchain{
+		walletAddress:  walletAddress,
+		initialBalance: oneEther,
+		retryingHub:    retryingHub,
+	}
+}
+
+func TestDeposits(t *testing.T) {
+	server, backend, contractAddress, err := newSimulatedBlockchain()
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := newSimulatedWallet(t, server, backend, *contractAddress)
+
+	antiSpamFee := big.NewInt(1e15)
+	deposit := big.NewInt(1e16)
+
+	t.Run("CanClaimToPayoutAddress", func(t *testing.T) {
+		antiSpamID, err := rand.Int(rand.Reader, math.MaxBig256)
+		if err != nil {
+			t.Fatal(err)
+		}
+		adaptorPrivKey, adaptorPubKey, err := ed25519.GenerateAdaptor(rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = client.BurnAntiSpamFee(*antiSpamID, *antiSpamFee)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = client.DepositEther(server.WalletAddress(), adaptorPubKey, *deposit, *antiSpamID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		payoutAddress := common.HexToAddress("0x7e0f2C4A8e0EE5dB9b1bA0b1dC3E3E5F0a1a7e57")
+		err = server.SetPayoutAddress(payoutAddress)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { server.payoutAddress = nil }()
+
+		_, err = server.ClaimDeposit(adaptorPrivKey, *antiSpamID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		payout, err := backend.BalanceAt(context.Background(), payoutAddress, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, deposit, payout, "expected deposit at payout address")
+
+		ok, revealed, err := client.LookupAdaptorPrivKey(adaptorPubKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		require.True(t, ok, "expected adaptor private key to be revealed")
+		assert.Equal(t, adaptorPrivKey, *revealed)
+	})
+}
+
+func TestEscrow(t *testing.T) {
+	server, backend, contractAddress, err := newSimulatedBlockchain()
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := newSimulatedWallet(t, server, backend, *contractAddress)
+
+	err = client.CheckEscrowSupport()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	antiSpamFee := big.NewInt(1e15)
+	deposit := big.NewInt(1e16)
+
+	escrow := func(t *testing.T) *big.Int {
+		antiSpamID, err := rand.Int(rand.Reader, math.MaxBig256)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = client.EscrowAntiSpamFee(*antiSpamID, *antiSpamFee, server.WalletAddress())
+		if err != nil {
+			t.Fatal(err)
+		}
+		backend.Commit()
+
+		confs, err := server.CheckEscrowConfirmations(*antiSpamID, *antiSpamFee, server.WalletAddress())
+		if err != nil {
+			t.Fatal(err)
+		}
+		require.True(t, confs > 0, "expected escrow to be confirmed")
+		return antiSpamID
+	}
+
+	credits := func(wallet *GethBlockchain) *big.Int {
+		return wallet.retryingHub.Credits(wallet.WalletAddress())
+	}
+
+	t.Run("RefundsFeeOnClaim", func(t *testing.T) {
+		antiSpamID := escrow(t)
+		adaptorPrivKey, adaptorPubKey, err := ed25519.GenerateAdaptor(rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = client.DepositEther(server.WalletAddress(), adaptorPubKey, *deposit, *antiSpamID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = server.ClaimDeposit(adaptorPrivKey, *antiSpamID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, antiSpamFee, credits(client), "expected fee to be credited to client")
+
+		withdrawn, err := client.WithdrawCredit()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, antiSpamFee, withdrawn)
+		assert.Equal(t, 0, credits(client).Sign(), "expected credit to be withdrawn")
+	})
+
+	t.Run("DoesNotRefundFeeForOwnDeposit", func(t *testing.T) {
+		antiSpamID := escrow(t)
+		adaptorPrivKey, adaptorPubKey, err := ed25519.GenerateAdaptor(rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = client.DepositEther(client.WalletAddress(), adaptorPubKey, *deposit, *antiSpamID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = client.ClaimDeposit(adaptorPrivKey, *antiSpamID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, 0, credits(client).Sign(), "expected no refund for deposit to oneself")
+		assert.Equal(t, antiSpamFee, credits(server), "expected fee to be credited to server")
+
+		withdrawn, err := server.WithdrawCredit()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, antiSpamFee, withdrawn)
+	})
+
+	t.Run("DoesNotRefundFeeOnReclaim", func(t *testing.T) {
+		antiSpamID := escrow(t)
+		_, adaptorPubKey, err := ed25519.GenerateAdaptor(rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = client.DepositEther(server.WalletAddress(), adaptorPubKey, *deposit, *antiSpamID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = backend.AdjustTime(3 * time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		backend.Commit()
+
+		err = client.ReclaimDeposit(*antiSpamID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		exists, err := client.DepositExists(*antiSpamID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.False(t, exists, "expected deposit to be reclaimed")
+		assert.Equal(t, 0, credits(client).Sign(), "expected no refund for reclaimed deposit")
+		assert.Equal(t, antiSpamFee, credits(server), "expected fee to be credited to server")
+
+		_, err = server.WithdrawCredit()
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("CanCollectFeeAfterDeadline", func(t *testing.T) {
+		antiSpamID := escrow(t)
+
+		err := backend.AdjustTime(7 * time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		backend.Commit()
+
+		balanceBefore, err := server.Balance()
+		if err != nil {
+			t.Fatal(err)
+		}
+		collected, err := server.CollectAntiSpamFee(*antiSpamID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, antiSpamFee, collected)
+
+		balanceAfter, err := server.Balance()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.True(t, balanceAfter.Cmp(balanceBefore) > 0, "expected fee to be paid out")
+
+		collected, err = server.CollectAntiSpamFee(*antiSpamID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, 0, collected.Sign(), "expected nothing left to collect")
+	})
+}
+
+func TestContractMatchesSource(t *testing.T) {
+	source, err := ioutil.ReadFile("../../contract/hub/Hub.sol")
+	if err != nil {
+		t.Fatal(err)
+	}
+	match := regexp.MustCompile(`string public version = "([^"]*)";`).FindSubmatch(source)
+	require.NotNil(t, match, "expected Hub.sol to declare a version")
+
+	ethChain, err := NewSimulatedBlockchain()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Hub.bin is generated from Hub.sol and has to be rebuilt with every
+	// change to the contract, or the bindings will call functions the
+	// deployed bytecode does not have.
+	assert.Equal(t, string(match[1]), ethChain.retryingHub.Version(),
+		"expected deployed contract to be built from Hub.sol")
 }
@@ -3,8 +3,8 @@ package sia
 import (
 	"encoding/base64"
 	"errors"
-	"fmt"
 	"io/ioutil"
+	"log"
 	"math/big"
 	"time"
 
@@ -49,6 +49,8 @@ const (
 var (
 	ErrWalletLocked      = errors.New("wallet is locked")
 	ErrInsufficientFunds = errors.New("insufficient funds")
+	ErrInvalidAmount     = errors.New("unable to parse siacoin amount")
+	ErrInvalidAddress    = errors.New("unable to parse Sia address")
 )
 
 func NewSimulatedBlockchain() (*HTTPAPIBlockchain, error) {
@@ -194,7 +196,7 @@ func (c *DryRunBlockchain) WalletSign(tx types.Transaction) (*types.Transaction,
 }
 
 func (c *DryRunBlockchain) BroadcastTransaction(tx types.Transaction) error {
-	fmt.Printf("Skipping broadcast for: %s\n", EncodeTransaction(tx))
+	log.Printf("Skipping broadcast for: %s\n", EncodeTransaction(tx))
 	return nil
 }
 
@@ -309,8 +311,40 @@ func EncodeTransaction(tx types.Transaction) string {
 	return base64.StdEncoding.EncodeToString(encoding.Marshal(tx))
 }
 
+// ToSiacoin converts an amount in hastings to siacoins.
+func ToSiacoin(siacoin types.Currency) *big.Rat {
+	return new(big.Rat).SetFrac(siacoin.Big(), types.SiacoinPrecision.Big())
+}
+
+// FormatSiacoinExact formats an amount in siacoins with full precision and
+// without a unit, as needed for exported data.
+func FormatSiacoinExact(siacoin types.Currency) string {
+	return ToSiacoin(siacoin).FloatString(24)
+}
+
 func ApplyRate(siacoin types.Currency, rate *big.Rat) *big.Rat {
-	siacoinRat := new(big.Rat).SetFrac(siacoin.Big(), types.SiacoinPrecision.Big())
+	siacoinRat := ToSiacoin(siacoin)
 	result := new(big.Rat).Mul(siacoinRat, rate)
 	return result
 }
+
+func ParseSiacoin(amount string) (types.Currency, error) {
+	siacoinRat, ok := new(big.Rat).SetString(amount)
+	if !ok || siacoinRat.Sign() == -1 {
+		return types.ZeroCurrency, ErrInvalidAmount
+	}
+
+	hastingsRat := new(big.Rat).Mul(siacoinRat, new(big.Rat).SetInt(types.SiacoinPrecision.Big()))
+	hastings := new(big.Int).Quo(hastingsRat.Num(), hastingsRat.Denom())
+	return types.NewCurrency(hastings), nil
+}
+
+// ParseAddress parses a Sia address, including its checksum.
+func ParseAddress(address string) (types.UnlockHash, error) {
+	var unlockHash types.UnlockHash
+	err := unlockHash.LoadString(address)
+	if err != nil {
+		return types.UnlockHash{}, ErrInvalidAddress
+	}
+	return unlockHash, nil
+}
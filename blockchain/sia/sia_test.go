@@ -0,0 +1,47 @@
+package sia
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gitlab.com/NebulousLabs/Sia/types"
+)
+
+func TestParseSiacoin(t *testing.T) {
+	siacoin, err := ParseSiacoin("1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, types.SiacoinPrecision, siacoin, "expected one siacoin")
+
+	siacoin, err = ParseSiacoin("2.5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, types.SiacoinPrecision.MulFloat(2.5), siacoin, "expected fractional siacoin amount")
+
+	_, err = ParseSiacoin("-1")
+	assert.Equal(t, ErrInvalidAmount, err, "expected negative amount to be rejected")
+
+	_, err = ParseSiacoin("1 SC")
+	assert.Equal(t, ErrInvalidAmount, err, "expected invalid amount to be rejected")
+}
+
+func TestParseAddress(t *testing.T) {
+	unlockHash := types.UnlockConditions{SignaturesRequired: 1}.UnlockHash()
+	address := unlockHash.String()
+
+	parsed, err := ParseAddress(address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, unlockHash, parsed, "expected address to round-trip")
+
+	corrupted := []byte(address)
+	corrupted[len(corrupted)-1] ^= 1
+	_, err = ParseAddress(string(corrupted))
+	assert.Equal(t, ErrInvalidAddress, err, "expected bad checksum to be rejected")
+
+	_, err = ParseAddress(address[1:])
+	assert.Equal(t, ErrInvalidAddress, err, "expected short address to be rejected")
+}
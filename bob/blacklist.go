@@ -0,0 +1,173 @@
+package bob
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"log"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/patrickmn/go-cache"
+)
+
+type (
+	// Blacklist remembers anti-spam IDs that were already used for a binding
+	// offer. If it is backed by a file, entries survive restarts and the file
+	// can be shared by several servers using the same wallet: it is locked
+	// while being read or appended to, and entries written by other servers
+	// are picked up before every check.
+	Blacklist struct {
+		mutex  sync.Mutex
+		cache  *cache.Cache
+		path   string // empty if entries are only kept in memory
+		offset int64  // how much of the file has been read already
+	}
+
+	blacklistEntry struct {
+		ID   big.Int
+		Time time.Time
+	}
+)
+
+func NewBlacklist() *Blacklist {
+	c := cache.New(blacklistExpiration, blacklistInterval)
+	return &Blacklist{cache: c}
+}
+
+// NewPersistentBlacklist returns a blacklist backed by the file at path, one
+// JSON object per line. As with NewBlacklist, entries expire after
+// blacklistExpiration; older entries in the file are ignored.
+func NewPersistentBlacklist(path string) (*Blacklist, error) {
+	c := cache.New(blacklistExpiration, blacklistInterval)
+	b := Blacklist{cache: c, path: path}
+
+	err := b.withFile(false, b.refresh)
+	if err != nil {
+		return nil, err
+	}
+
+	return &b, nil
+}
+
+func (b *Blacklist) contains(id big.Int) (bool, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.path != "" {
+		err := b.withFile(false, b.refresh)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	_, ok := b.cache.Get(id.String())
+	return ok, nil
+}
+
+// add records the ID or returns ErrAntiSpamReused if it is already known,
+// possibly because another server has just added it.
+func (b *Blacklist) add(id big.Int, now time.Time) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.path == "" {
+		if _, ok := b.cache.Get(id.String()); ok {
+			return ErrAntiSpamReused
+		}
+		b.cache.Set(id.String(), true, cache.DefaultExpiration)
+		return nil
+	}
+
+	return b.withFile(true, func(file *os.File) error {
+		err := b.refresh(file)
+		if err != nil {
+			return err
+		}
+
+		if _, ok := b.cache.Get(id.String()); ok {
+			return ErrAntiSpamReused
+		}
+
+		data, err := json.Marshal(&blacklistEntry{ID: id, Time: now})
+		if err != nil {
+			return err
+		}
+
+		info, err := file.Stat()
+		if err != nil {
+			return err
+		}
+		if info.Size() != b.offset { // terminate incomplete line left by an earlier crash
+			data = append([]byte{'\n'}, data...)
+		}
+
+		_, err = file.Write(append(data, '\n'))
+		if err != nil {
+			return err
+		}
+
+		b.cache.Set(id.String(), true, cache.DefaultExpiration)
+		return nil
+	})
+}
+
+// withFile opens and locks the file, shared or exclusively, and passes it to
+// fn. Writes always append to the end of the file.
+func (b *Blacklist) withFile(exclusive bool, fn func(file *os.File) error) error {
+	err := os.MkdirAll(filepath.Dir(b.path), 0700)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(b.path, os.O_APPEND|os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	err = lockFile(file, exclusive)
+	if err != nil {
+		return err
+	}
+	defer unlockFile(file)
+
+	return fn(file)
+}
+
+// refresh reads the entries added since the last call. An incomplete last
+// line is left for later; lines that cannot be parsed and expired entries are
+// skipped.
+func (b *Blacklist) refresh(file *os.File) error {
+	_, err := file.Seek(b.offset, io.SeekStart)
+	if err != nil {
+		return err
+	}
+
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		b.offset += int64(len(line))
+
+		var entry blacklistEntry
+		err = json.Unmarshal(line, &entry)
+		if err != nil {
+			log.Printf("Skipping invalid line in blacklist %s: %s\n", b.path, err)
+			continue
+		}
+
+		expiration := time.Until(entry.Time.Add(blacklistExpiration))
+		if expiration <= 0 {
+			continue
+		}
+		b.cache.Set(entry.ID.String(), true, expiration)
+	}
+}
@@ -0,0 +1,91 @@
+package bob
+
+import (
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPersistentBlacklist(t *testing.T) {
+	dir, err := ioutil.TempDir("", "roadie-blacklist")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "blacklist.jsonl")
+	now := time.Now()
+
+	first, err := NewPersistentBlacklist(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := NewPersistentBlacklist(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = first.add(*big.NewInt(1), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, ErrAntiSpamReused, first.add(*big.NewInt(1), now))
+
+	// shared with other servers using the same file
+	reused, err := second.contains(*big.NewInt(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, reused, "should see ID added by other server")
+	assert.Equal(t, ErrAntiSpamReused, second.add(*big.NewInt(1), now))
+
+	// survives a restart, even after a crash in the middle of a write
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = file.WriteString(`{"ID":3,"Ti`)
+	file.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	restarted, err := NewPersistentBlacklist(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = restarted.add(*big.NewInt(2), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = restarted.add(*big.NewInt(4), now.Add(-blacklistExpiration-time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	restarted, err = NewPersistentBlacklist(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []int64{1, 2} {
+		reused, err = restarted.contains(*big.NewInt(id))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.True(t, reused, "should remember ID %d", id)
+	}
+	reused, err = restarted.contains(*big.NewInt(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.False(t, reused, "should skip incomplete entry")
+	reused, err = restarted.contains(*big.NewInt(4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.False(t, reused, "should drop expired entry")
+}
@@ -9,7 +9,6 @@ import (
 	"github.com/HyperspaceApp/ed25519"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/google/uuid"
-	"github.com/patrickmn/go-cache"
 	"gitlab.com/NebulousLabs/Sia/types"
 
 	"github.com/javgh/roadie/blockchain/ethereum"
@@ -21,9 +20,13 @@ import (
 type (
 	state int
 
+	// Outcome describes what became of the binding offer of a swap.
+	Outcome int
+
 	AtomicSwap struct {
 		ID             uuid.UUID
 		state          state
+		created        time.Time
 		deadline       time.Time
 		siacoin        types.Currency
 		ether          big.Int
@@ -37,14 +40,15 @@ type (
 		refundTx       types.Transaction
 		adaptorPrivKey ed25519.Adaptor
 		adaptorPubKey  ed25519.CurvePoint
+		claimFee       *big.Int
+		boundOffer     bool
+		escrow         bool // whether anti-spam fees should be escrowed for us
+		escrowed       bool // whether the anti-spam fee of the binding offer was escrowed
+		collectedFee   *big.Int
 		trader         trader.Trader
 		ethChain       ethereum.Blockchain
 		siaChain       sia.Blockchain
-		blacklist      Blacklist
-	}
-
-	Blacklist struct {
-		cache *cache.Cache
+		blacklist      *Blacklist
 	}
 
 	RefundDetails struct {
@@ -55,6 +59,23 @@ type (
 		BobRefundNoncePoint ed25519.CurvePoint
 	}
 
+	// Snapshot is a read-only copy of the most important details of a swap,
+	// for monitoring purposes.
+	Snapshot struct {
+		ID          uuid.UUID
+		State       string
+		Completed   bool
+		Deadline    time.Time
+		Siacoin     types.Currency
+		MinerFees   types.Currency
+		Ether       big.Int
+		AntiSpamFee big.Int
+		AntiSpamID  big.Int
+		ClaimFee    *big.Int // nil if unknown
+		Escrowed    bool
+		Collected   *big.Int // escrowed anti-spam fee collected after a failed swap, nil if none
+	}
+
 	AdaptorDetails struct {
 		BobClaimNoncePoint ed25519.CurvePoint
 		AdaptorPubKey      ed25519.CurvePoint
@@ -79,6 +100,13 @@ const (
 	depositConfirmations  = 8
 )
 
+const (
+	OutcomeNone Outcome = iota // no binding offer made
+	OutcomePending
+	OutcomeSucceeded
+	OutcomeFailed
+)
+
 var (
 	ErrWrongState          = errors.New("atomic swap is in a state where this action is not permitted")
 	ErrOfferExpired        = errors.New("offer has expired")
@@ -93,27 +121,14 @@ var (
 	blacklistInterval, _   = time.ParseDuration("1h")
 )
 
-func NewBlacklist() Blacklist {
-	c := cache.New(blacklistExpiration, blacklistInterval)
-	return Blacklist{cache: c}
-}
-
-func (b *Blacklist) add(id big.Int) {
-	b.cache.Set(id.String(), true, cache.DefaultExpiration)
-}
-
-func (b *Blacklist) contains(id big.Int) bool {
-	_, ok := b.cache.Get(id.String())
-	return ok
-}
-
 func NewAtomicSwap(trader trader.Trader, ethChain ethereum.Blockchain, siaChain sia.Blockchain,
-	blacklist Blacklist, now time.Time) *AtomicSwap {
+	blacklist *Blacklist, now time.Time) *AtomicSwap {
 	id := uuid.Must(uuid.NewRandom())
 	deadline := now.Add(atomicSwapLifetime)
 	atomicSwap := AtomicSwap{
 		ID:        id,
 		state:     stateInitialized,
+		created:   now,
 		deadline:  deadline,
 		trader:    trader,
 		ethChain:  ethChain,
@@ -123,6 +138,15 @@ func NewAtomicSwap(trader trader.Trader, ethChain ethereum.Blockchain, siaChain
 	return &atomicSwap
 }
 
+// EnableEscrow makes offers ask for the anti-spam fee to be escrowed for our
+// wallet rather than burned. It is credited back to Alice if the swap
+// completes, credited to us if she reclaims her deposit and collected by
+// Check if there is no deposit. Fees that are burned anyway are
+// still accepted.
+func (s *AtomicSwap) EnableEscrow() {
+	s.escrow = true
+}
+
 func (s *AtomicSwap) RequestNonBindingOffer(siacoin types.Currency, now time.Time) (*trader.Offer, error) {
 	if s.state != stateInitialized {
 		return nil, ErrWrongState
@@ -133,12 +157,36 @@ func (s *AtomicSwap) RequestNonBindingOffer(siacoin types.Currency, now time.Tim
 		return nil, err
 	}
 
+	if s.escrow {
+		walletAddress := s.ethChain.WalletAddress()
+		offer.Escrow = &walletAddress
+	}
+
 	s.siacoin = siacoin
 	s.antiSpamFee = offer.AntiSpamFee
 	s.state = stateMadeNonBindingOffer
 	return offer, nil
 }
 
+func (s *AtomicSwap) RequestNonBindingOfferForEther(ether big.Int,
+	now time.Time) (*types.Currency, *trader.Offer, error) {
+	if s.state != stateInitialized {
+		return nil, nil, ErrWrongState
+	}
+
+	siacoin, err := s.trader.CalculateSiacoin(ether, defaultMinerFee, now)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	offer, err := s.RequestNonBindingOffer(*siacoin, now)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return siacoin, offer, nil
+}
+
 func (s *AtomicSwap) RequestBindingOffer(antiSpamID big.Int, now time.Time) (*trader.Offer, error) {
 	if s.state != stateMadeNonBindingOffer {
 		return nil, ErrWrongState
@@ -153,23 +201,58 @@ func (s *AtomicSwap) RequestBindingOffer(antiSpamID big.Int, now time.Time) (*tr
 		return offer, nil
 	}
 
-	if s.blacklist.contains(antiSpamID) {
+	reused, err := s.blacklist.contains(antiSpamID)
+	if err != nil {
+		return nil, err
+	}
+	if reused {
 		return nil, ErrAntiSpamReused
 	}
 
-	confs, err := s.ethChain.CheckAntiSpamConfirmations(antiSpamID, s.antiSpamFee)
+	// fees of swaps that reached a deposit can no longer be used, as the
+	// contract forgets about them once the deposit is claimed or reclaimed
+	deposited, err := s.ethChain.DepositExists(antiSpamID)
 	if err != nil {
 		return nil, err
 	}
+	if deposited {
+		return nil, ErrAntiSpamReused
+	}
+
+	escrowed := false
+	if s.escrow {
+		confs, err := s.ethChain.CheckEscrowConfirmations(antiSpamID, s.antiSpamFee, s.ethChain.WalletAddress())
+		if err != nil {
+			return nil, err
+		}
+		escrowed = confs >= antiSpamConfirmations
+	}
 
-	if confs < antiSpamConfirmations {
-		return nil, ErrAntiSpamNotDetected
+	if !escrowed {
+		confs, err := s.ethChain.CheckAntiSpamConfirmations(antiSpamID, s.antiSpamFee)
+		if err != nil {
+			return nil, err
+		}
+
+		if confs < antiSpamConfirmations {
+			return nil, ErrAntiSpamNotDetected
+		}
 	}
 
-	s.blacklist.add(antiSpamID)
+	err = s.blacklist.add(antiSpamID, now)
+	if err != nil {
+		return nil, err
+	}
 	s.trader.PauseOrderPreparation(now)
 
+	offer.AntiSpamFee = s.antiSpamFee // the fee that was actually burned or escrowed
+	if escrowed {
+		walletAddress := s.ethChain.WalletAddress()
+		offer.Escrow = &walletAddress
+	}
 	s.ether = offer.Ether
+	s.boundOffer = true
+	s.escrowed = escrowed
 	s.antiSpamID = antiSpamID
 	s.deadline = *deadline
 	s.state = stateMadeBindingOffer
@@ -347,10 +430,13 @@ func (s *AtomicSwap) AnnounceDeposit() error {
 		return ErrInvalidDeposit
 	}
 
-	err = s.ethChain.ClaimDeposit(s.adaptorPrivKey, s.antiSpamID)
+	receipt, err := s.ethChain.ClaimDeposit(s.adaptorPrivKey, s.antiSpamID)
 	if err != nil {
 		return err
 	}
+	if receipt != nil {
+		s.claimFee = &receipt.Fee
+	}
 
 	s.state = stateCompleted
 	return nil
@@ -376,6 +462,18 @@ func (s *AtomicSwap) Check(now time.Time) (noLongerNeeded bool, maybeRefundTxID
 		noLongerNeeded = true
 	}
 
+	if noLongerNeeded && s.escrowed && s.state != stateCompleted {
+		// collect the escrowed anti-spam fee; if Alice made a deposit, the fee
+		// is credited to us once she reclaims it instead
+		s.collectedFee, err = s.ethChain.CollectAntiSpamFee(s.antiSpamID)
+		if err == ethereum.ErrEscrowPending {
+			return false, maybeRefundTxID, nil
+		} else if err != nil {
+			return false, maybeRefundTxID, err
+		}
+		s.escrowed = false
+	}
+
 	return noLongerNeeded, maybeRefundTxID, nil
 }
 
@@ -402,6 +500,59 @@ func (s *AtomicSwap) StateText() string {
 	}
 }
 
+func (s *AtomicSwap) Snapshot() Snapshot {
+	return Snapshot{
+		ID:          s.ID,
+		State:       s.StateText(),
+		Completed:   s.state == stateCompleted,
+		Deadline:    s.deadline,
+		Siacoin:     s.siacoin,
+		MinerFees:   defaultMinerFee.Mul64(2), // funding transaction and claim transaction
+		Ether:       s.ether,
+		AntiSpamFee: s.antiSpamFee,
+		AntiSpamID:  s.antiSpamID,
+		ClaimFee:    s.claimFee,
+		Escrowed:    s.escrowed || s.collectedFee != nil,
+		Collected:   s.collectedFee,
+	}
+}
+
+// BindingOfferOutcome reports whether a binding offer was made and, if so,
+// whether it led to a completed swap. Offers that expired or were refunded
+// count as failed.
+func (s *AtomicSwap) BindingOfferOutcome() Outcome {
+	if !s.boundOffer {
+		return OutcomeNone
+	}
+
+	switch s.state {
+	case stateCompleted:
+		return OutcomeSucceeded
+	case stateAborted, stateRefunded:
+		return OutcomeFailed
+	default:
+		return OutcomePending
+	}
+}
+
+// AwaitingBindingOffer reports whether no binding offer has been made yet.
+// Such swaps cost the server nothing but memory and can be dropped early.
+func (s *AtomicSwap) AwaitingBindingOffer() bool {
+	return s.state == stateInitialized || s.state == stateMadeNonBindingOffer
+}
+
+// Stale reports whether the swap has been awaiting a binding offer for longer
+// than timeout.
+func (s *AtomicSwap) Stale(now time.Time, timeout time.Duration) bool {
+	return s.AwaitingBindingOffer() && now.Sub(s.created) > timeout
+}
+
+// ClaimPending reports whether Alice might still announce a deposit that we
+// would then claim.
+func (s *AtomicSwap) ClaimPending() bool {
+	return s.state == stateProvidedAdaptorDetails
+}
+
 func (s *AtomicSwap) EncodedRefundTransaction() (string, bool) {
 	if s.state == stateFunded || s.state == stateProvidedAdaptorDetails ||
 		s.state == stateCompleted || s.state == stateRefunded {
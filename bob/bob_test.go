@@ -54,7 +54,7 @@ func TestAtomicSwap(t *testing.T) {
 		assert.True(t, nonBindingOffer2.Available, "should receive non-binding offer")
 
 		antiSpamID := big.NewInt(0)
-		err = ethChain.BurnAntiSpamFee(*antiSpamID, nonBindingOffer1.AntiSpamFee)
+		_, err = ethChain.BurnAntiSpamFee(*antiSpamID, nonBindingOffer1.AntiSpamFee)
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -72,6 +72,8 @@ func TestAtomicSwap(t *testing.T) {
 		}
 		assert.False(t, bindingOffer2.Available, "should not have multiple binding offers in parallel")
 		assert.Contains(t, bindingOffer2.Msg, "critical phase", "should inform of critical phase")
+		assert.Equal(t, OutcomePending, swap1.BindingOfferOutcome())
+		assert.Equal(t, OutcomeNone, swap2.BindingOfferOutcome())
 
 		later := now.Add(2 * bindingOfferLifetime)
 		_, err = swap2.RequestBindingOffer(*antiSpamID, later)
@@ -7,28 +7,44 @@ import (
 	"math/big"
 	"os"
 	"os/signal"
-	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/spf13/cobra"
 	"gitlab.com/NebulousLabs/Sia/types"
+	"google.golang.org/grpc/status"
 
 	"github.com/javgh/roadie/alice"
+	"github.com/javgh/roadie/backtest"
 	"github.com/javgh/roadie/blockchain/ethereum"
 	"github.com/javgh/roadie/blockchain/sia"
 	"github.com/javgh/roadie/bob"
 	"github.com/javgh/roadie/config"
 	"github.com/javgh/roadie/frontend"
+	"github.com/javgh/roadie/history"
+	"github.com/javgh/roadie/ledger"
+	"github.com/javgh/roadie/output"
 	"github.com/javgh/roadie/rpc"
+	"github.com/javgh/roadie/scheduler"
 	"github.com/javgh/roadie/trader"
+	"github.com/javgh/roadie/tui"
+	"github.com/javgh/roadie/webhook"
 )
 
 const (
 	serverNetwork         = "tcp"
 	registryCheckInterval = 12 * time.Hour
 	serverCheckInterval   = time.Hour
+	serverViewInterval    = 2 * time.Second
+	serverViewLogLines    = 10
+
+	quoteSampleInterval = 5 * time.Minute
+	quoteWindow         = 6 * time.Hour
+
+	strategyFixed     = "fixed"
+	strategyInventory = "inventory"
 )
 
 var (
@@ -40,6 +56,11 @@ var (
 	useGanache            = false
 	serverAddress         = "localhost:9979"
 	externalAddress       = "localhost:9979"
+	payoutAddressHex      = ""
+	sweepAddressHex       = ""
+	sweepReserve          = "0.05"
+	sweepMinimum          = "0.01"
+	sweepInterval         = time.Hour
 	certFile              = ""
 	keyFile               = ""
 	jsonRPCEndpoint       = config.PrependHomeDirectory(".ethereum/geth.ipc")
@@ -47,10 +68,62 @@ var (
 	maxGasPriceInGwei     = int64(21)
 	boostIntervalSeconds  = int64(90)
 	useExchangeRate       = false
+	pickOffer             = false
+	useTUI                = false
 	similarityPercentage  = int64(1)
 	absDiffRule           = float64(0)
 	relDiffRule           = float64(0)
+	rules                 = ""
+	bindingTolerance      = int64(frontend.NoBindingTolerance)
+	approvalCommand       = ""
 	maxAntiSpamFeeInEther = float64(0.001)
+	etherBudget           = false
+	siaAddress            = ""
+	scheduleFile          = config.PrependConfigDirectory("schedule.json")
+	ledgerFile            = config.PrependConfigDirectory("ledger.jsonl")
+	blacklistFile         = config.PrependConfigDirectory("blacklist.jsonl")
+	historyFile           = config.PrependConfigDirectory("history.jsonl")
+	exportFormat          = ""
+	webhookURLs           = []string{}
+	traderPlugin          = ""
+	strategy              = strategyFixed
+	premiumPercentage     = "1"
+	inventoryPremium      = "5"
+	volumePremium         = "0"
+	priceFloor            = ""
+	priceCeiling          = ""
+	maxSwap               = ""
+	dailyCap              = ""
+	quoteSize             = "1000"
+	volatilityFactor      = float64(1)
+	antiSpamFee           = "0.0001"
+	antiSpamFeePerSiacoin = "0"
+	antiSpamLoadFactor    = "0"
+	antiSpamFailureFactor = "0"
+	antiSpamMaxFee        = ""
+	antiSpamEscrow        = false
+	rateLimit             = float64(30)
+	rateLimitGlobal       = float64(600)
+	maxPendingSwaps       = 1000
+	offerTimeout          = 30 * time.Minute
+	quoteCacheDuration    = 10 * time.Second
+	ratesFile             = ""
+	requestsFile          = ""
+	requestsPerHour       = float64(1)
+	minRequest            = "1000"
+	maxRequest            = "100000"
+	seed                  = int64(1)
+	backtestBalance       = "1000000"
+	gasPriceInGwei        = int64(2)
+	buyerTolerance        = "5"
+	swapDuration          = 2 * time.Hour
+	fundingDuration       = 10 * time.Minute
+	webhookSecretFile     = ""
+	csvExport             = false
+	scheduleInterval      = 7 * 24 * time.Hour
+	maxTotalEther         = ""
+	outputFormat          = "text"
+	out                   = output.Stdout
 
 	gwei                          = big.NewInt(1e9)
 	ether                         = big.NewInt(1e18)
@@ -59,8 +132,236 @@ var (
 	registryEntryMaxAgeWithMargin = big.NewInt(15 * 24 * 60 * 60) // 15 days in seconds
 
 	errParsingFailed = errors.New("unable to parse id")
+	errTUIWithJSON   = errors.New("full-screen mode is not available with JSON output")
+
+	errEmptyTraderPlugin = errors.New("empty trader plugin command")
+	errUnknownStrategy   = errors.New("unknown pricing strategy - expected 'fixed' or 'inventory'")
+	errInvalidPercentage = errors.New("unable to parse percentage")
+	errInvalidPrice      = errors.New("unable to parse USD price")
+	errNoRatesFile       = errors.New("no rate series given - use --rates")
+	errPluginBacktest    = errors.New("pricing plugins cannot be backtested")
+
+	errorCodes = map[error]string{
+		alice.ErrNoServers:              "no_servers",
+		alice.ErrNoOffers:               "no_offers",
+		alice.ErrBudgetExceeded:         "budget_exceeded",
+		alice.ErrTimelockTooShort:       "timelock_too_short",
+		alice.ErrInvalidAdaptorSig:      "invalid_adaptor_signature",
+		alice.ErrInvalidClaimSig:        "invalid_claim_signature",
+		alice.ErrWrongState:             "wrong_state",
+		ethereum.ErrStillSyncing:        "ethereum_node_syncing",
+		ethereum.ErrIncompatibleVersion: "incompatible_contract",
+		ethereum.ErrDeprecated:          "deprecated_contract",
+		ethereum.ErrUnexpectedDirectory: "invalid_keystore",
+		ethereum.ErrLowBalance:          "low_balance",
+		ethereum.ErrInvalidAmount:       "invalid_amount",
+		ethereum.ErrInvalidAddress:      "invalid_address",
+		ethereum.ErrPayoutUnsupported:   "payout_unsupported",
+		ethereum.ErrEscrowUnsupported:   "escrow_unsupported",
+		sia.ErrWalletLocked:             "sia_wallet_locked",
+		sia.ErrInsufficientFunds:        "insufficient_funds",
+		sia.ErrInvalidAmount:            "invalid_amount",
+		sia.ErrInvalidAddress:           "invalid_address",
+		scheduler.ErrNoRules:            "no_rules",
+		scheduler.ErrInvalidInterval:    "invalid_interval",
+		history.ErrUnknownFormat:        "unknown_format",
+		frontend.ErrInvalidRule:         "invalid_rule",
+		frontend.ErrNoExchangeRate:      "no_exchange_rate",
+		frontend.ErrConflictingRules:    "conflicting_rules",
+		frontend.ErrNoCommand:           "no_approval_command",
+		output.ErrUnknownFormat:         "unknown_output_format",
+		errParsingFailed:                "invalid_id",
+		errTUIWithJSON:                  "tui_with_json",
+		errEmptyTraderPlugin:            "empty_trader_plugin",
+		errUnknownStrategy:              "unknown_strategy",
+		errInvalidPercentage:            "invalid_percentage",
+		errInvalidPrice:                 "invalid_price",
+		errNoRatesFile:                  "no_rates_file",
+		errPluginBacktest:               "plugin_backtest",
+		backtest.ErrNoRates:             "no_rates",
+		backtest.ErrInvalidRate:         "invalid_rate",
+		backtest.ErrInvalidTime:         "invalid_time",
+		trader.ErrInvalidPluginReply:    "invalid_plugin_reply",
+		trader.ErrPluginTimeout:         "plugin_timeout",
+	}
 )
 
+func errorCode(err error) string {
+	code, ok := errorCodes[err]
+	if ok {
+		return code
+	}
+
+	_, isRPCError := status.FromError(err)
+	if isRPCError {
+		return "server_error"
+	}
+
+	return "unknown"
+}
+
+func fail(err error) {
+	if out.IsJSON() {
+		out.Error(errorCode(err), err)
+		os.Exit(1)
+	}
+
+	log.Fatal(err)
+}
+
+func initTrader(ethChain ethereum.Blockchain, siaChain sia.Blockchain,
+	volume trader.VolumeTracker, rates trader.RateFetcher) (trader.Trader, error) {
+	if traderPlugin != "" {
+		command := strings.Fields(traderPlugin)
+		if len(command) == 0 {
+			return nil, errEmptyTraderPlugin
+		}
+		return trader.NewPluginTrader(command[0], command[1:]...)
+	}
+
+	switch strategy {
+	case strategyFixed:
+		fixedPremiumTrader := trader.NewFixedPremiumTrader(nil, *defaultAntiSpamFee, ethChain, siaChain)
+		fixedPremiumTrader.SetRates(rates)
+		return &fixedPremiumTrader, nil
+	case strategyInventory:
+		config, err := parseInventoryConfig()
+		if err != nil {
+			return nil, err
+		}
+
+		inventoryTrader := trader.NewInventoryTrader(*config, rates, volume, ethChain, siaChain)
+		return &inventoryTrader, nil
+	default:
+		return nil, errUnknownStrategy
+	}
+}
+
+func initAntiSpamPolicy() (*trader.AntiSpamPolicy, error) {
+	config := trader.AntiSpamConfig{}
+
+	base, err := ethereum.ParseEther(antiSpamFee)
+	if err != nil {
+		return nil, err
+	}
+	config.Base = *base
+
+	perSiacoin, err := ethereum.ParseEther(antiSpamFeePerSiacoin)
+	if err != nil {
+		return nil, err
+	}
+	config.PerSiacoin = *perSiacoin
+
+	for _, factor := range []struct {
+		target **big.Rat
+		value  string
+	}{{&config.LoadFactor, antiSpamLoadFactor}, {&config.FailureFactor, antiSpamFailureFactor}} {
+		percentage, ok := new(big.Rat).SetString(factor.value)
+		if !ok || percentage.Sign() == -1 {
+			return nil, errInvalidPercentage
+		}
+		*factor.target = percentage.Quo(percentage, big.NewRat(100, 1))
+	}
+
+	if antiSpamMaxFee != "" {
+		config.Max, err = ethereum.ParseEther(antiSpamMaxFee)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return trader.NewAntiSpamPolicy(config), nil
+}
+
+// antiSpamFlagsChanged reports whether any anti-spam option was given
+// explicitly. Otherwise, pricing plugins determine the fee themselves.
+func antiSpamFlagsChanged(cmd *cobra.Command) bool {
+	for _, name := range []string{"anti-spam-fee", "anti-spam-fee-per-sc", "anti-spam-load-factor",
+		"anti-spam-failure-factor", "anti-spam-max-fee"} {
+		if cmd.Flags().Changed(name) {
+			return true
+		}
+	}
+	return false
+}
+
+func parseInventoryConfig() (*trader.InventoryConfig, error) {
+	config := trader.InventoryConfig{AntiSpamFee: *defaultAntiSpamFee}
+
+	for _, premium := range []struct {
+		target **big.Rat
+		value  string
+	}{
+		{&config.BasePremium, premiumPercentage},
+		{&config.InventoryPremium, inventoryPremium},
+		{&config.VolumePremium, volumePremium},
+	} {
+		percentage, ok := new(big.Rat).SetString(premium.value)
+		if !ok || percentage.Sign() == -1 {
+			return nil, errInvalidPercentage
+		}
+		*premium.target = percentage.Quo(percentage, big.NewRat(100, 1))
+	}
+
+	for _, price := range []struct {
+		target **big.Rat
+		value  string
+	}{{&config.FloorUSD, priceFloor}, {&config.CeilingUSD, priceCeiling}} {
+		if price.value == "" {
+			continue
+		}
+
+		usd, ok := new(big.Rat).SetString(price.value)
+		if !ok || usd.Sign() == -1 {
+			return nil, errInvalidPrice
+		}
+		*price.target = usd
+	}
+
+	for _, limit := range []struct {
+		target *types.Currency
+		value  string
+	}{{&config.MaxSwap, maxSwap}, {&config.DailyCap, dailyCap}} {
+		if limit.value == "" {
+			continue
+		}
+
+		siacoin, err := sia.ParseSiacoin(limit.value)
+		if err != nil {
+			return nil, err
+		}
+		*limit.target = siacoin
+	}
+
+	return &config, nil
+}
+
+func initNotifier() (*webhook.Notifier, error) {
+	if len(webhookURLs) == 0 {
+		return nil, nil
+	}
+
+	secret := ""
+	if webhookSecretFile != "" {
+		var err error
+		secret, err = config.ReadPasswordFile(webhookSecretFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return webhook.New(webhookURLs, secret), nil
+}
+
+func setupOutput(cmd *cobra.Command, args []string) {
+	format, err := output.ParseFormat(outputFormat)
+	if err != nil {
+		fail(err)
+	}
+
+	out = output.New(format, os.Stdout)
+}
+
 func initEthChain() (ethereum.Blockchain, error) {
 	var maybeContractAddress *common.Address
 	if contractAddressHex != "" {
@@ -76,7 +377,7 @@ func initEthChain() (ethereum.Blockchain, error) {
 			return nil, err
 		}
 	} else {
-		err = ethereum.EnsureKeystoreExists(keystoreFile)
+		err = ethereum.EnsureKeystoreExists(keystoreFile, out)
 		if err != nil {
 			return nil, err
 		}
@@ -90,7 +391,7 @@ func initEthChain() (ethereum.Blockchain, error) {
 		}
 	}
 
-	err = ethChain.CheckBalance()
+	err = ethChain.CheckBalance(out)
 	if err != nil {
 		return nil, err
 	}
@@ -123,26 +424,122 @@ func initSiaChain() (sia.Blockchain, error) {
 }
 
 func runServe(cmd *cobra.Command, args []string) {
+	var maybePayoutAddress *common.Address
+	if payoutAddressHex != "" {
+		payoutAddress, err := ethereum.ParseAddress(payoutAddressHex)
+		if err != nil {
+			fail(err)
+		}
+		maybePayoutAddress = &payoutAddress
+	}
+
+	quoteSiacoin, err := sia.ParseSiacoin(quoteSize)
+	if err != nil {
+		fail(err)
+	}
+
+	var maybeSweepAddress *common.Address
+	var reserve, minimum *big.Int
+	if sweepAddressHex != "" {
+		sweepAddress, err := ethereum.ParseAddress(sweepAddressHex)
+		if err != nil {
+			fail(err)
+		}
+		maybeSweepAddress = &sweepAddress
+
+		reserve, err = ethereum.ParseEther(sweepReserve)
+		if err != nil {
+			fail(err)
+		}
+
+		minimum, err = ethereum.ParseEther(sweepMinimum)
+		if err != nil {
+			fail(err)
+		}
+	}
+
 	ethChain, err := initEthChain()
 	if err != nil {
-		log.Fatal(err)
+		fail(err)
+	}
+
+	if maybePayoutAddress != nil {
+		err = ethChain.SetPayoutAddress(*maybePayoutAddress)
+		if err != nil {
+			fail(err)
+		}
+	}
+
+	if antiSpamEscrow {
+		err = ethChain.CheckEscrowSupport()
+		if err != nil {
+			fail(err)
+		}
 	}
 
 	siaChain, err := initSiaChain()
 	if err != nil {
-		log.Fatal(err)
+		fail(err)
 	}
 
-	trader := trader.NewFixedPremiumTrader(nil, *defaultAntiSpamFee, ethChain, siaChain)
-	blacklist := bob.NewBlacklist()
+	swapLedger := ledger.New(ledgerFile, trader.NewExchangeRate())
+	swapTrader, err := initTrader(ethChain, siaChain, swapLedger, trader.NewExchangeRate())
+	if err != nil {
+		fail(err)
+	}
+	if quoteCacheDuration > 0 {
+		swapTrader = trader.NewCachingTrader(swapTrader, quoteCacheDuration)
+	}
+	var policy *trader.AntiSpamPolicy
+	if traderPlugin == "" || antiSpamFlagsChanged(cmd) {
+		policy, err = initAntiSpamPolicy()
+		if err != nil {
+			fail(err)
+		}
+		swapTrader = policy.Wrap(swapTrader)
+	}
+	blacklist, err := bob.NewPersistentBlacklist(blacklistFile)
+	if err != nil {
+		fail(err)
+	}
 
 	newAtomicSwap := func(now time.Time) *bob.AtomicSwap {
-		return bob.NewAtomicSwap(&trader, ethChain, siaChain, blacklist, now)
+		atomicSwap := bob.NewAtomicSwap(swapTrader, ethChain, siaChain, blacklist, now)
+		if antiSpamEscrow {
+			atomicSwap.EnableEscrow()
+		}
+		return atomicSwap
 	}
-	bobServer, err := rpc.NewBobServer(serverNetwork, serverAddress, certFile, keyFile, externalAddress, newAtomicSwap)
+
+	notifier, err := initNotifier()
 	if err != nil {
-		log.Fatal(err)
+		fail(err)
+	}
+	var maybeNotifier rpc.Notifier
+	if notifier != nil {
+		maybeNotifier = notifier
+	}
+
+	bobServer, err := rpc.NewBobServer(
+		serverNetwork, serverAddress, certFile, keyFile, externalAddress, newAtomicSwap, swapLedger, maybeNotifier)
+	if err != nil {
+		fail(err)
+	}
+
+	// configure the server before any goroutine uses it
+	if policy != nil {
+		bobServer.SetAntiSpamPolicy(policy)
 	}
+	bobServer.SetLimits(rpc.Limits{
+		RequestsPerClient: rateLimit,
+		RequestsGlobal:    rateLimitGlobal,
+		MaxPendingSwaps:   maxPendingSwaps,
+		OfferTimeout:      offerTimeout,
+	})
+
+	quoteEngine := trader.NewQuoteEngine(
+		trader.NewExchangeRate(), swapTrader, quoteSiacoin, volatilityFactor, quoteWindow)
+	bobServer.SetQuoteEngine(quoteEngine)
 
 	c := make(chan os.Signal, 1)
 	signal.Notify(c, syscall.SIGUSR1)
@@ -156,7 +553,7 @@ func runServe(cmd *cobra.Command, args []string) {
 
 	err = bobServer.Register(*registryEntryMaxAge, ethChain)
 	if err != nil {
-		log.Fatal(err)
+		fail(err)
 	}
 	go func() {
 		for {
@@ -175,101 +572,611 @@ func runServe(cmd *cobra.Command, args []string) {
 			if err3 != nil {
 				log.Printf("Error while running check: %s\n", err3)
 			}
+			if antiSpamEscrow {
+				_, err3 = bobServer.WithdrawCredit(ethChain)
+				if err3 != nil {
+					log.Printf("Error while withdrawing credit: %s\n", err3)
+				}
+			}
 		}
 	}()
 
+	go func() {
+		for {
+			err5 := quoteEngine.Sample(time.Now())
+			if err5 != nil {
+				log.Printf("Error while sampling exchange rates: %s\n", err5)
+			}
+			time.Sleep(quoteSampleInterval)
+		}
+	}()
+
+	if maybeSweepAddress != nil {
+		go func() {
+			for {
+				time.Sleep(sweepInterval)
+				_, err4 := bobServer.Sweep(ethChain, *maybeSweepAddress, *reserve, *minimum)
+				if err4 != nil {
+					log.Printf("Error while sweeping: %s\n", err4)
+				}
+			}
+		}()
+	}
+
+	if useTUI {
+		if out.IsJSON() {
+			fail(errTUIWithJSON)
+		}
+
+		logs := tui.NewLogBuffer(serverViewLogLines)
+		log.SetOutput(logs)
+		go tui.NewServerView(os.Stdout, bobServer.Snapshot, logs).Run(serverViewInterval, nil)
+	}
+
 	err = bobServer.Serve()
 	if err != nil {
-		log.Fatal(err)
+		log.SetOutput(os.Stderr)
+		fail(err)
+	}
+}
+
+func parseOrder(amount string) (*alice.Order, error) {
+	var order alice.Order
+	if siaAddress != "" {
+		claimAddress, err := sia.ParseAddress(siaAddress)
+		if err != nil {
+			return nil, err
+		}
+		order.ClaimAddress = &claimAddress
+	}
+
+	if etherBudget {
+		budget, err := ethereum.ParseEther(amount)
+		if err != nil {
+			return nil, err
+		}
+
+		order.EtherBudget = budget
+		return &order, nil
+	}
+
+	siacoin, err := sia.ParseSiacoin(amount)
+	if err != nil {
+		return nil, err
 	}
+
+	order.Siacoin = siacoin
+	return &order, nil
 }
 
 func runBuy(cmd *cobra.Command, args []string) {
-	amount, err := strconv.ParseInt(args[0], 10, 64)
+	order, err := parseOrder(args[0])
 	if err != nil {
-		log.Fatal(err)
+		fail(err)
 	}
-	hastings := types.SiacoinPrecision.Mul64(uint64(amount))
 
 	ethChain, err := initEthChain()
 	if err != nil {
-		log.Fatal(err)
+		fail(err)
 	}
 
 	siaChain, err := initSiaChain()
 	if err != nil {
-		log.Fatal(err)
+		fail(err)
 	}
 
 	var selectedFrontend frontend.Frontend
 	exchangeRate := trader.NewExchangeRate()
-	if absDiffRule == 0 && relDiffRule == 0 {
-		selectedFrontend = frontend.NewConsoleFrontend(similarityPercentage, useExchangeRate, exchangeRate)
+	if approvalCommand != "" {
+		fields := strings.Fields(approvalCommand)
+		if len(fields) == 0 {
+			fail(frontend.ErrNoCommand)
+		}
+		selectedFrontend, err = frontend.NewExecFrontend(fields[0], fields[1:], exchangeRate)
+		if err != nil {
+			fail(err)
+		}
+	} else if rules != "" {
+		if absDiffRule != 0 || relDiffRule != 0 {
+			fail(frontend.ErrConflictingRules)
+		}
+
+		rule, err := frontend.ParseRules(rules)
+		if err != nil {
+			fail(err)
+		}
+		selectedFrontend = frontend.NewRuleExpressionFrontend(rule, bindingTolerance, exchangeRate)
+	} else if absDiffRule == 0 && relDiffRule == 0 {
+		selectedFrontend = frontend.NewConsoleFrontend(similarityPercentage, useExchangeRate, pickOffer, exchangeRate, out)
 	} else {
 		selectedFrontend = frontend.NewRuleBasedFrontend(absDiffRule, relDiffRule, exchangeRate)
 	}
 
+	records, err := history.Load(historyFile)
+	if err != nil {
+		log.Printf("Unable to load purchase history: %s\n", err)
+	}
+	selectedFrontend = frontend.NewReputationFrontend(selectedFrontend, history.Reputation(records))
+
 	serverDetails, err := ethChain.FetchServers(*registryEntryMaxAgeWithMargin)
 	if err != nil {
-		log.Fatal(err)
+		fail(err)
+	}
+
+	maxAntiSpamFeeRat := new(big.Rat).Mul(new(big.Rat).SetFloat64(maxAntiSpamFeeInEther), new(big.Rat).SetInt(ether))
+	maxAntiSpamFee, _ := new(big.Float).SetRat(maxAntiSpamFeeRat).Int(nil)
+
+	var sink alice.EventSink = alice.NewConsoleSink(out)
+	var view *tui.SwapView
+	if useTUI {
+		if out.IsJSON() {
+			fail(errTUIWithJSON)
+		}
+
+		view = tui.NewSwapView(os.Stdout)
+		sink = view
+	}
+
+	notifier, err := initNotifier()
+	if err != nil {
+		fail(err)
+	}
+	if notifier != nil {
+		sink = alice.Sinks{sink, notifier}
+	}
+
+	result, err := alice.PerformSwap(
+		*order, serverDetails, maxAntiSpamFee, fundingConfirmations, selectedFrontend, ethChain, siaChain, sink)
+	if view != nil {
+		view.Close()
+	}
+	if err == alice.ErrOfferDeclined {
+		// the sink has already reported the decline
+		notifier.Close()
+		return
+	}
+	if err != nil {
+		notifier.Notify("error", output.Fields{"code": errorCode(err), "message": err.Error()})
+		notifier.Close()
+		fail(err)
+	}
+	notifier.Close()
+
+	recordHistory(result)
+}
+
+func recordHistory(result *alice.Result) {
+	if result == nil {
+		return
+	}
+
+	err := history.Append(historyFile, history.NewRecord(*result, time.Now()))
+	if err != nil {
+		log.Printf("Unable to record purchase in history: %s\n", err)
+	}
+}
+
+func runScheduleCreate(cmd *cobra.Command, args []string) {
+	order, err := parseOrder(args[0])
+	if err != nil {
+		fail(err)
+	}
+
+	var maybeMaxTotalEther *big.Int
+	if maxTotalEther != "" {
+		maybeMaxTotalEther, err = ethereum.ParseEther(maxTotalEther)
+		if err != nil {
+			fail(err)
+		}
+	}
+
+	schedule, err := scheduler.NewSchedule(
+		*order, scheduleInterval, maybeMaxTotalEther, absDiffRule, relDiffRule, rules, time.Now())
+	if err != nil {
+		fail(err)
+	}
+
+	err = schedule.Save(scheduleFile)
+	if err != nil {
+		fail(err)
+	}
+
+	out.Printf("Created schedule %s\n", scheduleFile)
+	out.Event("schedule_created", output.Fields{"path": scheduleFile})
+}
+
+func runScheduleRun(cmd *cobra.Command, args []string) {
+	ethChain, err := initEthChain()
+	if err != nil {
+		fail(err)
+	}
+
+	siaChain, err := initSiaChain()
+	if err != nil {
+		fail(err)
 	}
 
 	maxAntiSpamFeeRat := new(big.Rat).Mul(new(big.Rat).SetFloat64(maxAntiSpamFeeInEther), new(big.Rat).SetInt(ether))
 	maxAntiSpamFee, _ := new(big.Float).SetRat(maxAntiSpamFeeRat).Int(nil)
 
-	err = alice.PerformSwap(
-		hastings, serverDetails, maxAntiSpamFee, fundingConfirmations, selectedFrontend, ethChain, siaChain)
+	notifier, err := initNotifier()
+	if err != nil {
+		fail(err)
+	}
+	var sink alice.EventSink = alice.NewConsoleSink(out)
+	if notifier != nil {
+		sink = alice.Sinks{sink, notifier}
+	}
+
+	swap := func(order alice.Order, selectedFrontend frontend.Frontend) (*alice.Result, error) {
+		serverDetails, err := ethChain.FetchServers(*registryEntryMaxAgeWithMargin)
+		if err != nil {
+			return nil, err
+		}
+
+		result, err := alice.PerformSwap(
+			order, serverDetails, maxAntiSpamFee, fundingConfirmations, selectedFrontend, ethChain, siaChain, sink)
+		if err == alice.ErrOfferDeclined {
+			return result, err
+		} else if err != nil {
+			notifier.Notify("error", output.Fields{"code": errorCode(err), "message": err.Error()})
+			return result, err
+		}
+		recordHistory(result)
+		return result, nil
+	}
+
+	err = scheduler.Start(scheduleFile, trader.NewExchangeRate(), swap, out)
+	if err != nil {
+		fail(err)
+	}
+}
+
+func runScheduleSummary(cmd *cobra.Command, args []string) {
+	schedule, err := scheduler.Load(scheduleFile)
+	if err != nil {
+		fail(err)
+	}
+
+	for _, run := range schedule.Runs {
+		out.Println(run)
+		out.Event("schedule_run", run.Fields())
+	}
+
+	summary := schedule.Summarize()
+	out.Println(summary)
+	out.Event("schedule_summary", summary.Fields())
+}
+
+func runReport(cmd *cobra.Command, args []string) {
+	entries, err := ledger.Load(ledgerFile)
+	if err != nil {
+		fail(err)
+	}
+
+	if csvExport {
+		err = ledger.WriteCSV(os.Stdout, entries)
+		if err != nil {
+			fail(err)
+		}
+		return
+	}
+
+	if len(entries) == 0 {
+		out.Println("No completed swaps recorded yet.")
+	}
+
+	for _, day := range ledger.Daily(entries) {
+		out.Println(day)
+		out.Event("report_day", day.Fields())
+	}
+}
+
+func runHistory(cmd *cobra.Command, args []string) {
+	records, err := history.Load(historyFile)
+	if err != nil {
+		fail(err)
+	}
+
+	if exportFormat != "" {
+		err = history.Export(os.Stdout, records, exportFormat)
+		if err != nil {
+			fail(err)
+		}
+		return
+	}
+
+	if len(records) == 0 {
+		out.Println("No completed purchases recorded yet.")
+	}
+
+	for _, record := range records {
+		out.Println(record)
+		out.Event("purchase", record.Fields())
+	}
+}
+
+func runQuote(cmd *cobra.Command, args []string) {
+	ethChain, err := initEthChain()
+	if err != nil {
+		fail(err)
+	}
+
+	serverDetails, err := ethChain.FetchServers(*registryEntryMaxAgeWithMargin)
+	if err != nil {
+		fail(err)
+	}
+
+	if len(serverDetails) == 0 {
+		fail(alice.ErrNoServers)
+	}
+
+	for _, details := range serverDetails {
+		quote, err := fetchQuote(details)
+		if err != nil {
+			out.Printf("%s: %s\n", details.Target, err)
+			out.Event("quote_error", output.Fields{"server": details.Target, "message": err.Error()})
+			continue
+		}
+
+		out.Printf("%s: %s\n", details.Target, quote)
+		fields := quote.Fields()
+		fields["server"] = details.Target
+		out.Event("quote", fields)
+	}
+}
+
+func fetchQuote(details ethereum.ServerDetails) (*trader.Quote, error) {
+	roadieClient, err := rpc.Dial(details.Target, details.Cert)
+	if err != nil {
+		return nil, err
+	}
+	defer roadieClient.Close()
+
+	return roadieClient.GetQuote()
+}
+
+func runBacktest(cmd *cobra.Command, args []string) {
+	if ratesFile == "" {
+		fail(errNoRatesFile)
+	}
+	if traderPlugin != "" {
+		fail(errPluginBacktest) // a plugin would price with live instead of replayed rates
+	}
+
+	file, err := os.Open(ratesFile)
+	if err != nil {
+		fail(err)
+	}
+	rates, err := backtest.LoadRates(file)
+	file.Close()
+	if err != nil {
+		fail(err)
+	}
+
+	var requests []backtest.Request
+	if requestsFile != "" {
+		file, err := os.Open(requestsFile)
+		if err != nil {
+			fail(err)
+		}
+		requests, err = backtest.LoadRequests(file)
+		file.Close()
+		if err != nil {
+			fail(err)
+		}
+	} else {
+		min, err := sia.ParseSiacoin(minRequest)
+		if err != nil {
+			fail(err)
+		}
+		max, err := sia.ParseSiacoin(maxRequest)
+		if err != nil {
+			fail(err)
+		}
+
+		start, end := backtest.Span(rates)
+		requests = backtest.GenerateRequests(start, end, requestsPerHour, min, max, seed)
+	}
+
+	balance, err := sia.ParseSiacoin(backtestBalance)
 	if err != nil {
-		log.Fatal(err)
+		fail(err)
+	}
+
+	tolerance, ok := new(big.Rat).SetString(buyerTolerance)
+	if !ok || tolerance.Sign() == -1 {
+		fail(errInvalidPercentage)
 	}
+
+	config := backtest.Config{
+		Balance:         balance,
+		GasPrice:        new(big.Int).Mul(big.NewInt(gasPriceInGwei), gwei),
+		MinerFee:        types.SiacoinPrecision, // as used by the server
+		Tolerance:       tolerance.Quo(tolerance, big.NewRat(100, 1)),
+		SwapDuration:    swapDuration,
+		FundingDuration: fundingDuration,
+	}
+	report, err := backtest.Run(rates, requests, config, func(env *backtest.Environment) (trader.Trader, error) {
+		return initTrader(env.EthChain, env.SiaChain, env, env)
+	})
+	if err != nil {
+		fail(err)
+	}
+
+	out.Println(report)
+	out.Event("backtest", report.Fields())
 }
 
 func runReclaim(cmd *cobra.Command, args []string) {
 	antiSpamID := new(big.Int)
 	_, ok := antiSpamID.SetString(args[0], 10)
 	if !ok {
-		log.Fatal(errParsingFailed)
+		fail(errParsingFailed)
 	}
 
 	ethChain, err := initEthChain()
 	if err != nil {
-		log.Fatal(err)
+		fail(err)
 	}
 
-	err = alice.ReclaimDeposit(ethChain, *antiSpamID)
+	err = alice.ReclaimDeposit(ethChain, *antiSpamID, alice.NewConsoleSink(out))
 	if err != nil {
-		log.Fatal(err)
+		fail(err)
 	}
 }
 
 func runInit(cmd *cobra.Command, args []string) {
 	_, err := initEthChain()
 	if err == ethereum.ErrLowBalance {
-		fmt.Println(err)
+		out.Println(err)
+		out.Error(errorCode(err), err)
 	} else if err != nil {
-		log.Fatal(err)
+		fail(err)
 	}
 }
 
+func addStrategyFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&strategy, "strategy", strategy, "pricing strategy: 'fixed' or 'inventory'; see help for details")
+	cmd.Flags().StringVar(&premiumPercentage, "premium", premiumPercentage, "base premium in percent (inventory strategy)")
+	cmd.Flags().StringVar(&inventoryPremium, "inventory-premium", inventoryPremium, "additional premium in percent for a swap using all siacoins (inventory strategy)")
+	cmd.Flags().StringVar(&volumePremium, "volume-premium", volumePremium, "additional premium in percent once the daily cap is reached (inventory strategy)")
+	cmd.Flags().StringVar(&priceFloor, "price-floor", priceFloor, "minimum price of one SC in USD (inventory strategy)")
+	cmd.Flags().StringVar(&priceCeiling, "price-ceiling", priceCeiling, "maximum price of one SC in USD (inventory strategy)")
+	cmd.Flags().StringVar(&maxSwap, "max-swap", maxSwap, "maximum amount of siacoins per swap (inventory strategy)")
+	cmd.Flags().StringVar(&dailyCap, "daily-cap", dailyCap, "maximum amount of siacoins sold within 24 hours (inventory strategy)")
+	cmd.Flags().StringVar(&traderPlugin, "trader-plugin", traderPlugin, "command to run as external pricing strategy; see help for 'serve'")
+}
+
 func main() {
 	descServe := "Start and register a server to offer atomic swaps"
 	cmdServe := &cobra.Command{
 		Use:   "serve",
 		Short: descServe,
-		Long:  fmt.Sprintf("%s.", descServe),
-		Run:   runServe,
+		Long: fmt.Sprintf(`%s.
+
+Claimed deposits are paid to the Ethereum wallet of the server, unless
+--payout-address is given. In that case they are paid directly to that address
+(for example a cold wallet) and the server wallet only needs to hold enough
+ether for gas. This requires version 0.2.0 or later of the smart contract.
+
+Alternatively, --sweep-address periodically transfers everything above
+--sweep-reserve from the server wallet to the given address, as long as at
+least --sweep-minimum would be transferred. No sweep takes place while a claim
+might be pending.
+
+Anti-spam IDs that were used for a binding offer are recorded in
+--blacklist-file, so that they cannot be used again, even after a restart.
+Several servers using the same wallet can share this file.
+
+With --webhook, events are posted as JSON to the given URLs whenever a swap
+changes state (for example swap_funded, swap_completed or swap_refunded) and
+when periodic checks or registration fail (check_failed, register_failed).
+Failed requests are retried a few times with increasing delays. If
+--webhook-secret-file is given, each request carries the time it was sent (Unix
+time in seconds) in the X-Roadie-Timestamp header and an HMAC-SHA256 signature
+of that timestamp, a dot and the body, keyed with the contents of that file, in
+the X-Roadie-Signature header ("sha256=" followed by the hex-encoded
+signature). Receivers should check the signature and reject requests with an
+old timestamp, so that captured requests cannot be replayed. The same options
+are available for 'roadie buy', where all progress events are posted.
+
+By default, offers are priced at the market rate plus Ethereum transaction
+fees. With --strategy inventory, a premium is added on top: --premium for every
+swap, up to --inventory-premium depending on the share of the available
+siacoins a swap would use, and up to --volume-premium depending on how much of
+--daily-cap has been sold within the last 24 hours. The resulting price of one
+SC is kept between --price-floor and --price-ceiling. Swaps larger than
+--max-swap or exceeding --daily-cap are declined.
+
+A custom pricing strategy can be written in any language and passed as
+--trader-plugin "<command> [args...]". The command is started by roadie and
+answers JSON-RPC 1.0 requests on stdin/stdout, one per line, for the methods
+Plugin.PrepareNonBindingOffer, Plugin.PrepareBindingOffer,
+Plugin.CalculateSiacoin, Plugin.PauseOrderPreparation and
+Plugin.ResumeOrderPreparation. Requests carry a single object with "siacoin"
+(hastings), "ether" (wei), "miner_fee" (hastings) and "now" (RFC 3339); offers
+are returned as {"msg", "available", "ether", "anti_spam_fee", "deadline"},
+with amounts as decimal strings. See trader.PluginTrader for details. If the
+command exits, it is started again on the next request.
+
+Buyers burn an anti-spam fee before they receive a binding offer. It is
+--anti-spam-fee plus --anti-spam-fee-per-sc for every siacoin requested, raised
+by --anti-spam-load-factor percent for every swap currently in progress
+(including those that have not requested a binding offer yet) and by up to
+--anti-spam-failure-factor percent depending on the share of recent binding
+offers that did not lead to a completed swap, but never more than
+--anti-spam-max-fee. Pricing plugins set the fee themselves unless one of these
+options is given. Note that buyers reject offers with an anti-spam fee above
+0.001 ETH by default.
+
+With --anti-spam-escrow, offers ask buyers to escrow the anti-spam fee for the
+server wallet instead of burning it. The fee is credited back to the buyer once
+the server claims the deposit. If the buyer reclaims the deposit instead, the
+fee is credited to the server, which withdraws it periodically. If no deposit
+is made, the server collects the fee when it forgets about the swap. Buyers that
+burn the fee anyway are still served. This requires version 0.3.0 or later of
+the smart contract.
+
+Requests for non-binding offers and quotes are free for clients, so each host
+is limited to --rate-limit requests per minute and all hosts together to
+--rate-limit-global requests per minute. At most --max-pending-swaps swaps may
+wait for a binding offer at any time and such swaps are forgotten after
+--offer-timeout. Non-binding offers for the same amount are reused for
+--quote-cache, so that repeated requests do not query the wallet and exchange
+rates every time. A value of 0 disables the respective limit.
+
+Clients can also request an indicative price for --quote-size siacoins
+without starting a swap (see 'roadie quote'). It is based on the non-binding
+offer the pricing strategy would make and is raised by
+--quote-volatility-factor times the volatility (standard deviation) of the
+exchange rates sampled during the last six hours.`, descServe),
+		Run: runServe,
 	}
 	cmdServe.Flags().StringVarP(&serverAddress, "listen", "l", serverAddress, "interface and port to listen on")
 	cmdServe.Flags().StringVarP(&certFile, "cert", "c", certFile, "path to certificate (or omit to disable encryption)")
 	cmdServe.Flags().StringVarP(&keyFile, "key", "k", certFile, "path to certificate key (or omit to disable encryption)")
 	cmdServe.Flags().StringVarP(&externalAddress, "addr", "a", externalAddress, "external server address (host and port to register with the smart contract)")
+	cmdServe.Flags().StringVar(&payoutAddressHex, "payout-address", payoutAddressHex, "Ethereum address to pay claimed deposits to instead of the server wallet")
+	cmdServe.Flags().StringVar(&sweepAddressHex, "sweep-address", sweepAddressHex, "Ethereum address to periodically sweep earnings to")
+	cmdServe.Flags().StringVar(&sweepReserve, "sweep-reserve", sweepReserve, "ether to keep in the server wallet for gas when sweeping")
+	cmdServe.Flags().StringVar(&sweepMinimum, "sweep-minimum", sweepMinimum, "smallest amount of ether worth sweeping")
+	cmdServe.Flags().DurationVar(&sweepInterval, "sweep-interval", sweepInterval, "time between sweeps")
+	cmdServe.Flags().StringVar(&ledgerFile, "ledger-file", ledgerFile, "path to ledger file for recording completed swaps")
+	cmdServe.Flags().StringVar(&blacklistFile, "blacklist-file", blacklistFile, "path to file for recording used anti-spam IDs")
+	addStrategyFlags(cmdServe)
+	cmdServe.Flags().StringVar(&antiSpamFee, "anti-spam-fee", antiSpamFee, "base anti-spam fee in ETH")
+	cmdServe.Flags().StringVar(&antiSpamFeePerSiacoin, "anti-spam-fee-per-sc", antiSpamFeePerSiacoin, "additional anti-spam fee in ETH per SC requested")
+	cmdServe.Flags().StringVar(&antiSpamLoadFactor, "anti-spam-load-factor", antiSpamLoadFactor, "raise anti-spam fee by this percentage per swap in progress")
+	cmdServe.Flags().StringVar(&antiSpamFailureFactor, "anti-spam-failure-factor", antiSpamFailureFactor, "raise anti-spam fee by this percentage if all recent binding offers failed")
+	cmdServe.Flags().StringVar(&antiSpamMaxFee, "anti-spam-max-fee", antiSpamMaxFee, "maximum anti-spam fee in ETH")
+	cmdServe.Flags().BoolVar(&antiSpamEscrow, "anti-spam-escrow", antiSpamEscrow, "ask buyers to escrow the anti-spam fee instead of burning it; see help for details")
+	cmdServe.Flags().Float64Var(&rateLimit, "rate-limit", rateLimit, "maximum requests for offers and quotes per minute and host")
+	cmdServe.Flags().Float64Var(&rateLimitGlobal, "rate-limit-global", rateLimitGlobal, "maximum requests for offers and quotes per minute from all hosts")
+	cmdServe.Flags().IntVar(&maxPendingSwaps, "max-pending-swaps", maxPendingSwaps, "maximum number of swaps waiting for a binding offer")
+	cmdServe.Flags().DurationVar(&offerTimeout, "offer-timeout", offerTimeout, "forget swaps that did not request a binding offer within this time")
+	cmdServe.Flags().DurationVar(&quoteCacheDuration, "quote-cache", quoteCacheDuration, "reuse non-binding offers for the same amount for this long")
+	cmdServe.Flags().StringVar(&quoteSize, "quote-size", quoteSize, "amount of siacoins that quoted prices refer to")
+	cmdServe.Flags().Float64Var(&volatilityFactor, "quote-volatility-factor", volatilityFactor, "raise quoted prices by this multiple of recent volatility")
+	cmdServe.Flags().StringSliceVar(&webhookURLs, "webhook", webhookURLs, "URL to post swap events to (may be repeated); see help for details")
+	cmdServe.Flags().StringVar(&webhookSecretFile, "webhook-secret-file", webhookSecretFile, "path to file with secret for signing webhook requests")
 	cmdServe.Flags().BoolVar(&siaDryRun, "sia-dry-run", siaDryRun, "do not actually broadcast Sia transactions")
+	cmdServe.Flags().BoolVar(&useTUI, "tui", useTUI, "show a full-screen overview of all swaps in progress")
 
 	cmdBuy := &cobra.Command{
-		Use:   "buy [SC amount]",
+		Use:   "buy [amount]",
 		Short: "Buy siacoins with ether via an atomic swap",
 		Long: `Buy siacoins with ether via an atomic swap.
 
+The amount may be fractional (for example 2.5). If --ether is given, the amount
+is instead interpreted as an ether budget and Roadie will ask for as many
+siacoins as this budget buys. The anti-spam fee is not part of the budget.
+
+Purchased siacoins are sent to a fresh address of the local Sia wallet, unless
+--sia-address is given. In that case they go directly to that address (for
+example a cold wallet) and the local Sia node is only used to follow the
+blockchain and to broadcast transactions.
+
 If at least one of --abs-diff-rule or --rel-diff-rule is given, Roadie will
 automatically accept or decline an offer based on those rules. Using exchange
 rate data from CoinMarketCap, Roadie will compare the USD amount required to
@@ -277,16 +1184,191 @@ spend on an offer with the USD amount of SC received in return. In the case
 of --abs-diff-rule the absolute difference may not exceed the specified amount
 for the rule to match. For --rel-diff-rule the difference will be calculated
 as a percentage and compared to the specified value. Either of these two rules
-need to match for the offer to be accepted. Otherwise the offer will be rejected.`,
+need to match for the offer to be accepted. Otherwise the offer will be rejected.
+
+Alternatively, --rules accepts a rule expression, which can also express
+limits that need no exchange rate data, for example:
+
+    --rules "max-eth-per-sc=0.00002 & max-anti-spam-share=10 | rel-diff-usd=2"
+
+Terms joined with '&' all need to match, for terms joined with '|' one match is
+enough ('&' binds more strongly). Available terms are max-eth-per-sc (ETH paid
+per SC, including the anti-spam fee), max-total-eth (ETH paid including the
+anti-spam fee), max-anti-spam-share (anti-spam fee as a percentage of the total),
+abs-diff-usd and rel-diff-usd (like --abs-diff-rule and --rel-diff-rule; a
+negative value only accepts offers below the market rate). With
+--binding-tolerance, a binding offer within the given percentage of the
+non-binding offer is accepted without checking the rules again.
+
+With --approval-command, every decision is delegated to an external program
+instead. For each decision the program is started once and receives a JSON
+object on stdin with the fields "method" ("approve_offer" or
+"check_similarity"), "siacoin" (in hastings), "offer" (or "offer_a" and
+"offer_b" when comparing a non-binding with a binding offer; amounts in wei),
+"binding" and "rates" (USD prices of one ETH and one SC). It has to answer on
+stdout with {"accept": true} or {"accept": false}. A non-zero exit status
+aborts the swap.`,
 		Args: cobra.ExactArgs(1),
 		Run:  runBuy,
 	}
 	cmdBuy.Flags().Int64VarP(&fundingConfirmations, "sia-confs", "c", fundingConfirmations, "Sia confirmations to require before proceeding with a swap")
 	cmdBuy.Flags().BoolVarP(&useExchangeRate, "usd-amounts", "$", useExchangeRate, "show approximate USD amounts based on data from CoinMarketCap")
+	cmdBuy.Flags().BoolVar(&useTUI, "tui", useTUI, "follow the swap in a full-screen view")
+	cmdBuy.Flags().BoolVarP(&pickOffer, "pick-offer", "p", pickOffer, "list all offers (with the number of past purchases from each server) and choose one instead of only being shown the best one")
 	cmdBuy.Flags().Int64VarP(&similarityPercentage, "similarity-percentage", "s", similarityPercentage, "consider offers within this range similar enough to not prompt the user again")
 	cmdBuy.Flags().Float64Var(&absDiffRule, "abs-diff-rule", absDiffRule, "absolute difference rule for rule-based offer decision; see help for details")
 	cmdBuy.Flags().Float64Var(&relDiffRule, "rel-diff-rule", relDiffRule, "relative difference rule in percentage for rule-based offer decision; see help for details")
+	cmdBuy.Flags().StringVar(&rules, "rules", rules, "rule expression for rule-based offer decision; see help for details")
+	cmdBuy.Flags().Int64Var(&bindingTolerance, "binding-tolerance", bindingTolerance, "accept binding offers within this percentage of the non-binding offer without checking the rules again; negative to disable")
+	cmdBuy.Flags().StringVar(&approvalCommand, "approval-command", approvalCommand, "external program that decides on offers; see help for details")
 	cmdBuy.Flags().Float64Var(&maxAntiSpamFeeInEther, "max-anti-spam-fee", maxAntiSpamFeeInEther, "maximum anti spam fee (in ether) to accept")
+	cmdBuy.Flags().BoolVarP(&etherBudget, "ether", "e", etherBudget, "interpret amount as ether budget instead of SC amount")
+	cmdBuy.Flags().StringVar(&siaAddress, "sia-address", siaAddress, "send purchased siacoins to this address instead of the local wallet")
+	cmdBuy.Flags().StringSliceVar(&webhookURLs, "webhook", webhookURLs, "URL to post swap events to (may be repeated)")
+	cmdBuy.Flags().StringVar(&webhookSecretFile, "webhook-secret-file", webhookSecretFile, "path to file with secret for signing webhook requests")
+	cmdBuy.Flags().StringVar(&historyFile, "history-file", historyFile, "path to history file for recording completed purchases")
+
+	cmdSchedule := &cobra.Command{
+		Use:   "schedule",
+		Short: "Buy siacoins on a recurring schedule",
+		Long: `Buy siacoins on a recurring schedule.
+
+A schedule is created once and then executed by 'roadie schedule run', which
+keeps running and performs a purchase whenever one is due. Offers are accepted
+or declined based on --abs-diff-rule and --rel-diff-rule or on a rule expression
+given with --rules (see 'roadie help buy' for details); at least one of them is
+required. If no acceptable offer is available, the purchase is skipped until the
+next scheduled time. The schedule and the results of all runs are stored in the
+schedule file.
+
+Ether and anti-spam fees paid by runs that failed count towards --max-total.
+Such runs record their anti-spam ID, which is needed to get a deposit back
+with 'roadie reclaim'.`,
+	}
+	cmdSchedule.PersistentFlags().StringVar(&scheduleFile, "schedule-file", scheduleFile, "path to schedule file")
+
+	cmdScheduleCreate := &cobra.Command{
+		Use:   "create [amount]",
+		Short: "Create a new schedule (replacing an existing one)",
+		Args:  cobra.ExactArgs(1),
+		Run:   runScheduleCreate,
+	}
+	cmdScheduleCreate.Flags().DurationVar(&scheduleInterval, "every", scheduleInterval, "time between purchases (for example 24h)")
+	cmdScheduleCreate.Flags().BoolVarP(&etherBudget, "ether", "e", etherBudget, "interpret amount as ether budget instead of SC amount")
+	cmdScheduleCreate.Flags().StringVar(&siaAddress, "sia-address", siaAddress, "send purchased siacoins to this address instead of the local wallet")
+	cmdScheduleCreate.Flags().StringVar(&maxTotalEther, "max-total", maxTotalEther, "stop buying once this much ether (including anti-spam fees) has been spent")
+	cmdScheduleCreate.Flags().Float64Var(&absDiffRule, "abs-diff-rule", absDiffRule, "absolute difference rule for offer decision")
+	cmdScheduleCreate.Flags().Float64Var(&relDiffRule, "rel-diff-rule", relDiffRule, "relative difference rule in percentage for offer decision")
+	cmdScheduleCreate.Flags().StringVar(&rules, "rules", rules, "rule expression for offer decision")
+
+	cmdScheduleRun := &cobra.Command{
+		Use:   "run",
+		Short: "Keep running and perform scheduled purchases",
+		Run:   runScheduleRun,
+	}
+	cmdScheduleRun.Flags().Int64VarP(&fundingConfirmations, "sia-confs", "c", fundingConfirmations, "Sia confirmations to require before proceeding with a swap")
+	cmdScheduleRun.Flags().StringSliceVar(&webhookURLs, "webhook", webhookURLs, "URL to post swap events to (may be repeated)")
+	cmdScheduleRun.Flags().StringVar(&webhookSecretFile, "webhook-secret-file", webhookSecretFile, "path to file with secret for signing webhook requests")
+	cmdScheduleRun.Flags().StringVar(&historyFile, "history-file", historyFile, "path to history file for recording completed purchases")
+	cmdScheduleRun.Flags().Float64Var(&maxAntiSpamFeeInEther, "max-anti-spam-fee", maxAntiSpamFeeInEther, "maximum anti spam fee (in ether) to accept")
+
+	cmdScheduleSummary := &cobra.Command{
+		Use:   "summary",
+		Short: "List all scheduled purchases so far",
+		Run:   runScheduleSummary,
+	}
+	cmdSchedule.AddCommand(cmdScheduleCreate, cmdScheduleRun, cmdScheduleSummary)
+
+	descHistory := "List completed purchases"
+	cmdHistory := &cobra.Command{
+		Use:   "history",
+		Short: descHistory,
+		Long: fmt.Sprintf(`%s.
+
+Every purchase completed with 'roadie buy' or 'roadie schedule run' is recorded
+with its date, server, the siacoins received, the ether paid, the anti-spam fee
+burned, the gas paid for burning the anti-spam fee and for the deposit, the Sia
+claim transaction ID and the Ethereum transaction hashes. With --export csv or
+--export json, all records are printed in that format, for example for
+accounting or taxes. CSV amounts are in SC and ETH, JSON amounts in hastings
+and wei.`, descHistory),
+		Run: runHistory,
+	}
+	cmdHistory.Flags().StringVar(&exportFormat, "export", exportFormat, "print all records as 'csv' or 'json'")
+	cmdHistory.Flags().StringVar(&historyFile, "history-file", historyFile, "path to history file")
+
+	descReport := "Report profit and loss of completed swaps as a server"
+	cmdReport := &cobra.Command{
+		Use:   "report",
+		Short: descReport,
+		Long: fmt.Sprintf(`%s.
+
+While running, 'roadie serve' records every completed swap: the siacoins sent
+and the miner fees paid, the ether received, the fee paid to claim it, the
+anti-spam fee paid by the buyer and the USD exchange rates at the time.
+Escrowed anti-spam fees that the server keeps after a failed swap are recorded
+as well and count towards profit. This command sums these up by day (in UTC).
+With --csv, it instead prints one line per swap in CSV format, suitable for
+accounting.`, descReport),
+		Run: runReport,
+	}
+	cmdReport.Flags().BoolVar(&csvExport, "csv", csvExport, "print all recorded swaps as CSV instead of daily totals")
+	cmdReport.Flags().StringVar(&ledgerFile, "ledger-file", ledgerFile, "path to ledger file")
+
+	descBacktest := "Evaluate a pricing strategy against historical exchange rates"
+	cmdBacktest := &cobra.Command{
+		Use:   "backtest",
+		Short: descBacktest,
+		Long: fmt.Sprintf(`%s.
+
+Replays a series of exchange rates (--rates, a CSV file with the columns time,
+USD per ETH and USD per SC) and a stream of buy requests against the pricing
+strategy selected with the same options as for 'roadie serve'. Times are given
+in RFC 3339 format or as Unix time. Requests are read from --requests (a CSV
+file with the columns time and amount in SC) or generated at random: on
+average --requests-per-hour, with amounts between --min-request and
+--max-request.
+
+Blockchains are simulated: the server starts with --balance siacoins and pays
+--gas-price for its transactions. Buyers accept an offer if it is at most
+--tolerance percent above the market value of the siacoins; a swap takes
+--swap-duration to complete. After each accepted offer, no further offers are
+made for --funding-duration, the time the server needs to fund the swap
+(pricing strategies may end the pause earlier). The report shows the share of
+requests that were filled, revenue and profit in USD, how much of the inventory
+was used and the worst-case exposure: the highest value of siacoins in open
+swaps and the worst result of a single swap if the ether lost value until
+completion.
+
+Pricing plugins (--trader-plugin) cannot be backtested, as they do not see the
+replayed exchange rates.`, descBacktest),
+		Run: runBacktest,
+	}
+	cmdBacktest.Flags().StringVar(&ratesFile, "rates", ratesFile, "path to CSV file with exchange rates")
+	cmdBacktest.Flags().StringVar(&requestsFile, "requests", requestsFile, "path to CSV file with requests; generated at random if not set")
+	cmdBacktest.Flags().Float64Var(&requestsPerHour, "requests-per-hour", requestsPerHour, "average number of random requests per hour")
+	cmdBacktest.Flags().StringVar(&minRequest, "min-request", minRequest, "smallest random request in SC")
+	cmdBacktest.Flags().StringVar(&maxRequest, "max-request", maxRequest, "largest random request in SC")
+	cmdBacktest.Flags().Int64Var(&seed, "seed", seed, "seed for random requests")
+	cmdBacktest.Flags().StringVar(&backtestBalance, "balance", backtestBalance, "initial balance of the server in SC")
+	cmdBacktest.Flags().Int64Var(&gasPriceInGwei, "gas-price", gasPriceInGwei, "gas price in Gwei")
+	cmdBacktest.Flags().StringVar(&buyerTolerance, "tolerance", buyerTolerance, "percentage above market value buyers accept")
+	cmdBacktest.Flags().DurationVar(&swapDuration, "swap-duration", swapDuration, "time from offer to completion of a swap")
+	cmdBacktest.Flags().DurationVar(&fundingDuration, "funding-duration", fundingDuration, "time from offer until the server has funded a swap")
+	addStrategyFlags(cmdBacktest)
+
+	descQuote := "Show indicative prices of all servers"
+	cmdQuote := &cobra.Command{
+		Use:   "quote",
+		Short: descQuote,
+		Long: fmt.Sprintf(`%s.
+
+Servers quote the price at which they would currently sell a reference amount
+of siacoins, next to the market value of that amount derived from current USD
+exchange rates. The price is raised with the recent volatility of the rates.
+Quotes are indicative only - a swap always starts with a new offer.`, descQuote),
+		Run: runQuote,
+	}
 
 	descReclaim := "Reclaim deposit after a failed atomic swap"
 	cmdReclaim := &cobra.Command{
@@ -305,8 +1387,9 @@ need to match for the offer to be accepted. Otherwise the offer will be rejected
 		Run:   runInit,
 	}
 
-	rootCmd := &cobra.Command{Use: "roadie"}
-	rootCmd.AddCommand(cmdServe, cmdBuy, cmdReclaim, cmdInit)
+	rootCmd := &cobra.Command{Use: "roadie", PersistentPreRun: setupOutput}
+	rootCmd.AddCommand(cmdServe, cmdBuy, cmdSchedule, cmdHistory, cmdReport, cmdQuote, cmdBacktest, cmdReclaim, cmdInit)
+	rootCmd.PersistentFlags().StringVarP(&outputFormat, "output", "o", outputFormat, "output format: 'text' or 'json' (one event per line)")
 	rootCmd.PersistentFlags().StringVar(&contractAddressHex, "contract", contractAddressHex, "registry contract; set to empty string to deploy a new one")
 	rootCmd.PersistentFlags().StringVar(&siaPasswordFile, "sia-password-file", siaPasswordFile, "path to Sia API password file")
 	rootCmd.PersistentFlags().StringVar(&siaDaemonAddress, "sia-daemon", siaDaemonAddress, "host and port of Sia daemon")
@@ -317,6 +1400,6 @@ need to match for the offer to be accepted. Otherwise the offer will be rejected
 
 	err := rootCmd.Execute()
 	if err != nil {
-		log.Fatal(err)
+		fail(err)
 	}
 }
@@ -1,4 +1,5 @@
-pragma solidity ^0.5.10;
+// SPDX-License-Identifier: MIT
+pragma solidity ^0.8.21;
 
 // Using formulas from https://hyperelliptic.org/EFD/g1p/auto-twisted-projective.html
 // and constants from https://tools.ietf.org/html/draft-josefsson-eddsa-ed25519-03
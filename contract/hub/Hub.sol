@@ -1,15 +1,25 @@
-pragma solidity ^0.5.10;
+// SPDX-License-Identifier: MIT
+pragma solidity ^0.8.21;
 
 import "./Ed25519.sol";
 
 contract Hub is Ed25519 {
-    address payable constant BLACK_HOLE = 0x0000000000000000000000000000000000000000;
+    address payable constant BLACK_HOLE = payable(address(0));
     uint constant DEPOSIT_DURATION = 2 hours;
     uint constant DEPOSIT_DURATION_MARGIN = 30 minutes;
+    uint constant ESCROW_DURATION = 6 hours;
 
+    // An anti-spam fee is either burned or, if server is set, held in escrow:
+    // it is credited back to the sender if the server claims a deposit made
+    // to it. If the deposit is reclaimed instead, the fee is credited to the
+    // server. Without a deposit, the server can collect the fee after the
+    // deadline.
     struct AntiSpamFee {
         uint fee;
         uint blockNumber;
+        address payable sender;
+        address payable server;
+        uint deadline;
     }
 
     struct Deposit {
@@ -34,20 +44,23 @@ contract Hub is Ed25519 {
     mapping(uint => Server) public servers;
     uint public nextServerID = 0;
 
-    string public version = "0.1.0";
+    string public version = "0.3.0";
     bool public deprecated = false;
     address public admin;
+    mapping(address => uint) public credits;
 
     modifier onlyAdmin {
         require(msg.sender == admin);
         _;
     }
 
-    constructor() public {
+    constructor() {
         admin = msg.sender;
     }
 
     function burnAntiSpamFee(bytes32 hashedID) external payable {
+        require(antiSpamFees[hashedID].server == BLACK_HOLE);
+
         antiSpamFees[hashedID].fee += msg.value;
         antiSpamFees[hashedID].blockNumber = block.number;
         BLACK_HOLE.transfer(msg.value);
@@ -56,13 +69,53 @@ contract Hub is Ed25519 {
     function checkAntiSpamConfirmations(uint id, uint fee) external view returns (uint) {
         bytes32 hashedID = hash(id);
 
-        if (antiSpamFees[hashedID].fee < fee) {
+        if (antiSpamFees[hashedID].fee < fee ||
+            antiSpamFees[hashedID].server != BLACK_HOLE) {
+            return 0;
+        } else {
+            return block.number - antiSpamFees[hashedID].blockNumber;
+        }
+    }
+
+    function escrowAntiSpamFee(bytes32 hashedID, address payable server) external payable {
+        require(antiSpamFees[hashedID].blockNumber == 0);
+        require(server != BLACK_HOLE);
+
+        antiSpamFees[hashedID].fee = msg.value;
+        antiSpamFees[hashedID].blockNumber = block.number;
+        antiSpamFees[hashedID].sender = payable(msg.sender);
+        antiSpamFees[hashedID].server = server;
+        antiSpamFees[hashedID].deadline = block.timestamp + ESCROW_DURATION;
+    }
+
+    function checkEscrowConfirmations(uint id, uint fee, address server) external view returns (uint) {
+        bytes32 hashedID = hash(id);
+
+        if (antiSpamFees[hashedID].fee < fee ||
+            antiSpamFees[hashedID].server != server ||
+            antiSpamFees[hashedID].deadline < block.timestamp + DEPOSIT_DURATION_MARGIN) {
             return 0;
         } else {
             return block.number - antiSpamFees[hashedID].blockNumber;
         }
     }
 
+    function collectAntiSpamFee(bytes32 hashedID) external {
+        require(antiSpamFees[hashedID].deadline < block.timestamp);
+        require(antiSpamFees[hashedID].server == msg.sender);
+        require(deposits[hashedID].blockNumber == 0);
+
+        uint fee = antiSpamFees[hashedID].fee;
+        delete antiSpamFees[hashedID];
+        payable(msg.sender).transfer(fee);
+    }
+
+    function withdrawCredit() external {
+        uint value = credits[msg.sender];
+        credits[msg.sender] = 0;
+        payable(msg.sender).transfer(value);
+    }
+
     function depositEther(address recipient, uint adaptorPubKey, bytes32 hashedAntiSpamID) external payable {
         require(deposits[hashedAntiSpamID].blockNumber == 0);
 
@@ -71,7 +124,7 @@ contract Hub is Ed25519 {
         deposits[hashedAntiSpamID].adaptorPubKey = adaptorPubKey;
         deposits[hashedAntiSpamID].value = msg.value;
         deposits[hashedAntiSpamID].blockNumber = block.number;
-        deposits[hashedAntiSpamID].deadline = now + DEPOSIT_DURATION;
+        deposits[hashedAntiSpamID].deadline = block.timestamp + DEPOSIT_DURATION;
     }
 
     function checkDepositConfirmations(address recipient, uint adaptorPubKey,
@@ -79,7 +132,7 @@ contract Hub is Ed25519 {
         if (deposits[hashedAntiSpamID].recipient != recipient ||
             deposits[hashedAntiSpamID].adaptorPubKey != adaptorPubKey ||
             deposits[hashedAntiSpamID].value < value ||
-            deposits[hashedAntiSpamID].deadline - DEPOSIT_DURATION_MARGIN < now) {
+            deposits[hashedAntiSpamID].deadline < block.timestamp + DEPOSIT_DURATION_MARGIN) {
             return 0;
         } else {
             return block.number - deposits[hashedAntiSpamID].blockNumber;
@@ -87,8 +140,14 @@ contract Hub is Ed25519 {
     }
 
     function claimDeposit(uint adaptorPrivKey, uint antiSpamID) external {
+        claimDepositTo(adaptorPrivKey, antiSpamID, payable(msg.sender));
+    }
+
+    // Like claimDeposit, but pays the deposit to a separate address, so that
+    // the claiming account only needs to hold enough ether for gas.
+    function claimDepositTo(uint adaptorPrivKey, uint antiSpamID, address payable payout) public {
         bytes32 hashedAntiSpamID = hash(antiSpamID);
-        require(deposits[hashedAntiSpamID].deadline >= now);
+        require(deposits[hashedAntiSpamID].deadline >= block.timestamp);
         require(deposits[hashedAntiSpamID].recipient == msg.sender);
         require(adaptorPrivKey != 0);
 
@@ -98,24 +157,36 @@ contract Hub is Ed25519 {
 
         uint value = deposits[hashedAntiSpamID].value;
         delete deposits[hashedAntiSpamID];
-        delete antiSpamFees[hashedAntiSpamID];
-        msg.sender.transfer(value);
+        settleAntiSpamFee(hashedAntiSpamID, antiSpamFees[hashedAntiSpamID].server == msg.sender);
+        payout.transfer(value);
     }
 
     function reclaimDeposit(bytes32 hashedAntiSpamID) external {
-        require(deposits[hashedAntiSpamID].deadline < now);
+        require(deposits[hashedAntiSpamID].deadline < block.timestamp);
         require(deposits[hashedAntiSpamID].sender == msg.sender);
 
         uint value = deposits[hashedAntiSpamID].value;
         delete deposits[hashedAntiSpamID];
-        delete antiSpamFees[hashedAntiSpamID];
-        msg.sender.transfer(value);
+        settleAntiSpamFee(hashedAntiSpamID, false);
+        payable(msg.sender).transfer(value);
+    }
+
+    // Credits an escrowed anti-spam fee back to its sender if refund is set
+    // and to the server otherwise. Credits have to be withdrawn separately,
+    // so that a failing transfer cannot block a claim.
+    function settleAntiSpamFee(bytes32 hashedID, bool refund) private {
+        address server = antiSpamFees[hashedID].server;
+        if (server != BLACK_HOLE) {
+            address target = refund ? antiSpamFees[hashedID].sender : server;
+            credits[target] += antiSpamFees[hashedID].fee;
+        }
+        delete antiSpamFees[hashedID];
     }
 
     function registerServer(string calldata target, bytes calldata cert) external {
         servers[nextServerID].target = target;
         servers[nextServerID].cert = cert;
-        servers[nextServerID].timestamp = now;
+        servers[nextServerID].timestamp = block.timestamp;
         nextServerID += 1;
     }
 
@@ -127,7 +198,7 @@ contract Hub is Ed25519 {
         }
 
         uint id = nextServerID - offset - 1;
-        if (servers[id].timestamp + maxAge < now) {
+        if (block.timestamp - servers[id].timestamp > maxAge) {
             return (false, "", "");
         }
 
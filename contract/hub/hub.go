@@ -28,10 +28,10 @@ var (
 )
 
 // HubABI is the input ABI used to generate the binding from.
-const HubABI = "[{\"constant\":true,\"inputs\":[],\"name\":\"deprecated\",\"outputs\":[{\"name\":\"\",\"type\":\"bool\"}],\"payable\":false,\"stateMutability\":\"view\",\"type\":\"function\"},{\"constant\":true,\"inputs\":[{\"name\":\"\",\"type\":\"bytes32\"}],\"name\":\"deposits\",\"outputs\":[{\"name\":\"sender\",\"type\":\"address\"},{\"name\":\"recipient\",\"type\":\"address\"},{\"name\":\"adaptorPubKey\",\"type\":\"uint256\"},{\"name\":\"value\",\"type\":\"uint256\"},{\"name\":\"blockNumber\",\"type\":\"uint256\"},{\"name\":\"deadline\",\"type\":\"uint256\"}],\"payable\":false,\"stateMutability\":\"view\",\"type\":\"function\"},{\"constant\":true,\"inputs\":[],\"name\":\"version\",\"outputs\":[{\"name\":\"\",\"type\":\"string\"}],\"payable\":false,\"stateMutability\":\"view\",\"type\":\"function\"},{\"constant\":true,\"inputs\":[{\"name\":\"recipient\",\"type\":\"address\"},{\"name\":\"adaptorPubKey\",\"type\":\"uint256\"},{\"name\":\"value\",\"type\":\"uint256\"},{\"name\":\"hashedAntiSpamID\",\"type\":\"bytes32\"}],\"name\":\"checkDepositConfirmations\",\"outputs\":[{\"name\":\"\",\"type\":\"uint256\"}],\"payable\":false,\"stateMutability\":\"view\",\"type\":\"function\"},{\"constant\":true,\"inputs\":[{\"name\":\"\",\"type\":\"bytes32\"}],\"name\":\"antiSpamFees\",\"outputs\":[{\"name\":\"fee\",\"type\":\"uint256\"},{\"name\":\"blockNumber\",\"type\":\"uint256\"}],\"payable\":false,\"stateMutability\":\"view\",\"type\":\"function\"},{\"constant\":true,\"inputs\":[{\"name\":\"\",\"type\":\"uint256\"}],\"name\":\"servers\",\"outputs\":[{\"name\":\"target\",\"type\":\"string\"},{\"name\":\"cert\",\"type\":\"bytes\"},{\"name\":\"timestamp\",\"type\":\"uint256\"}],\"payable\":false,\"stateMutability\":\"view\",\"type\":\"function\"},{\"constant\":true,\"inputs\":[{\"name\":\"id\",\"type\":\"uint256\"},{\"name\":\"fee\",\"type\":\"uint256\"}],\"name\":\"checkAntiSpamConfirmations\",\"outputs\":[{\"name\":\"\",\"type\":\"uint256\"}],\"payable\":false,\"stateMutability\":\"view\",\"type\":\"function\"},{\"constant\":false,\"inputs\":[{\"name\":\"_version\",\"type\":\"string\"}],\"name\":\"setVersion\",\"outputs\":[],\"payable\":false,\"stateMutability\":\"nonpayable\",\"type\":\"function\"},{\"constant\":true,\"inputs\":[],\"name\":\"nextServerID\",\"outputs\":[{\"name\":\"\",\"type\":\"uint256\"}],\"payable\":false,\"stateMutability\":\"view\",\"type\":\"function\"},{\"constant\":false,\"inputs\":[{\"name\":\"target\",\"type\":\"string\"},{\"name\":\"cert\",\"type\":\"bytes\"}],\"name\":\"registerServer\",\"outputs\":[],\"payable\":false,\"stateMutability\":\"nonpayable\",\"type\":\"function\"},{\"constant\":false,\"inputs\":[{\"name\":\"hashedID\",\"type\":\"bytes32\"}],\"name\":\"burnAntiSpamFee\",\"outputs\":[],\"payable\":true,\"stateMutability\":\"payable\",\"type\":\"function\"},{\"constant\":true,\"inputs\":[{\"name\":\"id\",\"type\":\"uint256\"}],\"name\":\"hash\",\"outputs\":[{\"name\":\"\",\"type\":\"bytes32\"}],\"payable\":false,\"stateMutability\":\"pure\",\"type\":\"function\"},{\"constant\":false,\"inputs\":[{\"name\":\"recipient\",\"type\":\"address\"},{\"name\":\"adaptorPubKey\",\"type\":\"uint256\"},{\"name\":\"hashedAntiSpamID\",\"type\":\"bytes32\"}],\"name\":\"depositEther\",\"outputs\":[],\"payable\":true,\"stateMutability\":\"payable\",\"type\":\"function\"},{\"constant\":true,\"inputs\":[{\"name\":\"s\",\"type\":\"uint256\"}],\"name\":\"scalarMultBase\",\"outputs\":[{\"name\":\"\",\"type\":\"uint256\"},{\"name\":\"\",\"type\":\"uint256\"}],\"payable\":false,\"stateMutability\":\"view\",\"type\":\"function\"},{\"constant\":false,\"inputs\":[{\"name\":\"_deprecated\",\"type\":\"bool\"}],\"name\":\"setDeprecated\",\"outputs\":[],\"payable\":false,\"stateMutability\":\"nonpayable\",\"type\":\"function\"},{\"constant\":true,\"inputs\":[{\"name\":\"\",\"type\":\"uint256\"}],\"name\":\"adaptorPrivKeys\",\"outputs\":[{\"name\":\"\",\"type\":\"uint256\"}],\"payable\":false,\"stateMutability\":\"view\",\"type\":\"function\"},{\"constant\":true,\"inputs\":[{\"name\":\"maxAge\",\"type\":\"uint256\"},{\"name\":\"offset\",\"type\":\"uint256\"}],\"name\":\"fetchServer\",\"outputs\":[{\"name\":\"\",\"type\":\"bool\"},{\"name\":\"\",\"type\":\"string\"},{\"name\":\"\",\"type\":\"bytes\"}],\"payable\":false,\"stateMutability\":\"view\",\"type\":\"function\"},{\"constant\":false,\"inputs\":[{\"name\":\"adaptorPrivKey\",\"type\":\"uint256\"},{\"name\":\"antiSpamID\",\"type\":\"uint256\"}],\"name\":\"claimDeposit\",\"outputs\":[],\"payable\":false,\"stateMutability\":\"nonpayable\",\"type\":\"function\"},{\"constant\":true,\"inputs\":[],\"name\":\"admin\",\"outputs\":[{\"name\":\"\",\"type\":\"address\"}],\"payable\":false,\"stateMutability\":\"view\",\"type\":\"function\"},{\"constant\":false,\"inputs\":[{\"name\":\"hashedAntiSpamID\",\"type\":\"bytes32\"}],\"name\":\"reclaimDeposit\",\"outputs\":[],\"payable\":false,\"stateMutability\":\"nonpayable\",\"type\":\"function\"},{\"inputs\":[],\"payable\":false,\"stateMutability\":\"nonpayable\",\"type\":\"constructor\"}]"
+const HubABI = "[{\"inputs\":[],\"stateMutability\":\"nonpayable\",\"type\":\"constructor\"},{\"inputs\":[{\"internalType\":\"uint256\",\"name\":\"\",\"type\":\"uint256\"}],\"name\":\"adaptorPrivKeys\",\"outputs\":[{\"internalType\":\"uint256\",\"name\":\"\",\"type\":\"uint256\"}],\"stateMutability\":\"view\",\"type\":\"function\",\"constant\":true},{\"inputs\":[],\"name\":\"admin\",\"outputs\":[{\"internalType\":\"address\",\"name\":\"\",\"type\":\"address\"}],\"stateMutability\":\"view\",\"type\":\"function\",\"constant\":true},{\"inputs\":[{\"internalType\":\"bytes32\",\"name\":\"\",\"type\":\"bytes32\"}],\"name\":\"antiSpamFees\",\"outputs\":[{\"internalType\":\"uint256\",\"name\":\"fee\",\"type\":\"uint256\"},{\"internalType\":\"uint256\",\"name\":\"blockNumber\",\"type\":\"uint256\"},{\"internalType\":\"addresspayable\",\"name\":\"sender\",\"type\":\"address\"},{\"internalType\":\"addresspayable\",\"name\":\"server\",\"type\":\"address\"},{\"internalType\":\"uint256\",\"name\":\"deadline\",\"type\":\"uint256\"}],\"stateMutability\":\"view\",\"type\":\"function\",\"constant\":true},{\"inputs\":[{\"internalType\":\"bytes32\",\"name\":\"hashedID\",\"type\":\"bytes32\"}],\"name\":\"burnAntiSpamFee\",\"outputs\":[],\"stateMutability\":\"payable\",\"type\":\"function\"},{\"inputs\":[{\"internalType\":\"uint256\",\"name\":\"id\",\"type\":\"uint256\"},{\"internalType\":\"uint256\",\"name\":\"fee\",\"type\":\"uint256\"}],\"name\":\"checkAntiSpamConfirmations\",\"outputs\":[{\"internalType\":\"uint256\",\"name\":\"\",\"type\":\"uint256\"}],\"stateMutability\":\"view\",\"type\":\"function\",\"constant\":true},{\"inputs\":[{\"internalType\":\"address\",\"name\":\"recipient\",\"type\":\"address\"},{\"internalType\":\"uint256\",\"name\":\"adaptorPubKey\",\"type\":\"uint256\"},{\"internalType\":\"uint256\",\"name\":\"value\",\"type\":\"uint256\"},{\"internalType\":\"bytes32\",\"name\":\"hashedAntiSpamID\",\"type\":\"bytes32\"}],\"name\":\"checkDepositConfirmations\",\"outputs\":[{\"internalType\":\"uint256\",\"name\":\"\",\"type\":\"uint256\"}],\"stateMutability\":\"view\",\"type\":\"function\",\"constant\":true},{\"inputs\":[{\"internalType\":\"uint256\",\"name\":\"id\",\"type\":\"uint256\"},{\"internalType\":\"uint256\",\"name\":\"fee\",\"type\":\"uint256\"},{\"internalType\":\"address\",\"name\":\"server\",\"type\":\"address\"}],\"name\":\"checkEscrowConfirmations\",\"outputs\":[{\"internalType\":\"uint256\",\"name\":\"\",\"type\":\"uint256\"}],\"stateMutability\":\"view\",\"type\":\"function\",\"constant\":true},{\"inputs\":[{\"internalType\":\"uint256\",\"name\":\"adaptorPrivKey\",\"type\":\"uint256\"},{\"internalType\":\"uint256\",\"name\":\"antiSpamID\",\"type\":\"uint256\"}],\"name\":\"claimDeposit\",\"outputs\":[],\"stateMutability\":\"nonpayable\",\"type\":\"function\"},{\"inputs\":[{\"internalType\":\"uint256\",\"name\":\"adaptorPrivKey\",\"type\":\"uint256\"},{\"internalType\":\"uint256\",\"name\":\"antiSpamID\",\"type\":\"uint256\"},{\"internalType\":\"addresspayable\",\"name\":\"payout\",\"type\":\"address\"}],\"name\":\"claimDepositTo\",\"outputs\":[],\"stateMutability\":\"nonpayable\",\"type\":\"function\"},{\"inputs\":[{\"internalType\":\"bytes32\",\"name\":\"hashedID\",\"type\":\"bytes32\"}],\"name\":\"collectAntiSpamFee\",\"outputs\":[],\"stateMutability\":\"nonpayable\",\"type\":\"function\"},{\"inputs\":[{\"internalType\":\"address\",\"name\":\"\",\"type\":\"address\"}],\"name\":\"credits\",\"outputs\":[{\"internalType\":\"uint256\",\"name\":\"\",\"type\":\"uint256\"}],\"stateMutability\":\"view\",\"type\":\"function\",\"constant\":true},{\"inputs\":[{\"internalType\":\"address\",\"name\":\"recipient\",\"type\":\"address\"},{\"internalType\":\"uint256\",\"name\":\"adaptorPubKey\",\"type\":\"uint256\"},{\"internalType\":\"bytes32\",\"name\":\"hashedAntiSpamID\",\"type\":\"bytes32\"}],\"name\":\"depositEther\",\"outputs\":[],\"stateMutability\":\"payable\",\"type\":\"function\"},{\"inputs\":[{\"internalType\":\"bytes32\",\"name\":\"\",\"type\":\"bytes32\"}],\"name\":\"deposits\",\"outputs\":[{\"internalType\":\"address\",\"name\":\"sender\",\"type\":\"address\"},{\"internalType\":\"address\",\"name\":\"recipient\",\"type\":\"address\"},{\"internalType\":\"uint256\",\"name\":\"adaptorPubKey\",\"type\":\"uint256\"},{\"internalType\":\"uint256\",\"name\":\"value\",\"type\":\"uint256\"},{\"internalType\":\"uint256\",\"name\":\"blockNumber\",\"type\":\"uint256\"},{\"internalType\":\"uint256\",\"name\":\"deadline\",\"type\":\"uint256\"}],\"stateMutability\":\"view\",\"type\":\"function\",\"constant\":true},{\"inputs\":[],\"name\":\"deprecated\",\"outputs\":[{\"internalType\":\"bool\",\"name\":\"\",\"type\":\"bool\"}],\"stateMutability\":\"view\",\"type\":\"function\",\"constant\":true},{\"inputs\":[{\"internalType\":\"bytes32\",\"name\":\"hashedID\",\"type\":\"bytes32\"},{\"internalType\":\"addresspayable\",\"name\":\"server\",\"type\":\"address\"}],\"name\":\"escrowAntiSpamFee\",\"outputs\":[],\"stateMutability\":\"payable\",\"type\":\"function\"},{\"inputs\":[{\"internalType\":\"uint256\",\"name\":\"maxAge\",\"type\":\"uint256\"},{\"internalType\":\"uint256\",\"name\":\"offset\",\"type\":\"uint256\"}],\"name\":\"fetchServer\",\"outputs\":[{\"internalType\":\"bool\",\"name\":\"\",\"type\":\"bool\"},{\"internalType\":\"string\",\"name\":\"\",\"type\":\"string\"},{\"internalType\":\"bytes\",\"name\":\"\",\"type\":\"bytes\"}],\"stateMutability\":\"view\",\"type\":\"function\",\"constant\":true},{\"inputs\":[{\"internalType\":\"uint256\",\"name\":\"id\",\"type\":\"uint256\"}],\"name\":\"hash\",\"outputs\":[{\"internalType\":\"bytes32\",\"name\":\"\",\"type\":\"bytes32\"}],\"stateMutability\":\"pure\",\"type\":\"function\",\"constant\":true},{\"inputs\":[],\"name\":\"nextServerID\",\"outputs\":[{\"internalType\":\"uint256\",\"name\":\"\",\"type\":\"uint256\"}],\"stateMutability\":\"view\",\"type\":\"function\",\"constant\":true},{\"inputs\":[{\"internalType\":\"bytes32\",\"name\":\"hashedAntiSpamID\",\"type\":\"bytes32\"}],\"name\":\"reclaimDeposit\",\"outputs\":[],\"stateMutability\":\"nonpayable\",\"type\":\"function\"},{\"inputs\":[{\"internalType\":\"string\",\"name\":\"target\",\"type\":\"string\"},{\"internalType\":\"bytes\",\"name\":\"cert\",\"type\":\"bytes\"}],\"name\":\"registerServer\",\"outputs\":[],\"stateMutability\":\"nonpayable\",\"type\":\"function\"},{\"inputs\":[{\"internalType\":\"uint256\",\"name\":\"s\",\"type\":\"uint256\"}],\"name\":\"scalarMultBase\",\"outputs\":[{\"internalType\":\"uint256\",\"name\":\"\",\"type\":\"uint256\"},{\"internalType\":\"uint256\",\"name\":\"\",\"type\":\"uint256\"}],\"stateMutability\":\"view\",\"type\":\"function\",\"constant\":true},{\"inputs\":[{\"internalType\":\"uint256\",\"name\":\"\",\"type\":\"uint256\"}],\"name\":\"servers\",\"outputs\":[{\"internalType\":\"string\",\"name\":\"target\",\"type\":\"string\"},{\"internalType\":\"bytes\",\"name\":\"cert\",\"type\":\"bytes\"},{\"internalType\":\"uint256\",\"name\":\"timestamp\",\"type\":\"uint256\"}],\"stateMutability\":\"view\",\"type\":\"function\",\"constant\":true},{\"inputs\":[{\"internalType\":\"bool\",\"name\":\"_deprecated\",\"type\":\"bool\"}],\"name\":\"setDeprecated\",\"outputs\":[],\"stateMutability\":\"nonpayable\",\"type\":\"function\"},{\"inputs\":[{\"internalType\":\"string\",\"name\":\"_version\",\"type\":\"string\"}],\"name\":\"setVersion\",\"outputs\":[],\"stateMutability\":\"nonpayable\",\"type\":\"function\"},{\"inputs\":[],\"name\":\"version\",\"outputs\":[{\"internalType\":\"string\",\"name\":\"\",\"type\":\"string\"}],\"stateMutability\":\"view\",\"type\":\"function\",\"constant\":true},{\"inputs\":[],\"name\":\"withdrawCredit\",\"outputs\":[],\"stateMutability\":\"nonpayable\",\"type\":\"function\"}]"
 
 // HubBin is the compiled bytecode used for deploying new contracts.
-var HubBin = "0x600060045560c0604052600560808190527f302e312e3000000000000000000000000000000000000000000000000000000060a09081526200004391908162000079565b506006805460ff191690553480156200005b57600080fd5b5060068054610100600160a81b03191633610100021790556200011e565b828054600181600116156101000203166002900490600052602060002090601f016020900481019282601f10620000bc57805160ff1916838001178555620000ec565b82800160010185558215620000ec579182015b82811115620000ec578251825591602001919060010190620000cf565b50620000fa929150620000fe565b5090565b6200011b91905b80821115620000fa576000815560010162000105565b90565b61166a806200012e6000396000f3fe60806040526004361061011f5760003560e01c8063ab80cdc2116100a0578063e74db5a911610064578063e74db5a91461064b578063e86ef23b14610675578063ea32a89e1461074d578063f851a4401461077d578063fa79c259146107ae5761011f565b8063ab80cdc21461057c578063b189fd4c14610599578063b90d104d146105c3578063c4f4912b146105f5578063d848dee71461061f5761011f565b80635cf0f357116100e75780635cf0f357146102da57806366db09c6146103e9578063788bc78c1461041957806395fcfa0c146104985780639f64195d146104ad5761011f565b80630e136b19146101245780633d4dff7b1461014d57806354fd4d50146101b657806357888e92146102405780635a161ba514610297575b600080fd5b34801561013057600080fd5b506101396107d8565b604080519115158252519081900360200190f35b34801561015957600080fd5b506101776004803603602081101561017057600080fd5b50356107e1565b604080516001600160a01b039788168152959096166020860152848601939093526060840191909152608083015260a082015290519081900360c00190f35b3480156101c257600080fd5b506101cb610825565b6040805160208082528351818301528351919283929083019185019080838360005b838110156102055781810151838201526020016101ed565b50505050905090810190601f1680156102325780820380516001836020036101000a031916815260200191505b509250505060405180910390f35b34801561024c57600080fd5b506102856004803603608081101561026357600080fd5b506001600160a01b0381351690602081013590604081013590606001356108b3565b60408051918252519081900360200190f35b3480156102a357600080fd5b506102c1600480360360208110156102ba57600080fd5b5035610958565b6040805192835260208301919091528051918290030190f35b3480156102e657600080fd5b50610304600480360360208110156102fd57600080fd5b5035610971565b604051808060200180602001848152602001838103835286818151815260200191508051906020019080838360005b8381101561034b578181015183820152602001610333565b50505050905090810190601f1680156103785780820380516001836020036101000a031916815260200191505b50838103825285518152855160209182019187019080838360005b838110156103ab578181015183820152602001610393565b50505050905090810190601f1680156103d85780820380516001836020036101000a031916815260200191505b509550505050505060405180910390f35b3480156103f557600080fd5b506102856004803603604081101561040c57600080fd5b5080359060200135610ab6565b34801561042557600080fd5b506104966004803603602081101561043c57600080fd5b81019060208101813564010000000081111561045757600080fd5b82018360208201111561046957600080fd5b8035906020019184600183028401116401000000008311171561048b57600080fd5b509092509050610b02565b005b3480156104a457600080fd5b50610285610b2f565b3480156104b957600080fd5b50610496600480360360408110156104d057600080fd5b8101906020810181356401000000008111156104eb57600080fd5b8201836020820111156104fd57600080fd5b8035906020019184600183028401116401000000008311171561051f57600080fd5b91939092909160208101903564010000000081111561053d57600080fd5b82018360208201111561054f57600080fd5b8035906020019184600183028401116401000000008311171561057157600080fd5b509092509050610b35565b6104966004803603602081101561059257600080fd5b5035610b98565b3480156105a557600080fd5b50610285600480360360208110156105bc57600080fd5b5035610be5565b610496600480360360608110156105d957600080fd5b506001600160a01b038135169060208101359060400135610c94565b34801561060157600080fd5b506102c16004803603602081101561061857600080fd5b5035610d0b565b34801561062b57600080fd5b506104966004803603602081101561064257600080fd5b50351515610dfe565b34801561065757600080fd5b506102856004803603602081101561066e57600080fd5b5035610e2d565b34801561068157600080fd5b506106a56004803603604081101561069857600080fd5b5080359060200135610e3f565b60405180841515151581526020018060200180602001838103835285818151815260200191508051906020019080838360005b838110156106f05781810151838201526020016106d8565b50505050905090810190601f16801561071d5780820380516001836020036101000a031916815260200191505b508381038252845181528451602091820191860190808383600083156103ab578181015183820152602001610393565b34801561075957600080fd5b506104966004803603604081101561077057600080fd5b5080359060200135610ffc565b34801561078957600080fd5b5061079261111f565b604080516001600160a01b039092168252519081900360200190f35b3480156107ba57600080fd5b50610496600480360360208110156107d157600080fd5b5035611133565b60065460ff1681565b60016020819052600091825260409091208054918101546002820154600383015460048401546005909401546001600160a01b039586169590931693919290919086565b6005805460408051602060026001851615610100026000190190941693909304601f810184900484028201840190925281815292918301828280156108ab5780601f10610880576101008083540402835291602001916108ab565b820191906000526020600020905b81548152906001019060200180831161088e57829003601f168201915b505050505081565b6000818152600160208190526040822001546001600160a01b0386811691161415806108f057506000828152600160205260409020600201548414155b8061090b575060008281526001602052604090206003015483115b8061092d57506000828152600160205260409020600501544261070719909101105b1561093a57506000610950565b5060008181526001602052604090206004015443035b949350505050565b6000602081905290815260409020805460019091015482565b60036020908152600091825260409182902080548351601f60026000196101006001861615020190931692909204918201849004840281018401909452808452909291839190830182828015610a085780601f106109dd57610100808354040283529160200191610a08565b820191906000526020600020905b8154815290600101906020018083116109eb57829003601f168201915b505050505090806001018054600181600116156101000203166002900480601f016020809104026020016040519081016040528092919081815260200182805460018160011615610100020316600290048015610aa65780601f10610a7b57610100808354040283529160200191610aa6565b820191906000526020600020905b815481529060010190602001808311610a8957829003601f168201915b5050505050908060020154905083565b600080610ac284610be5565b600081815260208190526040902054909150831115610ae5576000915050610afc565b600090815260208190526040902060010154430390505b92915050565b60065461010090046001600160a01b03163314610b1e57600080fd5b610b2a60058383611534565b505050565b60045481565b6004546000908152600360205260409020610b51908585611534565b506004546000908152600360205260409020610b71906001018383611534565b50506004805460009081526003602052604090204260029091015580546001019055505050565b600081815260208190526040808220805434908101825543600190920191909155905181156108fc02919083818181858288f19350505050158015610be1573d6000803e3d6000fd5b5050565b6000600282604051602001808281526020019150506040516020818303038152906040526040518082805190602001908083835b60208310610c385780518252601f199092019160209182019101610c19565b51815160209384036101000a60001901801990921691161790526040519190930194509192505080830381855afa158015610c77573d6000803e3d6000fd5b5050506040513d6020811015610c8c57600080fd5b505192915050565b60008181526001602052604090206004015415610cb057600080fd5b60009081526001602081905260409091208054336001600160a01b031991821617825591810180549092166001600160a01b03949094169390931790556002820155346003820155436004820155611c204201600590910155565b600080610d166115b2565b610d1e6115b2565b7f216936d3cd6e53fec0a4e231fdd6dc5c692cc7609525a7b2c9562d608f25d51a82527f66666666666666666666666666666666666666666666666666666666666666586020808401919091526001604080850182905260008452918301819052908201525b8415610dba578460011660011415610da357610da081836111fb565b90505b600185901c9450610db382611391565b9150610d84565b6000610dc982604001516114d4565b90506013600160ff1b03825182900982526013600160ff1b038183602001510960208301819052915194509092505050915091565b60065461010090046001600160a01b03163314610e1a57600080fd5b6006805460ff1916911515919091179055565b60026020526000908152604090205481565b60006060806004548410610e7357505060408051602080820183526000808352835191820190935282815291925090610ff5565b60045484900360001901600081815260036020526040902060020154429087011015610ec05750506040805160208082018352600080835283519182019093528281529193509150610ff5565b600081815260036020908152604091829020805483516002600180841615610100026000190190931604601f810185900485028201850190955284815290939192848401928491830182828015610f585780601f10610f2d57610100808354040283529160200191610f58565b820191906000526020600020905b815481529060010190602001808311610f3b57829003601f168201915b5050845460408051602060026001851615610100026000190190941693909304601f810184900484028201840190925281815295975086945092508401905082828015610fe65780601f10610fbb57610100808354040283529160200191610fe6565b820191906000526020600020905b815481529060010190602001808311610fc957829003601f168201915b50505050509050935093509350505b9250925092565b600061100782610be5565b60008181526001602052604090206005015490915042111561102857600080fd5b600081815260016020819052604090912001546001600160a01b0316331461104f57600080fd5b8261105957600080fd5b600061106484610d0b565b6000848152600160205260409020600201549092508214905061108657600080fd5b6000818152600260208181526040808420889055858452600180835281852060038101805482546001600160a01b03199081168455838501805490911690559582018790558690556004810186905560050185905591849052808420848155909101839055519091339183156108fc0291849190818181858888f19350505050158015611117573d6000803e3d6000fd5b505050505050565b60065461010090046001600160a01b031681565b600081815260016020526040902060050154421161115057600080fd5b6000818152600160205260409020546001600160a01b0316331461117357600080fd5b600081815260016020818152604080842060038101805482546001600160a01b031990811684558387018054909116905560028301879055908690556004820186905560059091018590559184905280842084815590920183905590519091339183156108fc0291849190818181858888f19350505050158015610b2a573d6000803e3d6000fd5b6112036115b2565b61120b6115d3565b6013600160ff1b03836040015185604001510981526013600160ff1b038151800960208201526013600160ff1b03835185510960408201526013600160ff1b03836020015185602001510960608201526013600160ff1b038082606001518360400151097f52036cee2b6ffe738cc740797779e89800700a4d4141d8ab75eb4dca135978a30960808201526013600160ff1b0381608001516013600160ff1b030382602001510860a08201526013600160ff1b03816080015182602001510860c08201526013600160ff1b038082606001516013600160ff1b03036013600160ff1b03806112f557fe5b84604001516013600160ff1b03036013600160ff1b038061131257fe5b6013600160ff1b0360208a01518a51086013600160ff1b0360208c01518c51080908086013600160ff1b0360a08401518451090982526013600160ff1b038082604001518360600151086013600160ff1b0360c08401518451090960208301526013600160ff1b038160c001518260a001510960408301525092915050565b6113996115b2565b6113a16115d3565b6013600160ff1b03602084015184510881526013600160ff1b038151800960208201526013600160ff1b038351800960408201526013600160ff1b03602084015180096060820181905260408201516013600160ff1b03908103608084018190529091900860a08201526013600160ff1b036040840151800960e08201526013600160ff1b03808260e001516002096013600160ff1b03038260a001510860c08201526013600160ff1b0360c08201516013600160ff1b0383606001516013600160ff1b03036013600160ff1b038061147657fe5b85604001516013600160ff1b0303866020015108080982526013600160ff1b038082606001516013600160ff1b03038360800151088260a001510960208301526013600160ff1b038160c001518260a0015109604083015250919050565b60008060026013600160ff1b0303905060006013600160ff1b03905060405160208152602080820152602060408201528460608201528260808201528160a082015260208160c0836005600019fa61152b57600080fd5b51949350505050565b828054600181600116156101000203166002900490600052602060002090601f016020900481019282601f106115755782800160ff198235161785556115a2565b828001600101855582156115a2579182015b828111156115a2578235825591602001919060010190611587565b506115ae929150611618565b5090565b60405180606001604052806000815260200160008152602001600081525090565b60405180610100016040528060008152602001600081526020016000815260200160008152602001600081526020016000815260200160008152602001600081525090565b61163291905b808211156115ae576000815560010161161e565b9056fea265627a7a723058204f33fc8e9418b12b01999f27372001917f3907dd3ce8141bbbbe0773260b588e64736f6c634300050a0032"
+var HubBin = "0x600060045560c0604052600560808181527f302e332e3000000000000000000000000000000000000000000000000000000060a0526200004090826200014d565b506006805460ff191690553480156200005857600080fd5b5060068054610100600160a81b031916336101000217905562000219565b7f4e487b7100000000000000000000000000000000000000000000000000000000600052604160045260246000fd5b600181811c90821680620000ba57607f821691505b602082108103620000f4577f4e487b7100000000000000000000000000000000000000000000000000000000600052602260045260246000fd5b50919050565b601f8211156200014857600081815260208120601f850160051c81016020861015620001235750805b601f850160051c820191505b8181101562000144578281556001016200012f565b5050505b505050565b81516001600160401b0381111562000169576200016962000076565b62000181816200017a8454620000a5565b84620000fa565b602080601f831160018114620001b95760008415620001a05750858301515b600019600386901b1c1916600185901b17855562000144565b600085815260208120601f198616915b82811015620001ea57888601518255948401946001909101908401620001c9565b5085821015620002095787850151600019600388901b60f8161c191681555b5050505050600190811b01905550565b611c7a80620002296000396000f3fe6080604052600436106101815760003560e01c8063ab80cdc2116100d1578063e74db5a91161008a578063f851a44011610064578063f851a4401461054d578063fa79c2591461058a578063fe5ff468146105aa578063ff1aab81146105d757600080fd5b8063e74db5a9146104d1578063e86ef23b146104fe578063ea32a89e1461052d57600080fd5b8063ab80cdc214610421578063b189fd4c14610434578063b90d104d14610454578063c36f387414610467578063c4f4912b1461047c578063d848dee7146104b157600080fd5b806357888e921161013e57806366db09c61161011857806366db09c6146103ab578063788bc78c146103cb57806395fcfa0c146103eb5780639f64195d1461040157600080fd5b806357888e92146102d15780635a161ba5146102f15780635cf0f3571461037c57600080fd5b80630e136b19146101865780633d4dff7b146101b55780634c0ae0a61461024c57806350b833e11461026157806354fd4d501461028f578063570ba8e3146102b1575b600080fd5b34801561019257600080fd5b506006546101a09060ff1681565b60405190151581526020015b60405180910390f35b3480156101c157600080fd5b506102146101d0366004611742565b60016020819052600091825260409091208054918101546002820154600383015460048401546005909401546001600160a01b039586169590931693919290919086565b604080516001600160a01b039788168152969095166020870152938501929092526060840152608083015260a082015260c0016101ac565b61025f61025a366004611773565b6105f7565b005b34801561026d57600080fd5b5061028161027c3660046117a3565b610690565b6040519081526020016101ac565b34801561029b57600080fd5b506102a4610734565b6040516101ac919061182c565b3480156102bd57600080fd5b5061025f6102cc3660046117a3565b6107c2565b3480156102dd57600080fd5b506102816102ec36600461183f565b610905565b3480156102fd57600080fd5b5061034861030c366004611742565b60006020819052908152604090208054600182015460028301546003840154600490940154929391926001600160a01b03918216929091169085565b6040805195865260208601949094526001600160a01b0392831693850193909352166060830152608082015260a0016101ac565b34801561038857600080fd5b5061039c610397366004611742565b6109b7565b6040516101ac9392919061187a565b3480156103b757600080fd5b506102816103c63660046118b0565b610ae9565b3480156103d757600080fd5b5061025f6103e636600461191b565b610b62565b3480156103f757600080fd5b5061028160045481565b34801561040d57600080fd5b5061025f61041c36600461195d565b610b90565b61025f61042f366004611742565b610c03565b34801561044057600080fd5b5061028161044f366004611742565b610c8c565b61025f6104623660046119c9565b610cfd565b34801561047357600080fd5b5061025f610d89565b34801561048857600080fd5b5061049c610497366004611742565b610dca565b604080519283526020830191909152016101ac565b3480156104bd57600080fd5b5061025f6104cc3660046119fe565b610ef4565b3480156104dd57600080fd5b506102816104ec366004611742565b60026020526000908152604090205481565b34801561050a57600080fd5b5061051e6105193660046118b0565b610f23565b6040516101ac93929190611a20565b34801561053957600080fd5b5061025f6105483660046118b0565b6110ff565b34801561055957600080fd5b506006546105729061010090046001600160a01b031681565b6040516001600160a01b0390911681526020016101ac565b34801561059657600080fd5b5061025f6105a5366004611742565b61110a565b3480156105b657600080fd5b506102816105c5366004611a57565b60076020526000908152604090205481565b3480156105e357600080fd5b5061025f6105f2366004611742565b6111cb565b6000828152602081905260409020600101541561061357600080fd5b6001600160a01b03811661062657600080fd5b6000828152602081905260409020348155436001820155600281018054336001600160a01b031991821617909155600390910180549091166001600160a01b03831617905561067761546042611a8a565b6000928352602083905260409092206004019190915550565b60008061069c85610c8c565b6000818152602081905260409020549091508411806106d857506000818152602081905260409020600301546001600160a01b03848116911614155b806106fe57506106ea61070842611a8a565b600082815260208190526040902060040154105b1561070d57600091505061072d565b6000818152602081905260409020600101546107299043611a9d565b9150505b9392505050565b6005805461074190611ab0565b80601f016020809104026020016040519081016040528092919081815260200182805461076d90611ab0565b80156107ba5780601f1061078f576101008083540402835291602001916107ba565b820191906000526020600020905b81548152906001019060200180831161079d57829003601f168201915b505050505081565b60006107cd83610c8c565b6000818152600160205260409020600501549091504211156107ee57600080fd5b600081815260016020819052604090912001546001600160a01b0316331461081557600080fd5b8360000361082257600080fd5b600061082d85610dca565b6000848152600160205260409020600201549092508214905061084f57600080fd5b600081815260026020818152604080842089905585845260018083528185206003808201805483546001600160a01b03199081168555948401805490951690945595820187905594869055600481018690556005018590559184905290922001546108c69084906001600160a01b0316331461129a565b6040516001600160a01b0385169082156108fc029083906000818181858888f193505050501580156108fc573d6000803e3d6000fd5b50505050505050565b6000818152600160208190526040822001546001600160a01b03868116911614158061094257506000828152600160205260409020600201548414155b8061095d575060008281526001602052604090206003015483115b80610983575061096f61070842611a8a565b600083815260016020526040902060050154105b15610990575060006109af565b6000828152600160205260409020600401546109ac9043611a9d565b90505b949350505050565b6003602052600090815260409020805481906109d290611ab0565b80601f01602080910402602001604051908101604052809291908181526020018280546109fe90611ab0565b8015610a4b5780601f10610a2057610100808354040283529160200191610a4b565b820191906000526020600020905b815481529060010190602001808311610a2e57829003601f168201915b505050505090806001018054610a6090611ab0565b80601f0160208091040260200160405190810160405280929190818152602001828054610a8c90611ab0565b8015610ad95780601f10610aae57610100808354040283529160200191610ad9565b820191906000526020600020905b815481529060010190602001808311610abc57829003601f168201915b5050505050908060020154905083565b600080610af584610c8c565b600081815260208190526040902054909150831180610b2d57506000818152602081905260409020600301546001600160a01b031615155b15610b3c576000915050610b5c565b600081815260208190526040902060010154610b589043611a9d565b9150505b92915050565b60065461010090046001600160a01b03163314610b7e57600080fd5b6005610b8b828483611b4e565b505050565b6004546000908152600360205260409020610bac848683611b4e565b506004546000908152600360205260409020600101610bcc828483611b4e565b506004805460009081526003602052604081204260029091015581546001929190610bf8908490611a8a565b909155505050505050565b6000818152602081905260409020600301546001600160a01b031615610c2857600080fd5b60008181526020819052604081208054349290610c46908490611a8a565b909155505060008181526020819052604080822043600190910155513480156108fc029183818181858288f19350505050158015610c88573d6000803e3d6000fd5b5050565b6000600282604051602001610ca391815260200190565b60408051601f1981840301815290829052610cbd91611c0f565b602060405180830381855afa158015610cda573d6000803e3d6000fd5b5050506040513d601f19601f82011682018060405250810190610b5c9190611c2b565b60008181526001602052604090206004015415610d1957600080fd5b60008181526001602081905260409091208054336001600160a01b031991821617825591810180549092166001600160a01b038616179091556002810183905534600382015543600490910155610d72611c2042611a8a565b600091825260016020526040909120600501555050565b33600081815260076020526040808220805490839055905190929183156108fc02918491818181858888f19350505050158015610c88573d6000803e3d6000fd5b600080610df160405180606001604052806000815260200160008152602001600081525090565b610e1560405180606001604052806000815260200160008152602001600081525090565b7f216936d3cd6e53fec0a4e231fdd6dc5c692cc7609525a7b2c9562d608f25d51a82527f66666666666666666666666666666666666666666666666666666666666666586020808401919091526001604080850182905260008452918301819052908201525b8415610eb05784600116600103610e9957610e968183611363565b90505b600185901c9450610ea98261150f565b9150610e7b565b6000610ebf8260400151611696565b90506013600160ff1b03825182900982526013600160ff1b038183602001510960208301819052915196919550909350505050565b60065461010090046001600160a01b03163314610f1057600080fd5b6006805460ff1916911515919091179055565b60006060806004548410610f57575050604080516020808201835260008083528351918201909352828152919250906110f8565b6000600185600454610f699190611a9d565b610f739190611a9d565b6000818152600360205260409020600201549091508690610f949042611a9d565b1115610fc157505060408051602080820183526000808352835191820190935282815291935091506110f8565b6000818152600360205260409020805460019190818301908290610fe490611ab0565b80601f016020809104026020016040519081016040528092919081815260200182805461101090611ab0565b801561105d5780601f106110325761010080835404028352916020019161105d565b820191906000526020600020905b81548152906001019060200180831161104057829003601f168201915b5050505050915080805461107090611ab0565b80601f016020809104026020016040519081016040528092919081815260200182805461109c90611ab0565b80156110e95780601f106110be576101008083540402835291602001916110e9565b820191906000526020600020905b8154815290600101906020018083116110cc57829003601f168201915b50505050509050935093509350505b9250925092565b610c888282336107c2565b600081815260016020526040902060050154421161112757600080fd5b6000818152600160205260409020546001600160a01b0316331461114a57600080fd5b6000818152600160208190526040822060038101805482546001600160a01b03199081168455938301805490941690935560028201849055839055600481018390556005018290559061119e90839061129a565b604051339082156108fc029083906000818181858888f19350505050158015610b8b573d6000803e3d6000fd5b60008181526020819052604090206004015442116111e857600080fd5b6000818152602081905260409020600301546001600160a01b0316331461120e57600080fd5b6000818152600160205260409020600401541561122a57600080fd5b6000818152602081905260408082208054838255600182018490556002820180546001600160a01b03199081169091556003830180549091169055600490910183905590519091339183156108fc0291849190818181858888f19350505050158015610b8b573d6000803e3d6000fd5b6000828152602081905260409020600301546001600160a01b03168015611323576000826112c857816112e4565b6000848152602081905260409020600201546001600160a01b03165b600085815260208181526040808320546001600160a01b0385168452600790925282208054939450909290919061131c908490611a8a565b9091555050505b50506000908152602081905260408120818155600181018290556002810180546001600160a01b0319908116909155600382018054909116905560040155565b61138760405180606001604052806000815260200160008152602001600081525090565b61138f6116fd565b6013600160ff1b03836040015185604001510981526013600160ff1b038151800960208201526013600160ff1b03835185510960408201526013600160ff1b03836020015185602001510960608201526013600160ff1b038082606001518360400151097f52036cee2b6ffe738cc740797779e89800700a4d4141d8ab75eb4dca135978a309608082018190526013600160ff1b039061142f9082611a9d565b82602001510860a08201526013600160ff1b03816080015182602001510860c08201526013600160ff1b03806060830151611471906013600160ff1b03611a9d565b6013600160ff1b03604085015161148f906013600160ff1b03611a9d565b6013600160ff1b038060208a01518a51086013600160ff1b0360208c01518c51080908086013600160ff1b0360a08401518451090982526013600160ff1b038082604001518360600151086013600160ff1b0360c08401518451090960208301526013600160ff1b038160c001518260a001510960408301525092915050565b61153360405180606001604052806000815260200160008152602001600081525090565b61153b6116fd565b6013600160ff1b03602084015184510881526013600160ff1b038151800960208201526013600160ff1b038351800960408201526013600160ff1b036020840151800960608201526040810151611599906013600160ff1b03611a9d565b6080820181905260608201516013600160ff1b03910860a08201526013600160ff1b036040840151800960e08201526013600160ff1b03808260e001516002096115ea906013600160ff1b03611a9d565b8260a001510860c08201526013600160ff1b0360c08201516013600160ff1b036060840151611620906013600160ff1b03611a9d565b6013600160ff1b03604086015161163e906013600160ff1b03611a9d565b866020015108080982526013600160ff1b03806060830151611667906013600160ff1b03611a9d565b8360800151088260a001510960208301526013600160ff1b038160c001518260a0015109604083015250919050565b6000806116ab60026013600160ff1b03611a9d565b905060006013600160ff1b03905060405160208152602080820152602060408201528460608201528260808201528160a082015260208160c0836005600019fa6116f457600080fd5b51949350505050565b60405180610100016040528060008152602001600081526020016000815260200160008152602001600081526020016000815260200160008152602001600081525090565b60006020828403121561175457600080fd5b5035919050565b6001600160a01b038116811461177057600080fd5b50565b6000806040838503121561178657600080fd5b8235915060208301356117988161175b565b809150509250929050565b6000806000606084860312156117b857600080fd5b833592506020840135915060408401356117d18161175b565b809150509250925092565b60005b838110156117f75781810151838201526020016117df565b50506000910152565b600081518084526118188160208601602086016117dc565b601f01601f19169290920160200192915050565b60208152600061072d6020830184611800565b6000806000806080858703121561185557600080fd5b84356118608161175b565b966020860135965060408601359560600135945092505050565b60608152600061188d6060830186611800565b828103602084015261189f8186611800565b915050826040830152949350505050565b600080604083850312156118c357600080fd5b50508035926020909101359150565b60008083601f8401126118e457600080fd5b50813567ffffffffffffffff8111156118fc57600080fd5b60208301915083602082850101111561191457600080fd5b9250929050565b6000806020838503121561192e57600080fd5b823567ffffffffffffffff81111561194557600080fd5b611951858286016118d2565b90969095509350505050565b6000806000806040858703121561197357600080fd5b843567ffffffffffffffff8082111561198b57600080fd5b611997888389016118d2565b909650945060208701359150808211156119b057600080fd5b506119bd878288016118d2565b95989497509550505050565b6000806000606084860312156119de57600080fd5b83356119e98161175b565b95602085013595506040909401359392505050565b600060208284031215611a1057600080fd5b8135801515811461072d57600080fd5b8315158152606060208201526000611a3b6060830185611800565b8281036040840152611a4d8185611800565b9695505050505050565b600060208284031215611a6957600080fd5b813561072d8161175b565b634e487b7160e01b600052601160045260246000fd5b80820180821115610b5c57610b5c611a74565b81810381811115610b5c57610b5c611a74565b600181811c90821680611ac457607f821691505b602082108103611ae457634e487b7160e01b600052602260045260246000fd5b50919050565b634e487b7160e01b600052604160045260246000fd5b601f821115610b8b57600081815260208120601f850160051c81016020861015611b275750805b601f850160051c820191505b81811015611b4657828155600101611b33565b505050505050565b67ffffffffffffffff831115611b6657611b66611aea565b611b7a83611b748354611ab0565b83611b00565b6000601f841160018114611bae5760008515611b965750838201355b600019600387901b1c1916600186901b178355611c08565b600083815260209020601f19861690835b82811015611bdf5786850135825560209485019460019092019101611bbf565b5086821015611bfc5760001960f88860031b161c19848701351681555b505060018560011b0183555b5050505050565b60008251611c218184602087016117dc565b9190910192915050565b600060208284031215611c3d57600080fd5b505191905056fea2646970667358221220e295cb172a6a09c2e82f7dabc9071b07be3def68a268cd344a25965f83457e4e64736f6c63430008150033"
 
 // DeployHub deploys a new Ethereum contract, binding an instance of Hub to it.
 func DeployHub(auth *bind.TransactOpts, backend bind.ContractBackend) (common.Address, *types.Transaction, *Hub, error) {
@@ -243,14 +243,20 @@ func (_Hub *HubCallerSession) Admin() (common.Address, error) {
 
 // AntiSpamFees is a free data retrieval call binding the contract method 0x5a161ba5.
 //
-// Solidity: function antiSpamFees(bytes32 ) constant returns(uint256 fee, uint256 blockNumber)
+// Solidity: function antiSpamFees(bytes32 ) constant returns(uint256 fee, uint256 blockNumber, address sender, address server, uint256 deadline)
 func (_Hub *HubCaller) AntiSpamFees(opts *bind.CallOpts, arg0 [32]byte) (struct {
 	Fee         *big.Int
 	BlockNumber *big.Int
+	Sender      common.Address
+	Server      common.Address
+	Deadline    *big.Int
 }, error) {
 	ret := new(struct {
 		Fee         *big.Int
 		BlockNumber *big.Int
+		Sender      common.Address
+		Server      common.Address
+		Deadline    *big.Int
 	})
 	out := ret
 	err := _Hub.contract.Call(opts, out, "antiSpamFees", arg0)
@@ -259,20 +265,26 @@ func (_Hub *HubCaller) AntiSpamFees(opts *bind.CallOpts, arg0 [32]byte) (struct
 
 // AntiSpamFees is a free data retrieval call binding the contract method 0x5a161ba5.
 //
-// Solidity: function antiSpamFees(bytes32 ) constant returns(uint256 fee, uint256 blockNumber)
+// Solidity: function antiSpamFees(bytes32 ) constant returns(uint256 fee, uint256 blockNumber, address sender, address server, uint256 deadline)
 func (_Hub *HubSession) AntiSpamFees(arg0 [32]byte) (struct {
 	Fee         *big.Int
 	BlockNumber *big.Int
+	Sender      common.Address
+	Server      common.Address
+	Deadline    *big.Int
 }, error) {
 	return _Hub.Contract.AntiSpamFees(&_Hub.CallOpts, arg0)
 }
 
 // AntiSpamFees is a free data retrieval call binding the contract method 0x5a161ba5.
 //
-// Solidity: function antiSpamFees(bytes32 ) constant returns(uint256 fee, uint256 blockNumber)
+// Solidity: function antiSpamFees(bytes32 ) constant returns(uint256 fee, uint256 blockNumber, address sender, address server, uint256 deadline)
 func (_Hub *HubCallerSession) AntiSpamFees(arg0 [32]byte) (struct {
 	Fee         *big.Int
 	BlockNumber *big.Int
+	Sender      common.Address
+	Server      common.Address
+	Deadline    *big.Int
 }, error) {
 	return _Hub.Contract.AntiSpamFees(&_Hub.CallOpts, arg0)
 }
@@ -329,6 +341,58 @@ func (_Hub *HubCallerSession) CheckDepositConfirmations(recipient common.Address
 	return _Hub.Contract.CheckDepositConfirmations(&_Hub.CallOpts, recipient, adaptorPubKey, value, hashedAntiSpamID)
 }
 
+// CheckEscrowConfirmations is a free data retrieval call binding the contract method 0x50b833e1.
+//
+// Solidity: function checkEscrowConfirmations(uint256 id, uint256 fee, address server) constant returns(uint256)
+func (_Hub *HubCaller) CheckEscrowConfirmations(opts *bind.CallOpts, id *big.Int, fee *big.Int, server common.Address) (*big.Int, error) {
+	var (
+		ret0 = new(*big.Int)
+	)
+	out := ret0
+	err := _Hub.contract.Call(opts, out, "checkEscrowConfirmations", id, fee, server)
+	return *ret0, err
+}
+
+// CheckEscrowConfirmations is a free data retrieval call binding the contract method 0x50b833e1.
+//
+// Solidity: function checkEscrowConfirmations(uint256 id, uint256 fee, address server) constant returns(uint256)
+func (_Hub *HubSession) CheckEscrowConfirmations(id *big.Int, fee *big.Int, server common.Address) (*big.Int, error) {
+	return _Hub.Contract.CheckEscrowConfirmations(&_Hub.CallOpts, id, fee, server)
+}
+
+// CheckEscrowConfirmations is a free data retrieval call binding the contract method 0x50b833e1.
+//
+// Solidity: function checkEscrowConfirmations(uint256 id, uint256 fee, address server) constant returns(uint256)
+func (_Hub *HubCallerSession) CheckEscrowConfirmations(id *big.Int, fee *big.Int, server common.Address) (*big.Int, error) {
+	return _Hub.Contract.CheckEscrowConfirmations(&_Hub.CallOpts, id, fee, server)
+}
+
+// Credits is a free data retrieval call binding the contract method 0xfe5ff468.
+//
+// Solidity: function credits(address ) constant returns(uint256)
+func (_Hub *HubCaller) Credits(opts *bind.CallOpts, arg0 common.Address) (*big.Int, error) {
+	var (
+		ret0 = new(*big.Int)
+	)
+	out := ret0
+	err := _Hub.contract.Call(opts, out, "credits", arg0)
+	return *ret0, err
+}
+
+// Credits is a free data retrieval call binding the contract method 0xfe5ff468.
+//
+// Solidity: function credits(address ) constant returns(uint256)
+func (_Hub *HubSession) Credits(arg0 common.Address) (*big.Int, error) {
+	return _Hub.Contract.Credits(&_Hub.CallOpts, arg0)
+}
+
+// Credits is a free data retrieval call binding the contract method 0xfe5ff468.
+//
+// Solidity: function credits(address ) constant returns(uint256)
+func (_Hub *HubCallerSession) Credits(arg0 common.Address) (*big.Int, error) {
+	return _Hub.Contract.Credits(&_Hub.CallOpts, arg0)
+}
+
 // Deposits is a free data retrieval call binding the contract method 0x3d4dff7b.
 //
 // Solidity: function deposits(bytes32 ) constant returns(address sender, address recipient, uint256 adaptorPubKey, uint256 value, uint256 blockNumber, uint256 deadline)
@@ -629,6 +693,48 @@ func (_Hub *HubTransactorSession) ClaimDeposit(adaptorPrivKey *big.Int, antiSpam
 	return _Hub.Contract.ClaimDeposit(&_Hub.TransactOpts, adaptorPrivKey, antiSpamID)
 }
 
+// ClaimDepositTo is a paid mutator transaction binding the contract method 0x570ba8e3.
+//
+// Solidity: function claimDepositTo(uint256 adaptorPrivKey, uint256 antiSpamID, address payout) returns()
+func (_Hub *HubTransactor) ClaimDepositTo(opts *bind.TransactOpts, adaptorPrivKey *big.Int, antiSpamID *big.Int, payout common.Address) (*types.Transaction, error) {
+	return _Hub.contract.Transact(opts, "claimDepositTo", adaptorPrivKey, antiSpamID, payout)
+}
+
+// ClaimDepositTo is a paid mutator transaction binding the contract method 0x570ba8e3.
+//
+// Solidity: function claimDepositTo(uint256 adaptorPrivKey, uint256 antiSpamID, address payout) returns()
+func (_Hub *HubSession) ClaimDepositTo(adaptorPrivKey *big.Int, antiSpamID *big.Int, payout common.Address) (*types.Transaction, error) {
+	return _Hub.Contract.ClaimDepositTo(&_Hub.TransactOpts, adaptorPrivKey, antiSpamID, payout)
+}
+
+// ClaimDepositTo is a paid mutator transaction binding the contract method 0x570ba8e3.
+//
+// Solidity: function claimDepositTo(uint256 adaptorPrivKey, uint256 antiSpamID, address payout) returns()
+func (_Hub *HubTransactorSession) ClaimDepositTo(adaptorPrivKey *big.Int, antiSpamID *big.Int, payout common.Address) (*types.Transaction, error) {
+	return _Hub.Contract.ClaimDepositTo(&_Hub.TransactOpts, adaptorPrivKey, antiSpamID, payout)
+}
+
+// CollectAntiSpamFee is a paid mutator transaction binding the contract method 0xff1aab81.
+//
+// Solidity: function collectAntiSpamFee(bytes32 hashedID) returns()
+func (_Hub *HubTransactor) CollectAntiSpamFee(opts *bind.TransactOpts, hashedID [32]byte) (*types.Transaction, error) {
+	return _Hub.contract.Transact(opts, "collectAntiSpamFee", hashedID)
+}
+
+// CollectAntiSpamFee is a paid mutator transaction binding the contract method 0xff1aab81.
+//
+// Solidity: function collectAntiSpamFee(bytes32 hashedID) returns()
+func (_Hub *HubSession) CollectAntiSpamFee(hashedID [32]byte) (*types.Transaction, error) {
+	return _Hub.Contract.CollectAntiSpamFee(&_Hub.TransactOpts, hashedID)
+}
+
+// CollectAntiSpamFee is a paid mutator transaction binding the contract method 0xff1aab81.
+//
+// Solidity: function collectAntiSpamFee(bytes32 hashedID) returns()
+func (_Hub *HubTransactorSession) CollectAntiSpamFee(hashedID [32]byte) (*types.Transaction, error) {
+	return _Hub.Contract.CollectAntiSpamFee(&_Hub.TransactOpts, hashedID)
+}
+
 // DepositEther is a paid mutator transaction binding the contract method 0xb90d104d.
 //
 // Solidity: function depositEther(address recipient, uint256 adaptorPubKey, bytes32 hashedAntiSpamID) returns()
@@ -650,6 +756,27 @@ func (_Hub *HubTransactorSession) DepositEther(recipient common.Address, adaptor
 	return _Hub.Contract.DepositEther(&_Hub.TransactOpts, recipient, adaptorPubKey, hashedAntiSpamID)
 }
 
+// EscrowAntiSpamFee is a paid mutator transaction binding the contract method 0x4c0ae0a6.
+//
+// Solidity: function escrowAntiSpamFee(bytes32 hashedID, address server) returns()
+func (_Hub *HubTransactor) EscrowAntiSpamFee(opts *bind.TransactOpts, hashedID [32]byte, server common.Address) (*types.Transaction, error) {
+	return _Hub.contract.Transact(opts, "escrowAntiSpamFee", hashedID, server)
+}
+
+// EscrowAntiSpamFee is a paid mutator transaction binding the contract method 0x4c0ae0a6.
+//
+// Solidity: function escrowAntiSpamFee(bytes32 hashedID, address server) returns()
+func (_Hub *HubSession) EscrowAntiSpamFee(hashedID [32]byte, server common.Address) (*types.Transaction, error) {
+	return _Hub.Contract.EscrowAntiSpamFee(&_Hub.TransactOpts, hashedID, server)
+}
+
+// EscrowAntiSpamFee is a paid mutator transaction binding the contract method 0x4c0ae0a6.
+//
+// Solidity: function escrowAntiSpamFee(bytes32 hashedID, address server) returns()
+func (_Hub *HubTransactorSession) EscrowAntiSpamFee(hashedID [32]byte, server common.Address) (*types.Transaction, error) {
+	return _Hub.Contract.EscrowAntiSpamFee(&_Hub.TransactOpts, hashedID, server)
+}
+
 // ReclaimDeposit is a paid mutator transaction binding the contract method 0xfa79c259.
 //
 // Solidity: function reclaimDeposit(bytes32 hashedAntiSpamID) returns()
@@ -733,3 +860,24 @@ func (_Hub *HubSession) SetVersion(_version string) (*types.Transaction, error)
 func (_Hub *HubTransactorSession) SetVersion(_version string) (*types.Transaction, error) {
 	return _Hub.Contract.SetVersion(&_Hub.TransactOpts, _version)
 }
+
+// WithdrawCredit is a paid mutator transaction binding the contract method 0xc36f3874.
+//
+// Solidity: function withdrawCredit() returns()
+func (_Hub *HubTransactor) WithdrawCredit(opts *bind.TransactOpts) (*types.Transaction, error) {
+	return _Hub.contract.Transact(opts, "withdrawCredit")
+}
+
+// WithdrawCredit is a paid mutator transaction binding the contract method 0xc36f3874.
+//
+// Solidity: function withdrawCredit() returns()
+func (_Hub *HubSession) WithdrawCredit() (*types.Transaction, error) {
+	return _Hub.Contract.WithdrawCredit(&_Hub.TransactOpts)
+}
+
+// WithdrawCredit is a paid mutator transaction binding the contract method 0xc36f3874.
+//
+// Solidity: function withdrawCredit() returns()
+func (_Hub *HubTransactorSession) WithdrawCredit() (*types.Transaction, error) {
+	return _Hub.Contract.WithdrawCredit(&_Hub.TransactOpts)
+}
@@ -3,8 +3,9 @@ package retryinghub
 import (
 	"context"
 	"crypto/ecdsa"
-	"fmt"
+	"log"
 	"math/big"
+	"sync"
 	"time"
 
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
@@ -28,6 +29,8 @@ type (
 	Backend interface {
 		bind.ContractBackend
 		NonceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (uint64, error)
+		BalanceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (*big.Int, error)
+		TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error)
 	}
 
 	RetryingHub struct {
@@ -38,6 +41,14 @@ type (
 		privKey         ecdsa.PrivateKey
 		walletAddress   common.Address
 		hub             *contract.Hub
+		writeMutex      *sync.Mutex // one transaction at a time, so that nonces do not clash
+	}
+
+	// Receipt identifies the transaction that eventually confirmed and the fee
+	// that was paid for it.
+	Receipt struct {
+		TxHash common.Hash
+		Fee    big.Int
 	}
 
 	ServerDetails struct {
@@ -46,6 +57,13 @@ type (
 		Cert   []byte
 	}
 
+	EscrowDetails struct {
+		Fee      *big.Int
+		Server   common.Address
+		Deadline *big.Int
+		Deposit  bool // whether a deposit with the same ID exists
+	}
+
 	blockchainReader func() (interface{}, error)
 	blockchainWriter func(auth *bind.TransactOpts) (*types.Transaction, error)
 )
@@ -61,12 +79,13 @@ func New(maxGasPrice big.Int, boostInterval time.Duration, txCheckInterval time.
 		privKey:         privKey,
 		walletAddress:   walletAddress,
 		hub:             hub,
+		writeMutex:      &sync.Mutex{},
 	}
 	return h
 }
 
-func (h *RetryingHub) BurnAntiSpamFee(hashedID [32]byte, value *big.Int, gasLimit uint64) {
-	h.robustWrite(func(auth *bind.TransactOpts) (*types.Transaction, error) {
+func (h *RetryingHub) BurnAntiSpamFee(hashedID [32]byte, value *big.Int, gasLimit uint64) *Receipt {
+	return h.robustWrite(func(auth *bind.TransactOpts) (*types.Transaction, error) {
 		return h.hub.BurnAntiSpamFee(auth, hashedID)
 	}, value, gasLimit)
 }
@@ -78,9 +97,64 @@ func (h *RetryingHub) CheckAntiSpamConfirmations(id *big.Int, fee *big.Int) *big
 	return confs.(*big.Int)
 }
 
-func (h *RetryingHub) DepositEther(recipient common.Address,
-	adaptorPubKey *big.Int, hashedAntiSpamID [32]byte, value *big.Int, gasLimit uint64) {
+func (h *RetryingHub) EscrowAntiSpamFee(hashedID [32]byte, server common.Address,
+	value *big.Int, gasLimit uint64) *Receipt {
+	return h.robustWrite(func(auth *bind.TransactOpts) (*types.Transaction, error) {
+		return h.hub.EscrowAntiSpamFee(auth, hashedID, server)
+	}, value, gasLimit)
+}
+
+func (h *RetryingHub) CheckEscrowConfirmations(id *big.Int, fee *big.Int, server common.Address) *big.Int {
+	confs := robustRead(func() (interface{}, error) {
+		return h.hub.CheckEscrowConfirmations(nil, id, fee, server)
+	})
+	return confs.(*big.Int)
+}
+
+func (h *RetryingHub) CollectAntiSpamFee(hashedID [32]byte, value *big.Int, gasLimit uint64) {
 	h.robustWrite(func(auth *bind.TransactOpts) (*types.Transaction, error) {
+		return h.hub.CollectAntiSpamFee(auth, hashedID)
+	}, value, gasLimit)
+}
+
+func (h *RetryingHub) Escrow(hashedID [32]byte) EscrowDetails {
+	escrowDetails := robustRead(func() (interface{}, error) {
+		antiSpamFee, err := h.hub.AntiSpamFees(nil, hashedID)
+		if err != nil {
+			return nil, err
+		}
+
+		deposit, err := h.hub.Deposits(nil, hashedID)
+		if err != nil {
+			return nil, err
+		}
+
+		return EscrowDetails{
+			Fee:      antiSpamFee.Fee,
+			Server:   antiSpamFee.Server,
+			Deadline: antiSpamFee.Deadline,
+			Deposit:  deposit.BlockNumber.Sign() != 0,
+		}, nil
+	})
+	return escrowDetails.(EscrowDetails)
+}
+
+func (h *RetryingHub) WithdrawCredit(value *big.Int, gasLimit uint64) *Receipt {
+	return h.robustWrite(func(auth *bind.TransactOpts) (*types.Transaction, error) {
+		return h.hub.WithdrawCredit(auth)
+	}, value, gasLimit)
+}
+
+func (h *RetryingHub) Credits(address common.Address) *big.Int {
+	credit := robustRead(func() (interface{}, error) {
+		return h.hub.Credits(nil, address)
+	})
+	return credit.(*big.Int)
+}
+
+func (h *RetryingHub) DepositEther(recipient common.Address,
+	adaptorPubKey *big.Int, hashedAntiSpamID [32]byte, value *big.Int, gasLimit uint64) *Receipt {
+	return h.robustWrite(func(auth *bind.TransactOpts) (*types.Transaction, error) {
 		return h.hub.DepositEther(auth, recipient, adaptorPubKey, hashedAntiSpamID)
 	}, value, gasLimit)
 }
@@ -93,13 +167,31 @@ func (h *RetryingHub) CheckDepositConfirmations(recipient common.Address,
 	return confs.(*big.Int)
 }
 
+func (h *RetryingHub) DepositExists(hashedAntiSpamID [32]byte) bool {
+	exists := robustRead(func() (interface{}, error) {
+		deposit, err := h.hub.Deposits(nil, hashedAntiSpamID)
+		if err != nil {
+			return nil, err
+		}
+		return deposit.BlockNumber.Sign() != 0, nil
+	})
+	return exists.(bool)
+}
+
 func (h *RetryingHub) ClaimDeposit(adaptorPrivKey *big.Int, antiSpamID *big.Int,
-	value *big.Int, gasLimit uint64) {
-	h.robustWrite(func(auth *bind.TransactOpts) (*types.Transaction, error) {
+	value *big.Int, gasLimit uint64) *Receipt {
+	return h.robustWrite(func(auth *bind.TransactOpts) (*types.Transaction, error) {
 		return h.hub.ClaimDeposit(auth, adaptorPrivKey, antiSpamID)
 	}, value, gasLimit)
 }
 
+func (h *RetryingHub) ClaimDepositTo(adaptorPrivKey *big.Int, antiSpamID *big.Int, payout common.Address,
+	value *big.Int, gasLimit uint64) *Receipt {
+	return h.robustWrite(func(auth *bind.TransactOpts) (*types.Transaction, error) {
+		return h.hub.ClaimDepositTo(auth, adaptorPrivKey, antiSpamID, payout)
+	}, value, gasLimit)
+}
+
 func (h *RetryingHub) AdaptorPrivKeys(adaptorPubKey *big.Int) *big.Int {
 	adaptorPrivKey := robustRead(func() (interface{}, error) {
 		return h.hub.AdaptorPrivKeys(nil, adaptorPubKey)
@@ -141,6 +233,40 @@ func (h *RetryingHub) Deprecated() bool {
 	return deprecated.(bool)
 }
 
+func (h *RetryingHub) Balance() *big.Int {
+	balance := robustRead(func() (interface{}, error) {
+		return h.backend.BalanceAt(context.Background(), h.walletAddress, nil)
+	})
+	return balance.(*big.Int)
+}
+
+// Transfer sends ether from the wallet to the recipient in a plain
+// transaction that does not involve the contract.
+func (h *RetryingHub) Transfer(recipient common.Address, value *big.Int, gasLimit uint64) {
+	h.robustWrite(func(auth *bind.TransactOpts) (*types.Transaction, error) {
+		gasPrice := auth.GasPrice
+		if gasPrice == nil {
+			var err error
+			gasPrice, err = h.backend.SuggestGasPrice(context.Background())
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		tx := types.NewTransaction(auth.Nonce.Uint64(), recipient, auth.Value, auth.GasLimit, gasPrice, nil)
+		signedTx, err := auth.Signer(types.HomesteadSigner{}, auth.From, tx)
+		if err != nil {
+			return nil, err
+		}
+
+		err = h.backend.SendTransaction(context.Background(), signedTx)
+		if err != nil {
+			return nil, err
+		}
+		return signedTx, nil
+	}, value, gasLimit)
+}
+
 func (h *RetryingHub) SuggestGasPrice() *big.Int {
 	gasPrice := robustRead(func() (interface{}, error) {
 		return h.backend.SuggestGasPrice(context.Background())
@@ -166,7 +292,7 @@ func robustRead(reader blockchainReader) interface{} {
 
 		if err != nil {
 			duration := b.Duration()
-			fmt.Printf("%s - retrying in %s\n", err, duration)
+			log.Printf("%s - retrying in %s\n", err, duration)
 			time.Sleep(duration)
 		} else {
 			return result
@@ -174,7 +300,14 @@ func robustRead(reader blockchainReader) interface{} {
 	}
 }
 
-func (h *RetryingHub) robustWrite(writer blockchainWriter, value *big.Int, gasLimit uint64) {
+// robustWrite keeps sending the transaction produced by writer, boosting the
+// gas price if necessary, until it confirms. It returns a receipt for the
+// confirmed transaction or nil if it cannot be determined. Concurrent writes
+// wait for each other.
+func (h *RetryingHub) robustWrite(writer blockchainWriter, value *big.Int, gasLimit uint64) *Receipt {
+	h.writeMutex.Lock()
+	defer h.writeMutex.Unlock()
+
 	b := newBackoff()
 
 	nonceBefore := robustRead(func() (interface{}, error) {
@@ -183,6 +316,7 @@ func (h *RetryingHub) robustWrite(writer blockchainWriter, value *big.Int, gasLi
 	nonce := new(big.Int).SetUint64(nonceBefore.(uint64))
 
 	var gasPrice *big.Int
+	var sent []*types.Transaction
 	for {
 		auth := bind.NewKeyedTransactor(&h.privKey)
 		auth.Value = value
@@ -197,12 +331,13 @@ func (h *RetryingHub) robustWrite(writer blockchainWriter, value *big.Int, gasLi
 
 			if err != nil {
 				duration := b.Duration()
-				fmt.Printf("%s - retrying in %s\n", err, duration)
+				log.Printf("%s - retrying in %s\n", err, duration)
 				time.Sleep(duration)
 			} else {
 				break
 			}
 		}
+		sent = append(sent, tx)
 
 		boostDeadline := time.Now().Add(h.boostInterval)
 		for {
@@ -213,7 +348,7 @@ func (h *RetryingHub) robustWrite(writer blockchainWriter, value *big.Int, gasLi
 			})
 
 			if nonceBefore.(uint64) != nonceNow.(uint64) { // one of our transactions confirmed
-				return
+				return h.receipt(sent)
 			}
 
 			if time.Now().After(boostDeadline) {
@@ -221,7 +356,7 @@ func (h *RetryingHub) robustWrite(writer blockchainWriter, value *big.Int, gasLi
 			}
 		}
 
-		fmt.Printf("Transaction is still pending - boosting gas price\n")
+		log.Printf("Transaction is still pending - boosting gas price\n")
 		gasPrice = new(big.Int).Div(
 			new(big.Int).Mul(tx.GasPrice(), big.NewInt(boostFactorNum)),
 			big.NewInt(boostFactorDen))
@@ -230,3 +365,18 @@ func (h *RetryingHub) robustWrite(writer blockchainWriter, value *big.Int, gasLi
 		}
 	}
 }
+
+func (h *RetryingHub) receipt(sent []*types.Transaction) *Receipt {
+	for _, tx := range sent {
+		receipt, err := h.backend.TransactionReceipt(context.Background(), tx.Hash())
+		if err != nil || receipt == nil {
+			continue
+		}
+
+		fee := new(big.Int).Mul(new(big.Int).SetUint64(receipt.GasUsed), tx.GasPrice())
+		return &Receipt{TxHash: tx.Hash(), Fee: *fee}
+	}
+
+	log.Printf("Unable to determine which transaction confirmed\n")
+	return nil
+}
@@ -0,0 +1,179 @@
+package frontend
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+
+	"gitlab.com/NebulousLabs/Sia/types"
+
+	"github.com/javgh/roadie/trader"
+)
+
+type (
+	// ExecFrontend delegates all decisions to an external program. For every
+	// decision, the program is started once, receives an ExecRequest as JSON
+	// on stdin and is expected to write an ExecDecision as JSON to stdout. A
+	// non-zero exit status is treated as an error.
+	ExecFrontend struct {
+		command      string
+		args         []string
+		exchangeRate Fetcher
+	}
+
+	ExecRequest struct {
+		Method  string     `json:"method"`
+		Siacoin string     `json:"siacoin,omitempty"`
+		Offer   *ExecOffer `json:"offer,omitempty"`
+		OfferA  *ExecOffer `json:"offer_a,omitempty"`
+		OfferB  *ExecOffer `json:"offer_b,omitempty"`
+		Binding bool       `json:"binding"`
+		Rates   *ExecRates `json:"rates,omitempty"`
+	}
+
+	// ExecOffer contains all amounts in their smallest unit (wei and
+	// hastings).
+	ExecOffer struct {
+		Available   bool   `json:"available"`
+		Ether       string `json:"ether"`
+		AntiSpamFee string `json:"anti_spam_fee"`
+		Msg         string `json:"msg"`
+	}
+
+	// ExecRates contains the price of one ether and one siacoin in USD.
+	ExecRates struct {
+		Ethereum string `json:"ethereum"`
+		Siacoin  string `json:"siacoin"`
+	}
+
+	ExecDecision struct {
+		Accept bool `json:"accept"`
+	}
+)
+
+const (
+	methodApproveOffer    = "approve_offer"
+	methodCheckSimilarity = "check_similarity"
+)
+
+var (
+	ErrNoCommand = errors.New("no approval command given")
+)
+
+// NewExecFrontend creates a frontend that runs command with the given
+// arguments. If exchangeRate is nil, no exchange rates are passed on.
+func NewExecFrontend(command string, args []string, exchangeRate Fetcher) (*ExecFrontend, error) {
+	if command == "" {
+		return nil, ErrNoCommand
+	}
+
+	frontend := ExecFrontend{
+		command:      command,
+		args:         args,
+		exchangeRate: exchangeRate,
+	}
+	return &frontend, nil
+}
+
+// ChooseOffer presents offers to the program one by one, cheapest first, and
+// picks the first one it accepts.
+func (f *ExecFrontend) ChooseOffer(quotes []Quote) (int, error) {
+	return chooseFirstApproved(f, quotes)
+}
+
+func (f *ExecFrontend) ApproveOffer(siacoin types.Currency, offer trader.Offer, binding bool) (bool, error) {
+	if !offer.Available {
+		return false, nil
+	}
+
+	rates, err := f.fetchRates()
+	if err != nil {
+		return false, err
+	}
+
+	req := ExecRequest{
+		Method:  methodApproveOffer,
+		Siacoin: siacoin.String(),
+		Offer:   newExecOffer(offer),
+		Binding: binding,
+		Rates:   rates,
+	}
+	return f.decide(req)
+}
+
+// CheckSimilarity reports offers as not similar if the program fails, which
+// means that the binding offer will be passed to ApproveOffer instead.
+func (f *ExecFrontend) CheckSimilarity(a trader.Offer, b trader.Offer) bool {
+	rates, err := f.fetchRates()
+	if err != nil {
+		return false
+	}
+
+	req := ExecRequest{
+		Method: methodCheckSimilarity,
+		OfferA: newExecOffer(a),
+		OfferB: newExecOffer(b),
+		Rates:  rates,
+	}
+	similar, err := f.decide(req)
+	return err == nil && similar
+}
+
+func (f *ExecFrontend) fetchRates() (*ExecRates, error) {
+	if f.exchangeRate == nil {
+		return nil, nil
+	}
+
+	usdEther, err := f.exchangeRate.Fetch("ethereum")
+	if err != nil {
+		return nil, err
+	}
+
+	usdSiacoin, err := f.exchangeRate.Fetch("siacoin")
+	if err != nil {
+		return nil, err
+	}
+
+	rates := ExecRates{
+		Ethereum: usdEther.FloatString(8),
+		Siacoin:  usdSiacoin.FloatString(8),
+	}
+	return &rates, nil
+}
+
+func (f *ExecFrontend) decide(req ExecRequest) (bool, error) {
+	input, err := json.Marshal(req)
+	if err != nil {
+		return false, err
+	}
+
+	var stdout bytes.Buffer
+	cmd := exec.Command(f.command, f.args...)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &stdout
+	cmd.Stderr = os.Stderr
+
+	err = cmd.Run()
+	if err != nil {
+		return false, err
+	}
+
+	var decision ExecDecision
+	err = json.Unmarshal(stdout.Bytes(), &decision)
+	if err != nil {
+		return false, err
+	}
+
+	return decision.Accept, nil
+}
+
+func newExecOffer(offer trader.Offer) *ExecOffer {
+	return &ExecOffer{
+		Available:   offer.Available,
+		Ether:       offer.Ether.String(),
+		AntiSpamFee: offer.AntiSpamFee.String(),
+		Msg:         offer.Msg,
+	}
+}
@@ -0,0 +1,79 @@
+package frontend
+
+import (
+	"encoding/json"
+	"math/big"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gitlab.com/NebulousLabs/Sia/types"
+
+	"github.com/javgh/roadie/trader"
+)
+
+// TestHelperProcess is not a real test; it acts as the external approval
+// program when started by the tests below.
+func TestHelperProcess(t *testing.T) {
+	if os.Getenv("ROADIE_HELPER_PROCESS") != "1" {
+		return
+	}
+	defer os.Exit(0)
+
+	var req ExecRequest
+	err := json.NewDecoder(os.Stdin).Decode(&req)
+	if err != nil || req.Rates == nil {
+		os.Exit(1)
+	}
+
+	var decision ExecDecision
+	switch req.Method {
+	case methodApproveOffer:
+		decision.Accept = req.Offer.Ether == "100" && !req.Binding
+	case methodCheckSimilarity:
+		decision.Accept = req.OfferA.Ether == req.OfferB.Ether
+	default:
+		os.Exit(1)
+	}
+	json.NewEncoder(os.Stdout).Encode(decision)
+}
+
+func TestExecFrontend(t *testing.T) {
+	_, err := NewExecFrontend("", nil, nil)
+	assert.Equal(t, ErrNoCommand, err)
+
+	os.Setenv("ROADIE_HELPER_PROCESS", "1")
+	defer os.Unsetenv("ROADIE_HELPER_PROCESS")
+	args := []string{"-test.run=TestHelperProcess"}
+
+	frontend, err := NewExecFrontend(os.Args[0], args, &MockExchangeRate{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	siacoin := types.NewCurrency64(100)
+	offer := trader.Offer{Available: true, Ether: *big.NewInt(100)}
+	approved, err := frontend.ApproveOffer(siacoin, offer, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, approved, "should approve offer accepted by program")
+
+	approved, err = frontend.ApproveOffer(siacoin, offer, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.False(t, approved, "should decline offer rejected by program")
+
+	other := trader.Offer{Available: true, Ether: *big.NewInt(101)}
+	assert.True(t, frontend.CheckSimilarity(offer, offer))
+	assert.False(t, frontend.CheckSimilarity(offer, other))
+
+	withoutRates, err := NewExecFrontend(os.Args[0], args, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = withoutRates.ApproveOffer(siacoin, offer, false)
+	assert.Error(t, err, "should report failing program")
+	assert.False(t, withoutRates.CheckSimilarity(offer, offer), "should not consider offers similar on failure")
+}
@@ -1,32 +1,48 @@
 package frontend
 
 import (
+	"bufio"
 	"fmt"
 	"math/big"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
 
 	"gitlab.com/NebulousLabs/Sia/types"
 
 	"github.com/javgh/roadie/blockchain/ethereum"
 	"github.com/javgh/roadie/blockchain/sia"
+	"github.com/javgh/roadie/output"
 	"github.com/javgh/roadie/trader"
 )
 
+const (
+	NoBindingTolerance = -1
+)
+
 type (
 	ConsoleFrontend struct {
 		similarityPercentage int64
 		useExchangeRate      bool
+		pickOffer            bool
 		exchangeRate         Fetcher
+		out                  *output.Output
+		in                   *bufio.Reader
 	}
 
 	AutoAcceptFrontend struct{}
 
 	RuleBasedFrontend struct {
-		absDiffRule  float64
-		relDiffRule  float64
-		exchangeRate Fetcher
+		rule             Rule
+		bindingTolerance int64
+		exchangeRate     Fetcher
 	}
 
 	Frontend interface {
+		// ChooseOffer picks one of several non-binding offers and returns its
+		// index or NoChoice. Choosing an offer implies approving it.
+		ChooseOffer(quotes []Quote) (int, error)
 		ApproveOffer(siacoin types.Currency, offer trader.Offer, binding bool) (bool, error)
 		CheckSimilarity(a trader.Offer, b trader.Offer) bool
 	}
@@ -36,20 +52,94 @@ type (
 	}
 )
 
-func NewConsoleFrontend(similarityPercentage int64, useExchangeRate bool, exchangeRate Fetcher) *ConsoleFrontend {
+// NewConsoleFrontend creates a frontend that asks the user. If pickOffer is
+// set, the user chooses among all offers; otherwise only the best offer is
+// shown.
+func NewConsoleFrontend(similarityPercentage int64, useExchangeRate bool, pickOffer bool, exchangeRate Fetcher,
+	out *output.Output) *ConsoleFrontend {
 	frontend := ConsoleFrontend{
 		similarityPercentage: similarityPercentage,
 		useExchangeRate:      useExchangeRate,
+		pickOffer:            pickOffer,
 		exchangeRate:         exchangeRate,
+		out:                  out,
+		in:                   bufio.NewReader(os.Stdin),
 	}
 	return &frontend
 }
 
+func (f *ConsoleFrontend) ChooseOffer(quotes []Quote) (int, error) {
+	if !f.pickOffer || len(quotes) == 0 {
+		return chooseCheapest(f, quotes)
+	}
+
+	order := ByPrice(quotes)
+	var offers []output.Fields
+	table := tabwriter.NewWriter(&tableBuffer{out: f.out}, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(table, "#\tServer\tGive\tBurn\tPrice\tReputation\tMessage\n")
+	for n, i := range order {
+		quote := quotes[i]
+		reputation := quote.Reputation
+		if reputation == "" {
+			reputation = "-"
+		}
+		fmt.Fprintf(table, "%d\t%s\t%s\t%s\t%s ETH/SC\t%s\t%s\n", n+1, quote.Server,
+			ethereum.FormatEther(&quote.Offer.Ether), ethereum.FormatEther(&quote.Offer.AntiSpamFee),
+			formatPrice(quote), reputation, firstLine(quote.Offer.Msg, 40))
+		offers = append(offers, output.Fields{
+			"choice":        n + 1,
+			"server":        quote.Server,
+			"siacoin":       quote.Siacoin.String(),
+			"ether":         quote.Offer.Ether.String(),
+			"anti_spam_fee": quote.Offer.AntiSpamFee.String(),
+			"price":         formatPrice(quote),
+			"reputation":    quote.Reputation,
+			"msg":           quote.Offer.Msg,
+		})
+	}
+
+	f.out.Printf("Offers received (price includes the anti-spam fee):\n")
+	table.Flush()
+	f.out.Printf("\nNote that all offers are non-binding. To continue, you will need to burn\n")
+	f.out.Printf("the listed anti-spam fee to receive a binding offer.\n\n")
+	f.out.Event("choice_required", output.Fields{"offers": offers})
+
+	for {
+		f.out.Printf("Enter the number of the offer to accept (ENTER for 1, 0 to cancel) >")
+		line, err := f.in.ReadString('\n')
+		if err != nil && line == "" {
+			return NoChoice, err
+		}
+		f.out.Println()
+
+		line = strings.TrimSpace(line)
+		if line == "" {
+			return order[0], nil
+		}
+
+		n, err := strconv.Atoi(line)
+		if err == nil && n == 0 {
+			return NoChoice, nil
+		}
+		if err == nil && n >= 1 && n <= len(order) {
+			return order[n-1], nil
+		}
+	}
+}
+
 func (f *ConsoleFrontend) ApproveOffer(siacoin types.Currency, offer trader.Offer, binding bool) (bool, error) {
 	if !offer.Available {
 		return false, nil
 	}
 
+	fields := output.Fields{
+		"binding":       binding,
+		"siacoin":       siacoin.String(),
+		"ether":         offer.Ether.String(),
+		"anti_spam_fee": offer.AntiSpamFee.String(),
+		"msg":           offer.Msg,
+	}
+
 	antiSpamFeeUSDSegment := ""
 	etherUSDSegment := ""
 	siacoinUSDSegment := ""
@@ -71,33 +161,37 @@ func (f *ConsoleFrontend) ApproveOffer(siacoin types.Currency, offer trader.Offe
 		antiSpamFeeUSDSegment = fmt.Sprintf(" (~ %s)", trader.FormatUSD(antiSpamFeeUSD))
 		etherUSDSegment = fmt.Sprintf(" (~ %s)", trader.FormatUSD(etherUSD))
 		siacoinUSDSegment = fmt.Sprintf(" (~ %s)", trader.FormatUSD(siacoinUSD))
+
+		fields["anti_spam_fee_usd"] = antiSpamFeeUSD.FloatString(2)
+		fields["ether_usd"] = etherUSD.FloatString(2)
+		fields["siacoin_usd"] = siacoinUSD.FloatString(2)
 	}
 
-	fmt.Printf("Best offer received:\n")
+	f.out.Printf("Best offer received:\n")
 	if !binding {
-		fmt.Printf("Burn: %s%s\n", ethereum.FormatEther(&offer.AntiSpamFee), antiSpamFeeUSDSegment)
+		f.out.Printf("Burn: %s%s\n", ethereum.FormatEther(&offer.AntiSpamFee), antiSpamFeeUSDSegment)
 	}
-	fmt.Printf("Give: %s%s\n", ethereum.FormatEther(&offer.Ether), etherUSDSegment)
-	fmt.Printf("Get : %s%s\n", siacoin.HumanString(), siacoinUSDSegment)
-	fmt.Printf("\nThe offer contains the following message:\n")
-	fmt.Printf("-----BEGIN MESSAGE-----\n")
-	fmt.Println(offer.Msg)
-	fmt.Printf("-----END MESSAGE-----\n\n")
+	f.out.Printf("Give: %s%s\n", ethereum.FormatEther(&offer.Ether), etherUSDSegment)
+	f.out.Printf("Get : %s%s\n", siacoin.HumanString(), siacoinUSDSegment)
+	f.out.Printf("\nThe offer contains the following message:\n")
+	f.out.Printf("-----BEGIN MESSAGE-----\n")
+	f.out.Println(offer.Msg)
+	f.out.Printf("-----END MESSAGE-----\n\n")
 
 	if !binding {
-		fmt.Printf("Note that this offer is non-binding. To continue, you will need to burn\n")
-		fmt.Printf("the listed anti-spam fee to receive a binding offer. Should the binding offer\n")
-		fmt.Printf("be different, you will be prompted again, but the anti-spam fee is non-refundable.\n\n")
+		f.out.Printf("Note that this offer is non-binding. To continue, you will need to burn\n")
+		f.out.Printf("the listed anti-spam fee to receive a binding offer. Should the binding offer\n")
+		f.out.Printf("be different, you will be prompted again, but the anti-spam fee is non-refundable.\n\n")
 	} else {
-		fmt.Printf("The other party has indicated that this offer is binding and that they\n")
-		fmt.Printf("are ready to proceed with the swap.\n\n")
+		f.out.Printf("The other party has indicated that this offer is binding and that they\n")
+		f.out.Printf("are ready to proceed with the swap.\n\n")
 	}
 
-	fmt.Printf("Press ENTER to continue and accept the offer or CTRL+C to cancel. >")
+	f.out.Printf("Press ENTER to continue and accept the offer or CTRL+C to cancel. >")
+	f.out.Event("approval_required", fields)
 
-	var in string
-	fmt.Scanln(&in)
-	fmt.Println()
+	f.in.ReadString('\n')
+	f.out.Println()
 
 	return true, nil
 }
@@ -106,6 +200,10 @@ func (f *ConsoleFrontend) CheckSimilarity(a trader.Offer, b trader.Offer) bool {
 	return trader.CheckSimilarity(a, b, f.similarityPercentage)
 }
 
+func (f AutoAcceptFrontend) ChooseOffer(quotes []Quote) (int, error) {
+	return chooseCheapest(f, quotes)
+}
+
 func (f AutoAcceptFrontend) ApproveOffer(siacoin types.Currency, offer trader.Offer, binding bool) (bool, error) {
 	return true, nil
 }
@@ -114,58 +212,80 @@ func (f AutoAcceptFrontend) CheckSimilarity(a trader.Offer, b trader.Offer) bool
 	return true
 }
 
+// NewRuleBasedFrontend creates a frontend that compares the USD amount spent on
+// an offer with the USD amount of SC received in return. The offer is approved
+// if the absolute difference does not exceed absDiffRule or if the relative
+// difference (in percent) does not exceed relDiffRule. A rule set to zero is
+// not used.
 func NewRuleBasedFrontend(absDiffRule float64, relDiffRule float64, exchangeRate Fetcher) *RuleBasedFrontend {
+	var rules anyRule
+	if absDiffRule != 0 {
+		rules = append(rules, absDiffUSDRule{limit: new(big.Rat).SetFloat64(absDiffRule)})
+	}
+	if relDiffRule != 0 {
+		rules = append(rules, relDiffUSDRule{limit: new(big.Rat).SetFloat64(relDiffRule)})
+	}
+	return NewRuleExpressionFrontend(rules, NoBindingTolerance, exchangeRate)
+}
+
+// NewRuleExpressionFrontend creates a frontend that approves offers matching
+// rule (see ParseRules). A binding offer within bindingTolerance percent of
+// the non-binding offer is accepted without checking the rule again; a
+// negative tolerance disables this. The exchangeRate may be nil if the rule
+// is not based on USD amounts.
+func NewRuleExpressionFrontend(rule Rule, bindingTolerance int64, exchangeRate Fetcher) *RuleBasedFrontend {
 	frontend := RuleBasedFrontend{
-		absDiffRule:  absDiffRule,
-		relDiffRule:  relDiffRule,
-		exchangeRate: exchangeRate,
+		rule:             rule,
+		bindingTolerance: bindingTolerance,
+		exchangeRate:     exchangeRate,
 	}
 	return &frontend
 }
 
+// ChooseOffer picks the cheapest offer that matches the rules.
+func (f *RuleBasedFrontend) ChooseOffer(quotes []Quote) (int, error) {
+	return chooseFirstApproved(f, quotes)
+}
+
 func (f *RuleBasedFrontend) ApproveOffer(siacoin types.Currency, offer trader.Offer, binding bool) (bool, error) {
 	if !offer.Available {
 		return false, nil
 	}
 
-	usdEther, err := f.exchangeRate.Fetch("ethereum")
-	if err != nil {
-		return false, err
-	}
+	return f.rule.matches(siacoin, offer, &usdRates{exchangeRate: f.exchangeRate})
+}
 
-	usdSiacoin, err := f.exchangeRate.Fetch("siacoin")
-	if err != nil {
-		return false, err
+func (f *RuleBasedFrontend) CheckSimilarity(a trader.Offer, b trader.Offer) bool {
+	if f.bindingTolerance < 0 {
+		return false
 	}
 
-	etherTotal := new(big.Int).Add(&offer.Ether, &offer.AntiSpamFee)
-
-	etherTotalUSD := ethereum.ApplyRate(etherTotal, usdEther)
-	siacoinUSD := sia.ApplyRate(siacoin, usdSiacoin)
-
-	if f.absDiffRule != 0 {
-		absDiff := new(big.Rat).Sub(etherTotalUSD, siacoinUSD)
+	return trader.CheckSimilarity(a, b, f.bindingTolerance)
+}
 
-		absDiffRuleAsRat := new(big.Rat).SetFloat64(f.absDiffRule)
-		if absDiff.Cmp(absDiffRuleAsRat) != 1 {
-			return true, nil
-		}
-	}
+// tableBuffer passes the output of a tabwriter on to an Output, so that the
+// table only shows up in text mode.
+type tableBuffer struct {
+	out *output.Output
+}
 
-	if f.relDiffRule != 0 {
-		relDiff := new(big.Rat).Quo(etherTotalUSD, siacoinUSD)
-		relDiff.Sub(relDiff, new(big.Rat).SetInt64(1))
-		relDiff.Mul(relDiff, new(big.Rat).SetInt64(100))
+func (b *tableBuffer) Write(p []byte) (int, error) {
+	b.out.Printf("%s", p)
+	return len(p), nil
+}
 
-		relDiffRuleAsRat := new(big.Rat).SetFloat64(f.relDiffRule)
-		if relDiff.Cmp(relDiffRuleAsRat) != 1 {
-			return true, nil
-		}
+func formatPrice(quote Quote) string {
+	price := quote.Price()
+	if price == nil {
+		return "-"
 	}
-
-	return false, nil
+	return price.FloatString(8)
 }
 
-func (f *RuleBasedFrontend) CheckSimilarity(a trader.Offer, b trader.Offer) bool {
-	return false
+func firstLine(msg string, maxLength int) string {
+	line := strings.TrimSpace(strings.SplitN(msg, "\n", 2)[0])
+	if len(line) > maxLength {
+		return line[:maxLength-3] + "..."
+	}
+	return line
 }
@@ -1,12 +1,16 @@
 package frontend
 
 import (
+	"bufio"
+	"bytes"
 	"math/big"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"gitlab.com/NebulousLabs/Sia/types"
 
+	"github.com/javgh/roadie/output"
 	"github.com/javgh/roadie/trader"
 )
 
@@ -88,3 +92,115 @@ func TestRuleBasedFrontend(t *testing.T) {
 	offer.AntiSpamFee = *antiSpamFee
 	assertApproveOffer(t, frontend, siacoin, offer, true, "should approve offer where we make money")
 }
+
+func TestRuleExpressionFrontend(t *testing.T) {
+	for _, expression := range []string{"", "max-eth-per-sc", "max-total-eth=-1", "max-anti-spam-share=-1",
+		"unknown=1", "max-total-eth=1 &"} {
+		_, err := ParseRules(expression)
+		assert.Equal(t, ErrInvalidRule, err, "should reject invalid expression %q", expression)
+	}
+
+	rule, err := ParseRules("max-eth-per-sc=0.01 & max-anti-spam-share=10 | max-total-eth = 0.001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	frontend := NewRuleExpressionFrontend(rule, 2, nil)
+
+	siacoin := types.SiacoinPrecision.Mul64(100)
+	offer := trader.Offer{Available: true, Ether: *big.NewInt(95e16), AntiSpamFee: *big.NewInt(5e16)}
+	approved, err := frontend.ApproveOffer(siacoin, offer, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, approved, "should approve offer at price limit")
+
+	offer.AntiSpamFee = *big.NewInt(15e16)
+	approved, err = frontend.ApproveOffer(siacoin, offer, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.False(t, approved, "should decline offer with large anti-spam fee share")
+
+	offer = trader.Offer{Available: true, Ether: *big.NewInt(1e15)}
+	approved, err = frontend.ApproveOffer(types.NewCurrency64(1), offer, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, approved, "should approve offer based on alternative rule")
+
+	similar := trader.Offer{Available: true, Ether: *big.NewInt(1.01e15)}
+	different := trader.Offer{Available: true, Ether: *big.NewInt(1.1e15)}
+	assert.True(t, frontend.CheckSimilarity(offer, similar), "should accept binding offer within tolerance")
+	assert.False(t, frontend.CheckSimilarity(offer, different), "should not accept binding offer outside tolerance")
+
+	rule, err = ParseRules("rel-diff-usd=5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = NewRuleExpressionFrontend(rule, 2, nil).ApproveOffer(siacoin, offer, false)
+	assert.Equal(t, ErrNoExchangeRate, err, "should require exchange rates for USD rules")
+
+	rule, err = ParseRules("rel-diff-usd=-5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	frontend = NewRuleExpressionFrontend(rule, NoBindingTolerance, &MockExchangeRate{})
+	offer = trader.Offer{Available: true, Ether: *big.NewInt(90)}
+	approved, err = frontend.ApproveOffer(types.NewCurrency64(100), offer, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, approved, "should approve offer below negative limit")
+
+	offer.Ether = *big.NewInt(100)
+	approved, err = frontend.ApproveOffer(types.NewCurrency64(100), offer, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.False(t, approved, "should decline offer at market rate with negative limit")
+}
+
+func TestChooseOffer(t *testing.T) {
+	quotes := []Quote{
+		{Server: "expensive", Siacoin: types.NewCurrency64(100), Offer: trader.Offer{Available: true, Ether: *big.NewInt(300)}},
+		{Server: "cheap", Siacoin: types.NewCurrency64(200), Offer: trader.Offer{Available: true, Ether: *big.NewInt(200)}},
+		{Server: "medium", Siacoin: types.NewCurrency64(100), Offer: trader.Offer{Available: true, Ether: *big.NewInt(150)}},
+	}
+	assert.Equal(t, []int{1, 2, 0}, ByPrice(quotes), "should order by price per siacoin")
+
+	rule, err := ParseRules("max-total-eth=0.000000000000000199")
+	if err != nil {
+		t.Fatal(err)
+	}
+	choice, err := NewRuleExpressionFrontend(rule, NoBindingTolerance, nil).ChooseOffer(quotes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 2, choice, "should choose cheapest offer matching the rules")
+
+	var buf bytes.Buffer
+	frontend := NewConsoleFrontend(1, false, true, nil, output.New(output.FormatText, &buf))
+	frontend.in = bufio.NewReader(strings.NewReader("7\n2\n"))
+	choice, err = frontend.ChooseOffer(quotes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 2, choice, "should choose offer picked by user")
+	assert.Contains(t, buf.String(), "medium")
+
+	frontend.in = bufio.NewReader(strings.NewReader("0\n"))
+	choice, err = frontend.ChooseOffer(quotes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, NoChoice, choice, "should allow cancelling")
+
+	buf.Reset()
+	frontend.in = bufio.NewReader(strings.NewReader("\n"))
+	_, err = NewReputationFrontend(frontend, map[string]string{"cheap": "3 purchases"}).ChooseOffer(quotes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Contains(t, buf.String(), "3 purchases", "should show reputation")
+	assert.Equal(t, "", quotes[1].Reputation, "should not modify quotes")
+}
@@ -0,0 +1,114 @@
+package frontend
+
+import (
+	"math/big"
+	"sort"
+
+	"gitlab.com/NebulousLabs/Sia/types"
+
+	"github.com/javgh/roadie/blockchain/ethereum"
+	"github.com/javgh/roadie/blockchain/sia"
+	"github.com/javgh/roadie/trader"
+)
+
+type (
+	// Quote is a non-binding offer from one server, for choosing among
+	// several offers.
+	Quote struct {
+		Server     string
+		Siacoin    types.Currency
+		Offer      trader.Offer
+		Reputation string // free-form; empty if nothing is known about the server
+	}
+
+	// ReputationFrontend fills in the reputation of each server before
+	// passing the quotes on to another frontend.
+	ReputationFrontend struct {
+		Frontend
+		reputation map[string]string
+	}
+)
+
+const (
+	// NoChoice is returned by ChooseOffer if none of the offers is acceptable.
+	NoChoice = -1
+)
+
+// NewReputationFrontend wraps frontend, taking the reputation of each server
+// from the given map.
+func NewReputationFrontend(frontend Frontend, reputation map[string]string) *ReputationFrontend {
+	return &ReputationFrontend{Frontend: frontend, reputation: reputation}
+}
+
+func (f *ReputationFrontend) ChooseOffer(quotes []Quote) (int, error) {
+	withReputation := make([]Quote, len(quotes))
+	for i, quote := range quotes {
+		quote.Reputation = f.reputation[quote.Server]
+		withReputation[i] = quote
+	}
+	return f.Frontend.ChooseOffer(withReputation)
+}
+
+// Price returns the ether paid per siacoin, including the anti-spam fee. This
+// allows comparing offers for different amounts of siacoins.
+func (q Quote) Price() *big.Rat {
+	if q.Siacoin.IsZero() {
+		return nil
+	}
+
+	total := new(big.Int).Add(&q.Offer.Ether, &q.Offer.AntiSpamFee)
+	totalEther := ethereum.ToEther(total)
+	siacoin := sia.ToSiacoin(q.Siacoin)
+	return totalEther.Quo(totalEther, siacoin)
+}
+
+// Cheaper reports whether q has a lower price per siacoin than other.
+func (q Quote) Cheaper(other Quote) bool {
+	totalA := new(big.Int).Add(&q.Offer.Ether, &q.Offer.AntiSpamFee)
+	totalB := new(big.Int).Add(&other.Offer.Ether, &other.Offer.AntiSpamFee)
+	return new(big.Int).Mul(totalA, other.Siacoin.Big()).Cmp(new(big.Int).Mul(totalB, q.Siacoin.Big())) == -1
+}
+
+// ByPrice returns the indices of quotes, cheapest first.
+func ByPrice(quotes []Quote) []int {
+	indices := make([]int, len(quotes))
+	for i := range indices {
+		indices[i] = i
+	}
+
+	sort.SliceStable(indices, func(a, b int) bool {
+		return quotes[indices[a]].Cheaper(quotes[indices[b]])
+	})
+	return indices
+}
+
+// chooseFirstApproved implements ChooseOffer for frontends that decide on
+// single offers: offers are presented to ApproveOffer from cheapest to most
+// expensive and the first approved one is chosen.
+func chooseFirstApproved(frontend Frontend, quotes []Quote) (int, error) {
+	for _, i := range ByPrice(quotes) {
+		approved, err := frontend.ApproveOffer(quotes[i].Siacoin, quotes[i].Offer, false)
+		if err != nil {
+			return NoChoice, err
+		}
+		if approved {
+			return i, nil
+		}
+	}
+	return NoChoice, nil
+}
+
+// chooseCheapest implements ChooseOffer for frontends that should only be
+// asked about the best offer.
+func chooseCheapest(frontend Frontend, quotes []Quote) (int, error) {
+	if len(quotes) == 0 {
+		return NoChoice, nil
+	}
+
+	i := ByPrice(quotes)[0]
+	approved, err := frontend.ApproveOffer(quotes[i].Siacoin, quotes[i].Offer, false)
+	if err != nil || !approved {
+		return NoChoice, err
+	}
+	return i, nil
+}
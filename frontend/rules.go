@@ -0,0 +1,201 @@
+package frontend
+
+import (
+	"errors"
+	"math/big"
+	"strings"
+
+	"gitlab.com/NebulousLabs/Sia/types"
+
+	"github.com/javgh/roadie/blockchain/ethereum"
+	"github.com/javgh/roadie/blockchain/sia"
+	"github.com/javgh/roadie/trader"
+)
+
+type (
+	// Rule decides whether an offer is acceptable. Rules are created with
+	// ParseRules.
+	Rule interface {
+		matches(siacoin types.Currency, offer trader.Offer, rates *usdRates) (bool, error)
+	}
+
+	anyRule []Rule
+	allRule []Rule
+
+	maxEtherPerSiacoinRule struct{ limit *big.Rat }
+	maxTotalEtherRule      struct{ limit *big.Rat }
+	maxAntiSpamShareRule   struct{ limit *big.Rat }
+	absDiffUSDRule         struct{ limit *big.Rat }
+	relDiffUSDRule         struct{ limit *big.Rat }
+
+	// usdRates fetches exchange rates only once they are actually needed, so
+	// that rules not based on USD amounts work without any external data.
+	usdRates struct {
+		exchangeRate Fetcher
+		ether        *big.Rat
+		siacoin      *big.Rat
+	}
+)
+
+var (
+	ErrInvalidRule      = errors.New("invalid rule expression")
+	ErrNoExchangeRate   = errors.New("rule requires exchange rates, but none are available")
+	ErrConflictingRules = errors.New("rule expression cannot be combined with separate difference rules")
+)
+
+// ParseRules parses a rule expression like
+//
+//	max-eth-per-sc=0.0001 & max-anti-spam-share=5 | rel-diff-usd=2
+//
+// Terms joined with '&' all need to match, while for terms joined with '|' one
+// match is enough; '&' binds more strongly than '|'. Available terms are
+// max-eth-per-sc (total ETH per SC), max-total-eth (total ETH including the
+// anti-spam fee), max-anti-spam-share (anti-spam fee as a percentage of the
+// total), abs-diff-usd and rel-diff-usd (see NewRuleBasedFrontend). Only the
+// USD differences may be negative, which requires offers below the market
+// rate.
+func ParseRules(expression string) (Rule, error) {
+	var alternatives anyRule
+	for _, conjunction := range strings.Split(expression, "|") {
+		var terms allRule
+		for _, term := range strings.Split(conjunction, "&") {
+			rule, err := parseTerm(strings.TrimSpace(term))
+			if err != nil {
+				return nil, err
+			}
+			terms = append(terms, rule)
+		}
+		alternatives = append(alternatives, terms)
+	}
+	return alternatives, nil
+}
+
+func parseTerm(term string) (Rule, error) {
+	parts := strings.Split(term, "=")
+	if len(parts) != 2 {
+		return nil, ErrInvalidRule
+	}
+
+	name := strings.TrimSpace(parts[0])
+	limit, ok := new(big.Rat).SetString(strings.TrimSpace(parts[1]))
+	if !ok {
+		return nil, ErrInvalidRule
+	}
+	if limit.Sign() == -1 && name != "abs-diff-usd" && name != "rel-diff-usd" {
+		return nil, ErrInvalidRule
+	}
+
+	switch name {
+	case "max-eth-per-sc":
+		return maxEtherPerSiacoinRule{limit: limit}, nil
+	case "max-total-eth":
+		return maxTotalEtherRule{limit: limit}, nil
+	case "max-anti-spam-share":
+		return maxAntiSpamShareRule{limit: limit}, nil
+	case "abs-diff-usd":
+		return absDiffUSDRule{limit: limit}, nil
+	case "rel-diff-usd":
+		return relDiffUSDRule{limit: limit}, nil
+	default:
+		return nil, ErrInvalidRule
+	}
+}
+
+func (r anyRule) matches(siacoin types.Currency, offer trader.Offer, rates *usdRates) (bool, error) {
+	for _, rule := range r {
+		ok, err := rule.matches(siacoin, offer, rates)
+		if err != nil {
+			return false, err
+		}
+		if ok {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r allRule) matches(siacoin types.Currency, offer trader.Offer, rates *usdRates) (bool, error) {
+	for _, rule := range r {
+		ok, err := rule.matches(siacoin, offer, rates)
+		if err != nil {
+			return false, err
+		}
+		if !ok {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func totalEther(offer trader.Offer) *big.Rat {
+	total := new(big.Int).Add(&offer.Ether, &offer.AntiSpamFee)
+	return ethereum.ToEther(total)
+}
+
+func (r maxEtherPerSiacoinRule) matches(siacoin types.Currency, offer trader.Offer, rates *usdRates) (bool, error) {
+	limit := new(big.Rat).Mul(r.limit, sia.ToSiacoin(siacoin))
+	return totalEther(offer).Cmp(limit) != 1, nil
+}
+
+func (r maxTotalEtherRule) matches(siacoin types.Currency, offer trader.Offer, rates *usdRates) (bool, error) {
+	return totalEther(offer).Cmp(r.limit) != 1, nil
+}
+
+func (r maxAntiSpamShareRule) matches(siacoin types.Currency, offer trader.Offer, rates *usdRates) (bool, error) {
+	total := new(big.Int).Add(&offer.Ether, &offer.AntiSpamFee)
+	if total.Sign() == 0 {
+		return true, nil
+	}
+
+	share := new(big.Rat).SetFrac(new(big.Int).Mul(&offer.AntiSpamFee, big.NewInt(100)), total)
+	return share.Cmp(r.limit) != 1, nil
+}
+
+func (r absDiffUSDRule) matches(siacoin types.Currency, offer trader.Offer, rates *usdRates) (bool, error) {
+	etherTotalUSD, siacoinUSD, err := rates.apply(siacoin, offer)
+	if err != nil {
+		return false, err
+	}
+
+	absDiff := new(big.Rat).Sub(etherTotalUSD, siacoinUSD)
+	return absDiff.Cmp(r.limit) != 1, nil
+}
+
+func (r relDiffUSDRule) matches(siacoin types.Currency, offer trader.Offer, rates *usdRates) (bool, error) {
+	etherTotalUSD, siacoinUSD, err := rates.apply(siacoin, offer)
+	if err != nil {
+		return false, err
+	}
+	if siacoinUSD.Sign() == 0 {
+		return false, nil
+	}
+
+	relDiff := new(big.Rat).Quo(etherTotalUSD, siacoinUSD)
+	relDiff.Sub(relDiff, new(big.Rat).SetInt64(1))
+	relDiff.Mul(relDiff, new(big.Rat).SetInt64(100))
+	return relDiff.Cmp(r.limit) != 1, nil
+}
+
+func (r *usdRates) apply(siacoin types.Currency, offer trader.Offer) (*big.Rat, *big.Rat, error) {
+	if r.ether == nil {
+		if r.exchangeRate == nil {
+			return nil, nil, ErrNoExchangeRate
+		}
+
+		usdEther, err := r.exchangeRate.Fetch("ethereum")
+		if err != nil {
+			return nil, nil, err
+		}
+
+		usdSiacoin, err := r.exchangeRate.Fetch("siacoin")
+		if err != nil {
+			return nil, nil, err
+		}
+
+		r.ether = usdEther
+		r.siacoin = usdSiacoin
+	}
+
+	etherTotal := new(big.Int).Add(&offer.Ether, &offer.AntiSpamFee)
+	return ethereum.ApplyRate(etherTotal, r.ether), sia.ApplyRate(siacoin, r.siacoin), nil
+}
@@ -0,0 +1,224 @@
+// Package history keeps a local record of completed purchases, for
+// accounting and tax purposes.
+package history
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"time"
+
+	"gitlab.com/NebulousLabs/Sia/types"
+
+	"github.com/javgh/roadie/alice"
+	"github.com/javgh/roadie/blockchain/ethereum"
+	"github.com/javgh/roadie/blockchain/sia"
+	"github.com/javgh/roadie/jsonl"
+	"github.com/javgh/roadie/output"
+)
+
+type (
+	// Record describes a single completed purchase. Fees and transaction
+	// hashes of Ethereum transactions are nil or empty if they could not be
+	// determined. Credit is the escrowed anti-spam fee that was refunded and
+	// nil if the fee was burned.
+	Record struct {
+		Time          time.Time
+		Server        string
+		Siacoin       types.Currency
+		Ether         big.Int
+		AntiSpamFee   big.Int
+		AntiSpamID    big.Int
+		BurnFee       *big.Int
+		DepositFee    *big.Int
+		Credit        *big.Int
+		ClaimTxID     types.TransactionID
+		BurnTxHash    string
+		DepositTxHash string
+	}
+)
+
+const (
+	FormatCSV  = "csv"
+	FormatJSON = "json"
+)
+
+var (
+	ErrUnknownFormat = errors.New("unknown export format - expected 'csv' or 'json'")
+
+	csvHeader = []string{"time", "server", "sc", "eth", "anti_spam_fee_eth", "burn_fee_eth",
+		"deposit_fee_eth", "sia_claim_txid", "burn_tx_hash", "deposit_tx_hash", "credit_eth"}
+)
+
+func NewRecord(result alice.Result, now time.Time) Record {
+	record := Record{
+		Time:        now,
+		Server:      result.Server,
+		Siacoin:     result.Siacoin,
+		Ether:       result.Ether,
+		AntiSpamFee: result.AntiSpamFee,
+		AntiSpamID:  result.AntiSpamID,
+		ClaimTxID:   result.ClaimTxID,
+		Credit:      result.Credit,
+	}
+	if result.BurnReceipt != nil {
+		record.BurnFee = &result.BurnReceipt.Fee
+		record.BurnTxHash = result.BurnReceipt.TxHash.Hex()
+	}
+	if result.DepositReceipt != nil {
+		record.DepositFee = &result.DepositReceipt.Fee
+		record.DepositTxHash = result.DepositReceipt.TxHash.Hex()
+	}
+	return record
+}
+
+// Append adds a record to the history at path, one JSON object per line.
+func Append(path string, record Record) error {
+	return jsonl.Append(path, &record) // big.Int only marshals properly when addressable
+}
+
+// Load reads all records from path. A missing file is treated as an empty
+// history. Lines that cannot be parsed, such as a last line that was only
+// partially written, are skipped.
+func Load(path string) ([]Record, error) {
+	var records []Record
+	err := jsonl.Load(path, func(line []byte) error {
+		var record Record
+		err := json.Unmarshal(line, &record)
+		if err != nil {
+			return err
+		}
+		records = append(records, record)
+		return nil
+	})
+	return records, err
+}
+
+// Export writes all records in the given format: CSV with amounts in SC and
+// ETH, or a JSON array with amounts in hastings and wei.
+func Export(writer io.Writer, records []Record, format string) error {
+	switch format {
+	case FormatCSV:
+		return writeCSV(writer, records)
+	case FormatJSON:
+		if records == nil {
+			records = []Record{}
+		}
+		encoder := json.NewEncoder(writer)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(records)
+	default:
+		return ErrUnknownFormat
+	}
+}
+
+func writeCSV(writer io.Writer, records []Record) error {
+	csvWriter := csv.NewWriter(writer)
+	err := csvWriter.Write(csvHeader)
+	if err != nil {
+		return err
+	}
+
+	for _, record := range records {
+		burnFee, depositFee, credit := "", "", ""
+		if record.BurnFee != nil {
+			burnFee = ethereum.FormatEtherExact(record.BurnFee)
+		}
+		if record.DepositFee != nil {
+			depositFee = ethereum.FormatEtherExact(record.DepositFee)
+		}
+		if record.Credit != nil {
+			credit = ethereum.FormatEtherExact(record.Credit)
+		}
+
+		err := csvWriter.Write([]string{
+			record.Time.UTC().Format(time.RFC3339),
+			record.Server,
+			sia.FormatSiacoinExact(record.Siacoin),
+			ethereum.FormatEtherExact(&record.Ether),
+			ethereum.FormatEtherExact(&record.AntiSpamFee),
+			burnFee,
+			depositFee,
+			record.ClaimTxID.String(),
+			record.BurnTxHash,
+			record.DepositTxHash,
+			credit,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	csvWriter.Flush()
+	return csvWriter.Error()
+}
+
+func (r Record) Fields() output.Fields {
+	fields := output.Fields{
+		"time":          r.Time.UTC().Format(time.RFC3339),
+		"server":        r.Server,
+		"siacoin":       r.Siacoin.String(),
+		"ether":         r.Ether.String(),
+		"anti_spam_fee": r.AntiSpamFee.String(),
+		"anti_spam_id":  r.AntiSpamID.String(),
+		"claim_txid":    r.ClaimTxID.String(),
+	}
+	if r.BurnFee != nil {
+		fields["burn_fee"] = r.BurnFee.String()
+		fields["burn_tx_hash"] = r.BurnTxHash
+	}
+	if r.DepositFee != nil {
+		fields["deposit_fee"] = r.DepositFee.String()
+		fields["deposit_tx_hash"] = r.DepositTxHash
+	}
+	if r.Credit != nil {
+		fields["credit"] = r.Credit.String()
+	}
+	return fields
+}
+
+func (r Record) String() string {
+	fees := new(big.Int)
+	if r.BurnFee != nil {
+		fees.Add(fees, r.BurnFee)
+	}
+	if r.DepositFee != nil {
+		fees.Add(fees, r.DepositFee)
+	}
+
+	antiSpamFee := ethereum.FormatEther(&r.AntiSpamFee) + " anti-spam fee"
+	if r.Credit != nil {
+		antiSpamFee += " (" + ethereum.FormatEther(r.Credit) + " refunded)"
+	}
+
+	return fmt.Sprintf("%s: bought %s for %s (+ %s, %s gas) from %s",
+		r.Time.Local().Format("2006-01-02 15:04"), r.Siacoin.HumanString(), ethereum.FormatEther(&r.Ether),
+		antiSpamFee, ethereum.FormatEther(fees), r.Server)
+}
+
+// Reputation summarizes the purchases from each server, such as "2 purchases,
+// last 2019-09-01", for display next to its offers.
+func Reputation(records []Record) map[string]string {
+	counts := make(map[string]int)
+	last := make(map[string]time.Time)
+	for _, record := range records {
+		counts[record.Server]++
+		if record.Time.After(last[record.Server]) {
+			last[record.Server] = record.Time
+		}
+	}
+
+	reputation := make(map[string]string)
+	for server, count := range counts {
+		purchases := "purchases"
+		if count == 1 {
+			purchases = "purchase"
+		}
+		reputation[server] = fmt.Sprintf("%d %s, last %s",
+			count, purchases, last[server].Local().Format("2006-01-02"))
+	}
+	return reputation
+}
@@ -0,0 +1,102 @@
+package history
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"gitlab.com/NebulousLabs/Sia/types"
+
+	"github.com/javgh/roadie/alice"
+	"github.com/javgh/roadie/contract/retryinghub"
+)
+
+func TestHistory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "history.jsonl")
+
+	result := alice.Result{
+		Server:      "server:9979",
+		Siacoin:     types.SiacoinPrecision.Mul64(100),
+		Ether:       *big.NewInt(1e16),
+		AntiSpamFee: *big.NewInt(1e14),
+		AntiSpamID:  *big.NewInt(42),
+		BurnReceipt: &retryinghub.Receipt{TxHash: common.HexToHash("0x01"), Fee: *big.NewInt(1e13)},
+	}
+	now := time.Date(2019, 9, 1, 12, 0, 0, 0, time.UTC)
+
+	err = Append(path, NewRecord(result, now))
+	if err != nil {
+		t.Fatal(err)
+	}
+	result.Credit = big.NewInt(1e14)
+	err = Append(path, NewRecord(result, now.Add(time.Hour)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, records, 2)
+	assert.Equal(t, result.Siacoin, records[0].Siacoin)
+	assert.Equal(t, big.NewInt(1e13), records[0].BurnFee)
+	assert.Nil(t, records[0].DepositFee, "expected unknown deposit fee")
+	assert.Nil(t, records[0].Credit, "expected no credit for burned fee")
+	assert.Equal(t, big.NewInt(1e14), records[1].Credit)
+
+	var buf bytes.Buffer
+	err = Export(&buf, records, FormatCSV)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Len(t, lines, 3)
+	assert.True(t, strings.HasPrefix(lines[1],
+		"2019-09-01T12:00:00Z,server:9979,100.000000000000000000000000,0.010000000000000000,"))
+	assert.True(t, strings.HasSuffix(lines[1], ","), "expected empty credit")
+	assert.True(t, strings.HasSuffix(lines[2], ",0.000100000000000000"))
+
+	buf.Reset()
+	err = Export(&buf, records, FormatJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var exported []Record
+	err = json.Unmarshal(buf.Bytes(), &exported)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, records[1].Ether, exported[1].Ether)
+
+	err = Export(&buf, records, "xml")
+	assert.Equal(t, ErrUnknownFormat, err)
+}
+
+func TestReputation(t *testing.T) {
+	first := time.Date(2019, 9, 1, 12, 0, 0, 0, time.Local)
+	records := []Record{
+		{Time: first.AddDate(0, 0, 2), Server: "a:9979"},
+		{Time: first, Server: "a:9979"},
+		{Time: first, Server: "b:9979"},
+	}
+
+	reputation := Reputation(records)
+	assert.Equal(t, map[string]string{
+		"a:9979": "2 purchases, last 2019-09-03",
+		"b:9979": "1 purchase, last 2019-09-01",
+	}, reputation)
+}
@@ -12,6 +12,7 @@ import (
 	"github.com/javgh/roadie/blockchain/sia"
 	"github.com/javgh/roadie/bob"
 	"github.com/javgh/roadie/frontend"
+	"github.com/javgh/roadie/output"
 	"github.com/javgh/roadie/rpc"
 	"github.com/javgh/roadie/trader"
 )
@@ -39,7 +40,7 @@ func TestIntegration(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	err = ethChain.CheckBalance()
+	err = ethChain.CheckBalance(output.Stdout)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -60,7 +61,7 @@ func server(t *testing.T, ethChain ethereum.Blockchain, siaChain sia.Blockchain)
 	newAtomicSwap := func(now time.Time) *bob.AtomicSwap {
 		return bob.NewAtomicSwap(&trader, ethChain, siaChain, blacklist, now)
 	}
-	bobServer, err := rpc.NewBobServer(serverNetwork, serverAddress, "", "", serverAddress, newAtomicSwap)
+	bobServer, err := rpc.NewBobServer(serverNetwork, serverAddress, "", "", serverAddress, newAtomicSwap, nil, nil)
 	if err != nil {
 		t.Error(err) // cannot use Fatal in goroutine
 	}
@@ -78,8 +79,9 @@ func client(t *testing.T, ethChain ethereum.Blockchain, siaChain sia.Blockchain)
 		{Target: serverAddress, Cert: []byte{}},
 	}
 
-	err := alice.PerformSwap(
-		oneSiacoin, serverDetails, maxAntiSpamFee, fundingConfirmations, frontend, ethChain, siaChain)
+	order := alice.Order{Siacoin: oneSiacoin}
+	_, err := alice.PerformSwap(
+		order, serverDetails, maxAntiSpamFee, fundingConfirmations, frontend, ethChain, siaChain, alice.NewConsoleSink(output.Stdout))
 	if err != nil {
 		t.Fatal(err)
 	}
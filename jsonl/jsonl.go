@@ -0,0 +1,78 @@
+// Package jsonl reads and writes files with one JSON object per line, such as
+// the purchase history and the ledger.
+package jsonl
+
+import (
+	"bufio"
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// Append adds value to the file at path as a single line, creating the file
+// and its directory if needed. If the last write to the file was interrupted,
+// a new line is started first. Note that big.Int only marshals properly when
+// addressable, so value should usually be a pointer.
+func Append(path string, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size() > 0 {
+		last := make([]byte, 1)
+		_, err = file.ReadAt(last, info.Size()-1)
+		if err != nil {
+			return err
+		}
+		if last[0] != '\n' {
+			data = append([]byte{'\n'}, data...)
+		}
+	}
+
+	_, err = file.Write(append(data, '\n'))
+	return err
+}
+
+// Load calls decode for every non-empty line of the file at path. Lines that
+// decode rejects, such as a last line that was only partially written, are
+// logged and skipped. A missing file is treated as an empty one.
+func Load(path string, decode func(line []byte) error) error {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		err := decode(scanner.Bytes())
+		if err != nil {
+			log.Printf("Skipping invalid line in %s: %s\n", path, err)
+		}
+	}
+
+	return scanner.Err()
+}
@@ -0,0 +1,68 @@
+package jsonl
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type (
+	item struct {
+		N int
+	}
+)
+
+func load(t *testing.T, path string) []item {
+	var items []item
+	err := Load(path, func(line []byte) error {
+		var i item
+		err := json.Unmarshal(line, &i)
+		if err != nil {
+			return err
+		}
+		items = append(items, i)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return items
+}
+
+func TestJSONLines(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jsonl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "sub", "items.jsonl")
+
+	assert.Empty(t, load(t, path), "expected missing file to be empty")
+
+	err = Append(path, &item{N: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = file.WriteString("garbage\n{\"N\":")
+	file.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = Append(path, &item{N: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, []item{{N: 1}, {N: 2}}, load(t, path),
+		"expected invalid and truncated lines to be skipped")
+}
@@ -0,0 +1,312 @@
+// Package ledger records what each completed swap earned a server, so that
+// operators can report profit and loss.
+package ledger
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"gitlab.com/NebulousLabs/Sia/types"
+
+	"github.com/javgh/roadie/blockchain/ethereum"
+	"github.com/javgh/roadie/blockchain/sia"
+	"github.com/javgh/roadie/jsonl"
+	"github.com/javgh/roadie/output"
+	"github.com/javgh/roadie/trader"
+)
+
+type (
+	// Entry describes a single completed swap from the server's point of
+	// view. Rates are the USD prices of one ETH and one SC at the time the
+	// swap completed and are nil if they could not be fetched. Anti-spam fees
+	// that were escrowed for the server and kept after a swap failed are
+	// recorded as entries of their own, with only Collected set.
+	Entry struct {
+		ID          uuid.UUID
+		Time        time.Time
+		Siacoin     types.Currency
+		MinerFees   types.Currency
+		Ether       big.Int
+		ClaimFee    *big.Int // nil if unknown
+		AntiSpamFee big.Int
+		Collected   *big.Int // nil for completed swaps
+		USDEther    *big.Rat
+		USDSiacoin  *big.Rat
+	}
+
+	// Day sums up all entries of one (UTC) day.
+	Day struct {
+		Date         string
+		Swaps        int
+		Siacoin      types.Currency
+		MinerFees    types.Currency
+		Ether        big.Int
+		ClaimFees    big.Int
+		AntiSpamFees big.Int
+		Collected    big.Int
+		ProfitUSD    big.Rat
+		Incomplete   bool // true if the profit of some entries is unknown
+	}
+
+	// Ledger appends entries to a file, one JSON object per line. The amounts
+	// sold are also kept in memory, so that Volume does not have to read the
+	// file each time.
+	Ledger struct {
+		mutex        sync.Mutex
+		path         string
+		exchangeRate *trader.ExchangeRate
+		sales        []sale // nil until the file has been read
+	}
+
+	sale struct {
+		time    time.Time
+		siacoin types.Currency
+	}
+)
+
+const (
+	dateFormat = "2006-01-02"
+)
+
+var (
+	csvHeader = []string{"id", "time", "sc", "miner_fees_sc", "eth", "claim_fee_eth",
+		"anti_spam_fee_eth", "collected_eth", "usd_per_eth", "usd_per_sc", "profit_usd"}
+)
+
+// New returns a ledger that writes to path. If exchangeRate is not nil, it is
+// used to add USD rates to entries that do not have them yet.
+func New(path string, exchangeRate *trader.ExchangeRate) *Ledger {
+	return &Ledger{path: path, exchangeRate: exchangeRate}
+}
+
+func (l *Ledger) Record(entry Entry) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if entry.USDEther == nil && entry.USDSiacoin == nil && l.exchangeRate != nil {
+		usdEther, usdSiacoin, err := l.fetchRates()
+		if err != nil {
+			log.Printf("Unable to fetch exchange rates for ledger: %s\n", err)
+		} else {
+			entry.USDEther = usdEther
+			entry.USDSiacoin = usdSiacoin
+		}
+	}
+
+	err := jsonl.Append(l.path, &entry) // big.Int only marshals properly when addressable
+	if err != nil {
+		return err
+	}
+
+	if l.sales != nil {
+		l.sales = append(l.sales, sale{time: entry.Time, siacoin: entry.Siacoin})
+	}
+	return nil
+}
+
+// Volume sums up the siacoins sold since the given time.
+func (l *Ledger) Volume(since time.Time) (types.Currency, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.sales == nil {
+		entries, err := Load(l.path)
+		if err != nil {
+			return types.ZeroCurrency, err
+		}
+
+		l.sales = make([]sale, 0, len(entries))
+		for _, entry := range entries {
+			l.sales = append(l.sales, sale{time: entry.Time, siacoin: entry.Siacoin})
+		}
+	}
+
+	volume := types.ZeroCurrency
+	for _, sale := range l.sales {
+		if sale.time.After(since) {
+			volume = volume.Add(sale.siacoin)
+		}
+	}
+	return volume, nil
+}
+
+func (l *Ledger) fetchRates() (usdEther *big.Rat, usdSiacoin *big.Rat, err error) {
+	usdEther, err = l.exchangeRate.Fetch("ethereum")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	usdSiacoin, err = l.exchangeRate.Fetch("siacoin")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return usdEther, usdSiacoin, nil
+}
+
+// Load reads all entries from path. A missing file is treated as an empty
+// ledger. Lines that cannot be parsed, such as a last line that was only
+// partially written, are skipped.
+func Load(path string) ([]Entry, error) {
+	var entries []Entry
+	err := jsonl.Load(path, func(line []byte) error {
+		var entry Entry
+		err := json.Unmarshal(line, &entry)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, entry)
+		return nil
+	})
+	return entries, err
+}
+
+// ProfitUSD is the value of the ether received minus the claim fee, less the
+// value of the siacoins sent including miner fees. It is nil if the rates are
+// unknown. The anti-spam fee of a completed swap does not count towards
+// profit, as it is either burned or refunded to the client. Collected fees of
+// failed swaps do.
+func (e Entry) ProfitUSD() *big.Rat {
+	if e.USDEther == nil || e.USDSiacoin == nil {
+		return nil
+	}
+
+	ether := new(big.Int).Set(&e.Ether)
+	if e.ClaimFee != nil {
+		ether.Sub(ether, e.ClaimFee)
+	}
+	if e.Collected != nil {
+		ether.Add(ether, e.Collected)
+	}
+	income := ethereum.ApplyRate(ether, e.USDEther)
+	expense := sia.ApplyRate(e.Siacoin.Add(e.MinerFees), e.USDSiacoin)
+
+	return income.Sub(income, expense)
+}
+
+// Daily sums up entries by day, oldest day first.
+func Daily(entries []Entry) []Day {
+	days := make(map[string]*Day)
+	for _, entry := range entries {
+		date := entry.Time.UTC().Format(dateFormat)
+		day, ok := days[date]
+		if !ok {
+			day = &Day{Date: date}
+			days[date] = day
+		}
+
+		profit := entry.ProfitUSD()
+		if profit == nil {
+			day.Incomplete = true
+		}
+
+		if entry.Collected != nil {
+			day.Collected.Add(&day.Collected, entry.Collected)
+		} else {
+			day.Swaps++
+			day.Siacoin = day.Siacoin.Add(entry.Siacoin)
+			day.MinerFees = day.MinerFees.Add(entry.MinerFees)
+			day.Ether.Add(&day.Ether, &entry.Ether)
+			day.AntiSpamFees.Add(&day.AntiSpamFees, &entry.AntiSpamFee)
+			if entry.ClaimFee != nil {
+				day.ClaimFees.Add(&day.ClaimFees, entry.ClaimFee)
+			} else {
+				day.Incomplete = true
+			}
+		}
+
+		if profit != nil {
+			day.ProfitUSD.Add(&day.ProfitUSD, profit)
+		}
+	}
+
+	var result []Day
+	for _, day := range days {
+		result = append(result, *day)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Date < result[j].Date
+	})
+	return result
+}
+
+func (d Day) Fields() output.Fields {
+	return output.Fields{
+		"date":           d.Date,
+		"swaps":          d.Swaps,
+		"siacoin":        d.Siacoin.String(),
+		"miner_fees":     d.MinerFees.String(),
+		"ether":          d.Ether.String(),
+		"claim_fees":     d.ClaimFees.String(),
+		"anti_spam_fees": d.AntiSpamFees.String(),
+		"collected":      d.Collected.String(),
+		"profit_usd":     d.ProfitUSD.FloatString(4),
+		"incomplete":     d.Incomplete,
+	}
+}
+
+func (d Day) String() string {
+	profit := trader.FormatUSD(&d.ProfitUSD)
+	if d.Incomplete {
+		profit += " (incomplete)"
+	}
+	return fmt.Sprintf("%s: %d swap(s); sold %s (+ %s miner fees) for %s (- %s claim fees); "+
+		"anti-spam fees %s (%s collected); profit %s", d.Date, d.Swaps, d.Siacoin.HumanString(),
+		d.MinerFees.HumanString(), ethereum.FormatEther(&d.Ether), ethereum.FormatEther(&d.ClaimFees),
+		ethereum.FormatEther(&d.AntiSpamFees), ethereum.FormatEther(&d.Collected), profit)
+}
+
+// WriteCSV writes one line per entry, with amounts in SC, ETH and USD.
+// Unknown values are left empty.
+func WriteCSV(writer io.Writer, entries []Entry) error {
+	csvWriter := csv.NewWriter(writer)
+	err := csvWriter.Write(csvHeader)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		claimFee, collected := "", ""
+		if entry.ClaimFee != nil {
+			claimFee = ethereum.FormatEtherExact(entry.ClaimFee)
+		}
+		if entry.Collected != nil {
+			collected = ethereum.FormatEtherExact(entry.Collected)
+		}
+
+		usdEther, usdSiacoin, profit := "", "", ""
+		if entry.USDEther != nil && entry.USDSiacoin != nil {
+			usdEther = entry.USDEther.FloatString(4)
+			usdSiacoin = entry.USDSiacoin.FloatString(8)
+			profit = entry.ProfitUSD().FloatString(4)
+		}
+
+		err := csvWriter.Write([]string{
+			entry.ID.String(),
+			entry.Time.UTC().Format(time.RFC3339),
+			sia.FormatSiacoinExact(entry.Siacoin),
+			sia.FormatSiacoinExact(entry.MinerFees),
+			ethereum.FormatEtherExact(&entry.Ether),
+			claimFee,
+			ethereum.FormatEtherExact(&entry.AntiSpamFee),
+			collected,
+			usdEther,
+			usdSiacoin,
+			profit,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	csvWriter.Flush()
+	return csvWriter.Error()
+}
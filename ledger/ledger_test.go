@@ -0,0 +1,138 @@
+package ledger
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"gitlab.com/NebulousLabs/Sia/types"
+)
+
+func TestLedger(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ledger")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "ledger.jsonl")
+
+	entries, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Empty(t, entries, "expected missing ledger to be empty")
+
+	day := time.Date(2019, 9, 1, 12, 0, 0, 0, time.UTC)
+	withRates := Entry{
+		ID:          uuid.Must(uuid.NewRandom()),
+		Time:        day,
+		Siacoin:     types.SiacoinPrecision.Mul64(100),
+		MinerFees:   types.SiacoinPrecision.Mul64(2),
+		Ether:       *big.NewInt(1e16),
+		ClaimFee:    big.NewInt(1e15),
+		AntiSpamFee: *big.NewInt(1e14),
+		USDEther:    big.NewRat(200, 1),
+		USDSiacoin:  big.NewRat(1, 100),
+	}
+	withoutRates := Entry{
+		ID:      uuid.Must(uuid.NewRandom()),
+		Time:    day.Add(time.Hour),
+		Siacoin: types.SiacoinPrecision.Mul64(50),
+		Ether:   *big.NewInt(5e15),
+	}
+	nextDay := withRates
+	nextDay.ID = uuid.Must(uuid.NewRandom())
+	nextDay.Time = day.Add(24 * time.Hour)
+
+	ledger := New(path, nil)
+	for _, entry := range []Entry{withRates, withoutRates, nextDay} {
+		err = ledger.Record(entry)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = file.WriteString("not json\n{\"ID\":\"")
+	file.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err = Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, entries, 3, "expected invalid and truncated lines to be skipped")
+	assert.Equal(t, withRates.ID, entries[0].ID)
+	assert.Equal(t, withRates.ClaimFee, entries[0].ClaimFee)
+
+	volume, err := ledger.Volume(day.Add(30 * time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, types.SiacoinPrecision.Mul64(150), volume)
+
+	err = ledger.Record(withoutRates)
+	if err != nil {
+		t.Fatal(err)
+	}
+	appended, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, appended, 4, "expected entry after truncated line to be kept")
+
+	volume, err = ledger.Volume(day.Add(30 * time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, types.SiacoinPrecision.Mul64(200), volume, "expected volume to include new entry")
+
+	// 0.009 ETH * 200 USD - 102 SC * 0.01 USD
+	assert.Equal(t, big.NewRat(78, 100), entries[0].ProfitUSD())
+	assert.Nil(t, entries[1].ProfitUSD(), "expected unknown profit without rates")
+
+	days := Daily(entries)
+	assert.Len(t, days, 2)
+	assert.Equal(t, "2019-09-01", days[0].Date)
+	assert.Equal(t, 2, days[0].Swaps)
+	assert.Equal(t, types.SiacoinPrecision.Mul64(150), days[0].Siacoin)
+	assert.Equal(t, big.NewInt(15e15), &days[0].Ether)
+	assert.True(t, days[0].Incomplete, "expected day with unknown profit to be incomplete")
+	assert.False(t, days[1].Incomplete)
+
+	collected := Entry{
+		ID:         uuid.Must(uuid.NewRandom()),
+		Time:       nextDay.Time,
+		Collected:  big.NewInt(1e15),
+		USDEther:   big.NewRat(200, 1),
+		USDSiacoin: big.NewRat(1, 100),
+	}
+	days = Daily([]Entry{nextDay, collected})
+	assert.Equal(t, 1, days[0].Swaps, "expected collected fee not to count as swap")
+	assert.Equal(t, big.NewInt(1e15), &days[0].Collected)
+	assert.Equal(t, big.NewRat(98, 100), &days[0].ProfitUSD, "expected collected fee to count as profit")
+	assert.False(t, days[0].Incomplete)
+
+	var buf bytes.Buffer
+	err = WriteCSV(&buf, entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Len(t, lines, 4)
+	assert.True(t, strings.HasPrefix(lines[0], "id,time,sc,"))
+	assert.True(t, strings.HasSuffix(lines[1], ",0.7800"))
+	assert.True(t, strings.HasSuffix(lines[2], ",,,"), "expected empty rates and profit")
+}
@@ -0,0 +1,111 @@
+package output
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+type (
+	Format int
+
+	// Output is used for everything that is meant for the user of the command
+	// line tool. In text mode, only human readable text is written; in JSON
+	// mode, only events are written (one JSON object per line), so that the
+	// output can be processed by other programs.
+	Output struct {
+		format Format
+		writer io.Writer
+		mutex  sync.Mutex
+	}
+
+	Fields map[string]interface{}
+)
+
+const (
+	FormatText Format = iota
+	FormatJSON
+)
+
+var (
+	ErrUnknownFormat = errors.New("unknown output format")
+
+	Stdout = New(FormatText, os.Stdout)
+)
+
+func ParseFormat(format string) (Format, error) {
+	switch format {
+	case "text":
+		return FormatText, nil
+	case "json":
+		return FormatJSON, nil
+	default:
+		return FormatText, ErrUnknownFormat
+	}
+}
+
+func New(format Format, writer io.Writer) *Output {
+	o := Output{
+		format: format,
+		writer: writer,
+	}
+	return &o
+}
+
+func (o *Output) IsJSON() bool {
+	return o.format == FormatJSON
+}
+
+func (o *Output) Printf(format string, a ...interface{}) {
+	if o.format != FormatText {
+		return
+	}
+
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	fmt.Fprintf(o.writer, format, a...)
+}
+
+func (o *Output) Println(a ...interface{}) {
+	if o.format != FormatText {
+		return
+	}
+
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	fmt.Fprintln(o.writer, a...)
+}
+
+func (o *Output) Event(event string, fields Fields) {
+	if o.format != FormatJSON {
+		return
+	}
+
+	line := Fields{}
+	for k, v := range fields {
+		line[k] = v
+	}
+	line["event"] = event
+	line["time"] = time.Now().UTC().Format(time.RFC3339)
+
+	data, err := json.Marshal(line)
+	if err != nil {
+		// should not happen with the simple values used for events; report
+		// it as an event itself, so that the output stays parseable
+		data, _ = json.Marshal(Fields{"event": "error", "code": "internal", "message": err.Error()})
+	}
+
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	fmt.Fprintf(o.writer, "%s\n", data)
+}
+
+// Error reports an error together with a short, stable code that scripts can
+// match on. Text mode leaves the reporting of errors to the caller.
+func (o *Output) Error(code string, err error) {
+	o.Event("error", Fields{"code": code, "message": err.Error()})
+}
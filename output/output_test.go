@@ -0,0 +1,63 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTextOutput(t *testing.T) {
+	var buf bytes.Buffer
+	out := New(FormatText, &buf)
+
+	out.Printf("%d/%d", 1, 10)
+	out.Println()
+	out.Event("confirmations", Fields{"current": 1})
+	out.Error("unknown", errors.New("failure"))
+
+	assert.Equal(t, "1/10\n", buf.String(), "should only contain human readable text")
+}
+
+func TestJSONOutput(t *testing.T) {
+	var buf bytes.Buffer
+	out := New(FormatJSON, &buf)
+
+	out.Printf("%d/%d", 1, 10)
+	out.Event("confirmations", Fields{"current": 1})
+	out.Error("no_offers", errors.New("no offers received"))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Equal(t, 2, len(lines), "should only contain events")
+
+	var event map[string]interface{}
+	err := json.Unmarshal([]byte(lines[0]), &event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "confirmations", event["event"])
+	assert.Equal(t, float64(1), event["current"])
+	assert.Contains(t, event, "time")
+
+	err = json.Unmarshal([]byte(lines[1]), &event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "error", event["event"])
+	assert.Equal(t, "no_offers", event["code"])
+	assert.Equal(t, "no offers received", event["message"])
+}
+
+func TestParseFormat(t *testing.T) {
+	format, err := ParseFormat("json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, FormatJSON, format)
+
+	_, err = ParseFormat("xml")
+	assert.Equal(t, ErrUnknownFormat, err)
+}
@@ -0,0 +1,125 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/peer"
+)
+
+type (
+	// Limits protect the server against clients that keep requesting offers
+	// without following through. Rates are in requests per minute and apply
+	// to requests for non-binding offers and quotes. Zero disables a limit.
+	Limits struct {
+		RequestsPerClient float64
+		RequestsGlobal    float64
+		MaxPendingSwaps   int           // swaps that have not received a binding offer yet
+		OfferTimeout      time.Duration // forget swaps without binding offer after this long
+	}
+
+	// rateLimiter is a token bucket per client plus one for all clients
+	// together. Buckets of clients that have been idle long enough to be full
+	// again are dropped by prune.
+	rateLimiter struct {
+		mutex     sync.Mutex
+		perClient float64
+		global    float64
+		clients   map[string]*bucket
+		all       bucket
+	}
+
+	bucket struct {
+		tokens float64
+		last   time.Time
+	}
+)
+
+var (
+	ErrRateLimited = errors.New("too many requests - please try again later")
+	ErrBusy        = errors.New("server is busy - please try again later")
+)
+
+func newRateLimiter(perClient float64, global float64) *rateLimiter {
+	return &rateLimiter{
+		perClient: perClient,
+		global:    global,
+		clients:   make(map[string]*bucket),
+	}
+}
+
+func (l *rateLimiter) allow(client string, now time.Time) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	clientBucket, ok := l.clients[client]
+	if !ok {
+		clientBucket = &bucket{}
+	}
+
+	if l.perClient > 0 && !clientBucket.available(l.perClient, now) {
+		return ErrRateLimited
+	}
+	if l.global > 0 && !l.all.available(l.global, now) {
+		return ErrBusy
+	}
+
+	if l.perClient > 0 {
+		clientBucket.tokens--
+		l.clients[client] = clientBucket
+	}
+	if l.global > 0 {
+		l.all.tokens--
+	}
+	return nil
+}
+
+func (l *rateLimiter) prune(now time.Time) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	for client, clientBucket := range l.clients {
+		if clientBucket.refill(l.perClient, now) >= burst(l.perClient) {
+			delete(l.clients, client)
+		}
+	}
+}
+
+// available refills the bucket and reports whether it holds a token.
+func (b *bucket) available(perMinute float64, now time.Time) bool {
+	return b.refill(perMinute, now) >= 1
+}
+
+func (b *bucket) refill(perMinute float64, now time.Time) float64 {
+	if b.last.IsZero() {
+		b.tokens = burst(perMinute)
+	} else {
+		b.tokens = math.Min(b.tokens+now.Sub(b.last).Minutes()*perMinute, burst(perMinute))
+	}
+	b.last = now
+	return b.tokens
+}
+
+// burst is the number of requests that can be made at once: a minute's worth.
+func burst(perMinute float64) float64 {
+	return math.Max(perMinute, 1)
+}
+
+// remoteHost returns the address of the client without the port, so that
+// all connections from one host share a limit.
+func remoteHost(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+	return host
+}
@@ -0,0 +1,27 @@
+package rpc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimiter(t *testing.T) {
+	limiter := newRateLimiter(2, 3)
+	now := time.Now()
+
+	assert.Nil(t, limiter.allow("a", now))
+	assert.Nil(t, limiter.allow("a", now))
+	assert.Equal(t, ErrRateLimited, limiter.allow("a", now), "should limit single client")
+
+	assert.Nil(t, limiter.allow("b", now))
+	assert.Equal(t, ErrBusy, limiter.allow("c", now), "should limit all clients together")
+
+	later := now.Add(30 * time.Second)
+	assert.Nil(t, limiter.allow("a", later), "should refill over time")
+	assert.Equal(t, ErrRateLimited, limiter.allow("a", later))
+
+	limiter.prune(now.Add(time.Hour))
+	assert.Equal(t, 0, len(limiter.clients), "should forget idle clients")
+}
@@ -0,0 +1,137 @@
+package rpc
+
+import (
+	"log"
+	"sort"
+	"sync"
+
+	"github.com/HyperspaceApp/ed25519"
+	"github.com/google/uuid"
+
+	"github.com/javgh/roadie/bob"
+)
+
+type (
+	// swapEntry holds a swap known to the server. The mutex is held for the
+	// whole of every operation on the swap, including slow blockchain calls,
+	// so that such an operation only holds up requests for the same swap.
+	// A summary of the swap is published after each operation and can be
+	// read without waiting for the next one to finish.
+	swapEntry struct {
+		mutex      sync.Mutex
+		atomicSwap *bob.AtomicSwap
+		sessionKey ed25519.PublicKey
+		removed    bool // set once the swap has been dropped from the registry
+
+		statusMutex sync.Mutex
+		status      swapStatus
+	}
+
+	swapStatus struct {
+		snapshot             bob.Snapshot
+		outcome              bob.Outcome
+		awaitingBindingOffer bool
+		claimPending         bool
+		refundTx             string // encoded refund transaction, empty if none yet
+	}
+)
+
+func newSwapEntry(atomicSwap *bob.AtomicSwap, sessionKey ed25519.PublicKey) *swapEntry {
+	entry := swapEntry{atomicSwap: atomicSwap, sessionKey: sessionKey}
+	entry.publish()
+	return &entry
+}
+
+func (e *swapEntry) lock() {
+	e.mutex.Lock()
+}
+
+// unlock publishes the state the swap was left in and releases it.
+func (e *swapEntry) unlock() {
+	e.publish()
+	e.mutex.Unlock()
+}
+
+// publish updates the summary of the swap. The caller must hold the mutex.
+func (e *swapEntry) publish() {
+	refundTx, _ := e.atomicSwap.EncodedRefundTransaction()
+	status := swapStatus{
+		snapshot:             e.atomicSwap.Snapshot(),
+		outcome:              e.atomicSwap.BindingOfferOutcome(),
+		awaitingBindingOffer: e.atomicSwap.AwaitingBindingOffer(),
+		claimPending:         e.atomicSwap.ClaimPending(),
+		refundTx:             refundTx,
+	}
+
+	e.statusMutex.Lock()
+	e.status = status
+	e.statusMutex.Unlock()
+}
+
+// current returns the summary published after the last operation.
+func (e *swapEntry) current() swapStatus {
+	e.statusMutex.Lock()
+	defer e.statusMutex.Unlock()
+
+	return e.status
+}
+
+// add makes a swap known to the server.
+func (s *BobServer) add(entry *swapEntry) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.swaps[entry.atomicSwap.ID] = entry
+}
+
+// remove drops a swap from the registry. The caller must hold the lock of
+// the entry.
+func (s *BobServer) remove(entry *swapEntry) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	entry.removed = true
+	delete(s.swaps, entry.atomicSwap.ID)
+}
+
+// entries returns all swaps currently known, ordered by ID.
+func (s *BobServer) entries() []*swapEntry {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	entries := make([]*swapEntry, 0, len(s.swaps))
+	for _, entry := range s.swaps {
+		entries = append(entries, entry)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].atomicSwap.ID.String() < entries[j].atomicSwap.ID.String()
+	})
+	return entries
+}
+
+// lookup returns the swap a request refers to, after checking that the
+// request is signed with the session key of the swap. The swap is locked and
+// has to be released with unlock.
+func (s *BobServer) lookup(method string, id uuid.UUID, req interface{}, sig *[]byte) (*swapEntry, error) {
+	s.mutex.RLock()
+	entry, ok := s.swaps[id]
+	s.mutex.RUnlock()
+	if !ok {
+		return nil, ErrUnknownID
+	}
+
+	err := verifyRequest(method, entry.sessionKey, req, sig)
+	if err != nil {
+		log.Printf("[%s] Rejected %s: %s\n", id, method, err)
+		return nil, err
+	}
+
+	entry.lock()
+	if entry.removed { // dropped by Check while we were waiting
+		entry.mutex.Unlock()
+		return nil, ErrUnknownID
+	}
+
+	return entry, nil
+}
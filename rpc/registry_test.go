@@ -0,0 +1,83 @@
+package rpc
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"gitlab.com/NebulousLabs/Sia/types"
+
+	"github.com/javgh/roadie/bob"
+	"github.com/javgh/roadie/keypair"
+	"github.com/javgh/roadie/trader"
+)
+
+func TestRegistry(t *testing.T) {
+	s := BobServer{swaps: make(map[uuid.UUID]*swapEntry)}
+	sessions := make([]Session, 2)
+	entries := make([]*swapEntry, 2)
+	for i := range entries {
+		sessionKeypair, err := keypair.Generate()
+		if err != nil {
+			t.Fatal(err)
+		}
+		atomicSwap := bob.NewAtomicSwap(nil, nil, nil, bob.NewBlacklist(), time.Now())
+		sessions[i] = Session{ID: atomicSwap.ID, Keypair: sessionKeypair}
+		entries[i] = newSwapEntry(atomicSwap, sessionKeypair.PubKey)
+		s.add(entries[i])
+	}
+
+	announce := func(session Session) (*swapEntry, error) {
+		req := ADRequest{ID: session.ID}
+		err := signRequest("AnnounceDeposit", session, &req, &req.Signature)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s.lookup("AnnounceDeposit", req.ID, &req, &req.Signature)
+	}
+
+	busy, err := announce(sessions[0]) // simulate a slow operation on the first swap
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error)
+	go func() {
+		entry, err := announce(sessions[1])
+		if err == nil {
+			entry.unlock()
+		}
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		assert.Nil(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("should not wait for other swap")
+	}
+	assert.Equal(t, 2, len(s.Snapshot()), "should not wait for swap in progress")
+
+	go func() {
+		_, err := announce(sessions[0])
+		done <- err
+	}()
+	s.remove(busy)
+	busy.unlock()
+	assert.Equal(t, ErrUnknownID, <-done, "should not operate on removed swap")
+	assert.Equal(t, 1, len(s.Snapshot()))
+}
+
+func TestUpdateLoad(t *testing.T) {
+	policy := trader.NewAntiSpamPolicy(trader.AntiSpamConfig{Base: *big.NewInt(100), LoadFactor: big.NewRat(1, 2)})
+	s := BobServer{swaps: make(map[uuid.UUID]*swapEntry), policy: policy}
+
+	s.updateLoad()
+	assert.Equal(t, *big.NewInt(100), policy.Fee(types.ZeroCurrency))
+
+	atomicSwap := bob.NewAtomicSwap(nil, nil, nil, bob.NewBlacklist(), time.Now())
+	s.add(newSwapEntry(atomicSwap, nil))
+	s.updateLoad()
+	assert.Equal(t, *big.NewInt(150), policy.Fee(types.ZeroCurrency), "expected swap awaiting binding offer to count")
+}
@@ -10,11 +10,11 @@ import (
 	"log"
 	"math/big"
 	"net"
-	"sort"
 	"sync"
 	"time"
 
 	"github.com/HyperspaceApp/ed25519"
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/google/uuid"
 	"gitlab.com/NebulousLabs/Sia/types"
 	"google.golang.org/grpc"
@@ -23,6 +23,9 @@ import (
 
 	"github.com/javgh/roadie/blockchain/ethereum"
 	"github.com/javgh/roadie/bob"
+	"github.com/javgh/roadie/keypair"
+	"github.com/javgh/roadie/ledger"
+	"github.com/javgh/roadie/output"
 	"github.com/javgh/roadie/trader"
 )
 
@@ -34,6 +37,18 @@ var (
 	ErrNotImplemented     = errors.New("interceptor support is not implemented")
 	ErrUnknownID          = errors.New("unknown id")
 	ErrInvalidCertificate = errors.New("unable to parse certificate")
+	ErrNoQuotes           = errors.New("server does not provide quotes")
+
+	// events sent to the notifier when a swap enters one of these states
+	transitionEvents = map[string]string{
+		"stateMadeBindingOffer":       "swap_binding_offer",
+		"stateOfferAccepted":          "swap_offer_accepted",
+		"stateFunded":                 "swap_funded",
+		"stateProvidedAdaptorDetails": "swap_adaptor_details",
+		"stateCompleted":              "swap_completed",
+		"stateRefunded":               "swap_refunded",
+		"stateAborted":                "swap_aborted",
+	}
 
 	serviceDesc = grpc.ServiceDesc{
 		ServiceName: "Roadie",
@@ -63,6 +78,10 @@ var (
 				MethodName: "AnnounceDeposit",
 				Handler:    announceDepositHandler,
 			},
+			{
+				MethodName: "GetQuote",
+				Handler:    getQuoteHandler,
+			},
 		},
 		Streams: []grpc.StreamDesc{},
 	}
@@ -92,14 +111,28 @@ type (
 		EnableFunding(req *EFRequest) (*EFResponse, error)
 		RequestAdaptorDetails(req *RADRequest) (*RADResponse, error)
 		AnnounceDeposit(req *ADRequest) (*ADResponse, error)
+		GetQuote(req *GQRequest) (*GQResponse, error)
+	}
+
+	// Notifier is informed about state transitions of swaps and about errors,
+	// for example to alert an operator. See package webhook.
+	Notifier interface {
+		Notify(event string, fields output.Fields)
 	}
 
 	BobServer struct {
-		mutex         sync.Mutex
-		atomicSwaps   map[uuid.UUID]*bob.AtomicSwap
+		mutex         sync.RWMutex // guards swaps, but not the swaps themselves
+		bindingMutex  sync.Mutex   // binding offers are made one at a time
+		swaps         map[uuid.UUID]*swapEntry
 		listener      net.Listener
 		grpcServer    *grpc.Server
 		newAtomicSwap func(now time.Time) *bob.AtomicSwap
+		ledger        *ledger.Ledger
+		notifier      Notifier
+		quoteEngine   *trader.QuoteEngine
+		policy        *trader.AntiSpamPolicy
+		limits        Limits
+		limiter       *rateLimiter
 		target        string
 		cert          []byte
 	}
@@ -107,12 +140,17 @@ type (
 
 type (
 	RNBORequest struct {
-		Siacoin types.Currency
+		Siacoin    types.Currency
+		Ether      *big.Int // if set, request as many siacoins as this amount of ether buys
+		SessionKey ed25519.PublicKey
+
+		remoteHost string
 	}
 
 	RNBOResponse struct {
-		ID    uuid.UUID
-		Offer *trader.Offer
+		ID      uuid.UUID
+		Siacoin types.Currency
+		Offer   *trader.Offer
 	}
 )
 
@@ -120,18 +158,43 @@ func (s *BobServer) RequestNonBindingOffer(req *RNBORequest) (*RNBOResponse, err
 	var err error
 	resp := new(RNBOResponse)
 
-	s.mutex.Lock()
-	defer s.mutex.Unlock()
+	if len(req.SessionKey) != ed25519.PublicKeySize {
+		return nil, ErrMissingSessionKey
+	}
+
+	err = s.limit(req.remoteHost)
+	if err != nil {
+		return nil, err
+	}
+
+	// may be exceeded slightly by concurrent requests
+	if s.limits.MaxPendingSwaps > 0 && s.pendingSwaps() >= s.limits.MaxPendingSwaps {
+		log.Printf("Rejected RequestNonBindingOffer from %s: too many pending swaps\n", req.remoteHost)
+		return nil, ErrBusy
+	}
 
+	s.updateLoad()
 	atomicSwap := s.newAtomicSwap(time.Now())
-	s.atomicSwaps[atomicSwap.ID] = atomicSwap
 
-	log.Printf("[%s] RequestNonBindingOffer; %s\n", atomicSwap.ID, req.Siacoin.HumanString())
+	if req.Ether != nil {
+		log.Printf("[%s] RequestNonBindingOffer; %s\n", atomicSwap.ID, ethereum.FormatEther(req.Ether))
 
-	resp.Offer, err = atomicSwap.RequestNonBindingOffer(req.Siacoin, time.Now())
-	if err != nil {
-		return nil, err
+		var siacoin *types.Currency
+		siacoin, resp.Offer, err = atomicSwap.RequestNonBindingOfferForEther(*req.Ether, time.Now())
+		if err != nil {
+			return nil, err
+		}
+		resp.Siacoin = *siacoin
+	} else {
+		log.Printf("[%s] RequestNonBindingOffer; %s\n", atomicSwap.ID, req.Siacoin.HumanString())
+
+		resp.Offer, err = atomicSwap.RequestNonBindingOffer(req.Siacoin, time.Now())
+		if err != nil {
+			return nil, err
+		}
+		resp.Siacoin = req.Siacoin
 	}
+	s.add(newSwapEntry(atomicSwap, req.SessionKey))
 	resp.ID = atomicSwap.ID
 
 	return resp, nil
@@ -148,6 +211,7 @@ func requestNonBindingOfferHandler(srv interface{}, ctx context.Context, dec fun
 	if err != nil {
 		return nil, err
 	}
+	in.remoteHost = remoteHost(ctx)
 
 	return srv.(Server).RequestNonBindingOffer(in)
 }
@@ -156,6 +220,7 @@ type (
 	RBORequest struct {
 		ID         uuid.UUID
 		AntiSpamID big.Int
+		Signature  []byte
 	}
 
 	RBOResponse struct {
@@ -167,13 +232,19 @@ func (s *BobServer) RequestBindingOffer(req *RBORequest) (*RBOResponse, error) {
 	var err error
 	resp := new(RBOResponse)
 
-	s.mutex.Lock()
-	defer s.mutex.Unlock()
-
-	atomicSwap, ok := s.atomicSwaps[req.ID]
-	if !ok {
-		return nil, ErrUnknownID
+	entry, err := s.lookup("RequestBindingOffer", req.ID, req, &req.Signature)
+	if err != nil {
+		return nil, err
 	}
+	defer entry.unlock()
+	atomicSwap := entry.atomicSwap
+
+	// the trader only pauses once the offer has been made, so two offers
+	// must not be prepared at the same time
+	s.bindingMutex.Lock()
+	defer s.bindingMutex.Unlock()
+
+	defer s.notifyTransition(atomicSwap, atomicSwap.StateText(), nil)
 
 	log.Printf("[%s] RequestBindingOffer; %s\n", atomicSwap.ID, req.AntiSpamID.String())
 
@@ -204,6 +275,7 @@ type (
 	AORequest struct {
 		ID          uuid.UUID
 		AlicePubKey ed25519.PublicKey
+		Signature   []byte
 	}
 
 	AOResponse struct {
@@ -215,13 +287,14 @@ func (s *BobServer) AcceptOffer(req *AORequest) (*AOResponse, error) {
 	var err error
 	resp := new(AOResponse)
 
-	s.mutex.Lock()
-	defer s.mutex.Unlock()
-
-	atomicSwap, ok := s.atomicSwaps[req.ID]
-	if !ok {
-		return nil, ErrUnknownID
+	entry, err := s.lookup("AcceptOffer", req.ID, req, &req.Signature)
+	if err != nil {
+		return nil, err
 	}
+	defer entry.unlock()
+	atomicSwap := entry.atomicSwap
+
+	defer s.notifyTransition(atomicSwap, atomicSwap.StateText(), nil)
 
 	log.Printf("[%s] AcceptOffer\n", atomicSwap.ID)
 
@@ -253,6 +326,7 @@ type (
 		ID                    uuid.UUID
 		AliceRefundNoncePoint ed25519.CurvePoint
 		RefundSigAlice        []byte
+		Signature             []byte
 	}
 
 	EFResponse struct {
@@ -264,13 +338,14 @@ func (s *BobServer) EnableFunding(req *EFRequest) (*EFResponse, error) {
 	var err error
 	resp := new(EFResponse)
 
-	s.mutex.Lock()
-	defer s.mutex.Unlock()
-
-	atomicSwap, ok := s.atomicSwaps[req.ID]
-	if !ok {
-		return nil, ErrUnknownID
+	entry, err := s.lookup("EnableFunding", req.ID, req, &req.Signature)
+	if err != nil {
+		return nil, err
 	}
+	defer entry.unlock()
+	atomicSwap := entry.atomicSwap
+
+	defer s.notifyTransition(atomicSwap, atomicSwap.StateText(), nil)
 
 	log.Printf("[%s] EnableFunding\n", atomicSwap.ID)
 
@@ -302,6 +377,7 @@ type (
 		ID                   uuid.UUID
 		AliceClaimUnlockHash types.UnlockHash
 		AliceClaimNoncePoint ed25519.CurvePoint
+		Signature            []byte
 	}
 
 	RADResponse struct {
@@ -313,13 +389,14 @@ func (s *BobServer) RequestAdaptorDetails(req *RADRequest) (*RADResponse, error)
 	var err error
 	resp := new(RADResponse)
 
-	s.mutex.Lock()
-	defer s.mutex.Unlock()
-
-	atomicSwap, ok := s.atomicSwaps[req.ID]
-	if !ok {
-		return nil, ErrUnknownID
+	entry, err := s.lookup("RequestAdaptorDetails", req.ID, req, &req.Signature)
+	if err != nil {
+		return nil, err
 	}
+	defer entry.unlock()
+	atomicSwap := entry.atomicSwap
+
+	defer s.notifyTransition(atomicSwap, atomicSwap.StateText(), nil)
 
 	log.Printf("[%s] RequestAdaptorDetails\n", atomicSwap.ID)
 
@@ -348,7 +425,8 @@ func requestAdaptorDetailsHandler(srv interface{}, ctx context.Context, dec func
 
 type (
 	ADRequest struct {
-		ID uuid.UUID
+		ID        uuid.UUID
+		Signature []byte
 	}
 
 	ADResponse struct{}
@@ -358,13 +436,14 @@ func (s *BobServer) AnnounceDeposit(req *ADRequest) (*ADResponse, error) {
 	var err error
 	resp := new(ADResponse)
 
-	s.mutex.Lock()
-	defer s.mutex.Unlock()
-
-	atomicSwap, ok := s.atomicSwaps[req.ID]
-	if !ok {
-		return nil, ErrUnknownID
+	entry, err := s.lookup("AnnounceDeposit", req.ID, req, &req.Signature)
+	if err != nil {
+		return nil, err
 	}
+	defer entry.unlock()
+	atomicSwap := entry.atomicSwap
+
+	defer s.notifyTransition(atomicSwap, atomicSwap.StateText(), nil)
 
 	log.Printf("[%s] AnnounceDeposit\n", atomicSwap.ID)
 
@@ -373,9 +452,32 @@ func (s *BobServer) AnnounceDeposit(req *ADRequest) (*ADResponse, error) {
 		return nil, err
 	}
 
+	snapshot := atomicSwap.Snapshot()
+	s.record(ledger.Entry{
+		ID:          snapshot.ID,
+		Time:        time.Now(),
+		Siacoin:     snapshot.Siacoin,
+		MinerFees:   snapshot.MinerFees,
+		Ether:       snapshot.Ether,
+		ClaimFee:    snapshot.ClaimFee,
+		AntiSpamFee: snapshot.AntiSpamFee,
+	})
+
 	return resp, nil
 }
 
+// record adds an entry to the ledger, if there is one.
+func (s *BobServer) record(entry ledger.Entry) {
+	if s.ledger == nil {
+		return
+	}
+
+	err := s.ledger.Record(entry)
+	if err != nil {
+		log.Printf("[%s] Unable to record swap in ledger: %s\n", entry.ID, err)
+	}
+}
+
 func announceDepositHandler(srv interface{}, ctx context.Context, dec func(interface{}) error,
 	interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	if interceptor != nil {
@@ -391,8 +493,12 @@ func announceDepositHandler(srv interface{}, ctx context.Context, dec func(inter
 	return srv.(Server).AnnounceDeposit(in)
 }
 
+// NewBobServer creates a server for Alice to connect to. If ledger is not nil,
+// completed swaps are recorded in it. If notifier is not nil, it is informed
+// about state transitions and errors.
 func NewBobServer(network string, address string, certFile string, keyFile string, target string,
-	newAtomicSwap func(now time.Time) *bob.AtomicSwap) (*BobServer, error) {
+	newAtomicSwap func(now time.Time) *bob.AtomicSwap, ledger *ledger.Ledger,
+	notifier Notifier) (*BobServer, error) {
 	opts := []grpc.ServerOption{}
 	if certFile != "" && keyFile != "" {
 		creds, err := credentials.NewServerTLSFromFile(certFile, keyFile)
@@ -418,9 +524,11 @@ func NewBobServer(network string, address string, certFile string, keyFile strin
 	}
 
 	bobServer := BobServer{
-		atomicSwaps:   make(map[uuid.UUID]*bob.AtomicSwap),
+		swaps:         make(map[uuid.UUID]*swapEntry),
 		listener:      listener,
 		newAtomicSwap: newAtomicSwap,
+		ledger:        ledger,
+		notifier:      notifier,
 		target:        target,
 		cert:          cert,
 	}
@@ -430,12 +538,132 @@ func NewBobServer(network string, address string, certFile string, keyFile strin
 	return &bobServer, nil
 }
 
-func (s *BobServer) Register(maxAge big.Int, ethChain ethereum.Blockchain) error {
-	s.mutex.Lock()
-	defer s.mutex.Unlock()
+type (
+	GQRequest struct {
+		remoteHost string
+	}
+
+	GQResponse struct {
+		Quote *trader.Quote
+	}
+)
+
+// GetQuote returns indicative prices without starting a swap. It does not
+// change any state and therefore does not need to hold the mutex.
+func (s *BobServer) GetQuote(req *GQRequest) (*GQResponse, error) {
+	if s.quoteEngine == nil {
+		return nil, ErrNoQuotes
+	}
+
+	err := s.limit(req.remoteHost)
+	if err != nil {
+		return nil, err
+	}
+
+	quote, err := s.quoteEngine.Quote(time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	return &GQResponse{Quote: quote}, nil
+}
+
+func getQuoteHandler(srv interface{}, ctx context.Context, dec func(interface{}) error,
+	interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	if interceptor != nil {
+		return nil, ErrNotImplemented
+	}
+
+	in := new(GQRequest)
+	err := dec(in)
+	if err != nil {
+		return nil, err
+	}
+	in.remoteHost = remoteHost(ctx)
+
+	return srv.(Server).GetQuote(in)
+}
+
+// SetQuoteEngine enables GetQuote. It must be called before the server is
+// used in any other way.
+func (s *BobServer) SetQuoteEngine(quoteEngine *trader.QuoteEngine) {
+	s.quoteEngine = quoteEngine
+}
+
+// SetAntiSpamPolicy lets the server keep the policy informed about its load
+// before each new offer. It must be called before the server is used in any
+// other way.
+func (s *BobServer) SetAntiSpamPolicy(policy *trader.AntiSpamPolicy) {
+	s.policy = policy
+}
+
+// SetLimits enables rate limiting and restricts the number of swaps awaiting
+// a binding offer. It must be called before the server is used in any other
+// way.
+func (s *BobServer) SetLimits(limits Limits) {
+	s.limits = limits
+	if limits.RequestsPerClient > 0 || limits.RequestsGlobal > 0 {
+		s.limiter = newRateLimiter(limits.RequestsPerClient, limits.RequestsGlobal)
+	}
+}
+
+// limit checks a free request from the given host against the rate limits.
+func (s *BobServer) limit(host string) error {
+	if s.limiter == nil {
+		return nil
+	}
+
+	err := s.limiter.allow(host, time.Now())
+	if err != nil {
+		log.Printf("Rejected request from %s: %s\n", host, err)
+	}
+	return err
+}
+
+// pendingSwaps counts swaps awaiting a binding offer.
+func (s *BobServer) pendingSwaps() int {
+	pending := 0
+	for _, entry := range s.entries() {
+		if entry.current().awaitingBindingOffer {
+			pending++
+		}
+	}
+	return pending
+}
+
+// updateLoad counts swaps in progress and failed binding offers among all
+// known swaps. Swaps still awaiting a binding offer count as in progress as
+// well, since they can turn into one at any time.
+func (s *BobServer) updateLoad() {
+	if s.policy == nil {
+		return
+	}
 
+	inProgress, resolved, failed := 0, 0, 0
+	for _, entry := range s.entries() {
+		status := entry.current()
+		if status.awaitingBindingOffer {
+			inProgress++
+			continue
+		}
+
+		switch status.outcome {
+		case bob.OutcomePending:
+			inProgress++
+		case bob.OutcomeSucceeded:
+			resolved++
+		case bob.OutcomeFailed:
+			resolved++
+			failed++
+		}
+	}
+	s.policy.SetLoad(inProgress, resolved, failed)
+}
+
+func (s *BobServer) Register(maxAge big.Int, ethChain ethereum.Blockchain) error {
 	serverDetails, err := ethChain.FetchServers(maxAge)
 	if err != nil {
+		s.notifyError("register_failed", err)
 		return err
 	}
 
@@ -448,7 +676,11 @@ func (s *BobServer) Register(maxAge big.Int, ethChain ethereum.Blockchain) error
 	}
 
 	if !alreadyRegistered {
-		return ethChain.RegisterServer(s.target, s.cert)
+		err = ethChain.RegisterServer(s.target, s.cert)
+		if err != nil {
+			s.notifyError("register_failed", err)
+			return err
+		}
 	}
 
 	return nil
@@ -459,82 +691,233 @@ func (s *BobServer) Serve() error {
 }
 
 func (s *BobServer) Report() {
-	s.mutex.Lock()
-	defer s.mutex.Unlock()
+	entries := s.entries()
+	for _, entry := range entries {
+		log.Printf("State of %s: %s\n", entry.atomicSwap.ID, entry.current().snapshot.State)
+	}
 
-	var keys []string
-	for k := range s.atomicSwaps {
-		keys = append(keys, k.String())
+	for _, entry := range entries {
+		refundTx := entry.current().refundTx
+		if refundTx != "" {
+			log.Printf("Refund tx for %s: %s\n", entry.atomicSwap.ID, refundTx)
+		}
 	}
+}
 
-	sort.Strings(keys)
-	for _, uuidStr := range keys {
-		k := uuid.MustParse(uuidStr)
-		log.Printf("State of %s: %s\n", k, s.atomicSwaps[k].StateText())
+// Snapshot returns the details of all swaps currently known, ordered by ID.
+func (s *BobServer) Snapshot() []bob.Snapshot {
+	var snapshots []bob.Snapshot
+	for _, entry := range s.entries() {
+		snapshots = append(snapshots, entry.current().snapshot)
 	}
+	return snapshots
+}
 
-	for _, uuidStr := range keys {
-		k := uuid.MustParse(uuidStr)
-		encodedTx, hasTx := s.atomicSwaps[k].EncodedRefundTransaction()
-		if hasTx {
-			log.Printf("Refund tx for %s: %s\n", k, encodedTx)
+// Sweep transfers the part of the wallet balance that exceeds reserve to
+// recipient, as long as that part is at least minimum. It returns the amount
+// transferred or nil if nothing was swept. While a claim might be pending, no
+// sweep takes place, so that the claim does not compete with it.
+func (s *BobServer) Sweep(ethChain ethereum.Blockchain, recipient common.Address,
+	reserve big.Int, minimum big.Int) (*big.Int, error) {
+	for _, entry := range s.entries() {
+		if entry.current().claimPending {
+			log.Printf("Skipping sweep while claim for %s is pending\n", entry.atomicSwap.ID)
+			return nil, nil
 		}
 	}
+
+	balance, err := ethChain.Balance()
+	if err != nil {
+		return nil, err
+	}
+
+	amount := new(big.Int).Sub(balance, &reserve)
+	if amount.Cmp(&minimum) < 0 || amount.Sign() <= 0 {
+		return nil, nil
+	}
+
+	err = ethChain.Transfer(recipient, *amount)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Swept %s to %s\n", ethereum.FormatEther(amount), recipient.String())
+	return amount, nil
 }
 
-func (s *BobServer) Check(now time.Time) error {
-	s.mutex.Lock()
-	defer s.mutex.Unlock()
+// WithdrawCredit withdraws anti-spam fees that the smart contract credited
+// to the server. This happens when a client reclaims a deposit instead of
+// completing the swap. It returns the amount withdrawn, which may be zero.
+func (s *BobServer) WithdrawCredit(ethChain ethereum.Blockchain) (*big.Int, error) {
+	credit, err := ethChain.WithdrawCredit()
+	if err != nil {
+		return nil, err
+	}
 
-	var keys []string
-	for k := range s.atomicSwaps {
-		keys = append(keys, k.String())
+	if credit.Sign() == 1 {
+		log.Printf("Withdrew credited anti-spam fees of %s\n", ethereum.FormatEther(credit))
+		s.record(ledger.Entry{Time: time.Now(), Collected: credit})
 	}
+	return credit, nil
+}
 
-	sort.Strings(keys)
-	for _, uuidStr := range keys {
-		k := uuid.MustParse(uuidStr)
-		noLongerNeeded, refundTxID, err := s.atomicSwaps[k].Check(now)
+// Check advances all swaps and drops those that are no longer needed. Each
+// swap is only locked while it is being checked, so requests for other swaps
+// are served in the meantime.
+func (s *BobServer) Check(now time.Time) error {
+	evicted := 0
+	for _, entry := range s.entries() {
+		stale, err := s.checkSwap(entry, now)
 		if err != nil {
+			s.notifyError("check_failed", err)
 			return err
 		}
-
-		if refundTxID != nil {
-			log.Printf("Broadcasted refund transaction %s for %s.\n", refundTxID, k)
+		if stale {
+			evicted++
 		}
+	}
 
-		if noLongerNeeded {
-			delete(s.atomicSwaps, k)
-		}
+	if evicted > 0 {
+		log.Printf("Dropped %d swaps that did not request a binding offer in time.\n", evicted)
+	}
+	if s.limiter != nil {
+		s.limiter.prune(now)
 	}
 
 	return nil
 }
 
+// checkSwap checks a single swap and reports whether it was dropped for not
+// requesting a binding offer in time.
+func (s *BobServer) checkSwap(entry *swapEntry, now time.Time) (bool, error) {
+	entry.lock()
+	defer entry.unlock()
+
+	atomicSwap := entry.atomicSwap
+	if entry.removed {
+		return false, nil
+	}
+
+	if s.limits.OfferTimeout > 0 && atomicSwap.Stale(now, s.limits.OfferTimeout) {
+		s.remove(entry)
+		return true, nil
+	}
+
+	before := atomicSwap.StateText()
+	noLongerNeeded, refundTxID, err := atomicSwap.Check(now)
+	if err != nil {
+		return false, err
+	}
+
+	var fields output.Fields
+	if refundTxID != nil {
+		log.Printf("Broadcasted refund transaction %s for %s.\n", refundTxID, atomicSwap.ID)
+		fields = output.Fields{"refund_txid": refundTxID.String()}
+	}
+	s.notifyTransition(atomicSwap, before, fields)
+
+	if noLongerNeeded {
+		collected := atomicSwap.Snapshot().Collected
+		if collected != nil && collected.Sign() == 1 {
+			log.Printf("Collected escrowed anti-spam fee of %s for %s.\n", ethereum.FormatEther(collected), atomicSwap.ID)
+			s.record(ledger.Entry{ID: atomicSwap.ID, Time: now, Collected: collected})
+		}
+		s.remove(entry)
+	}
+
+	return false, nil
+}
+
+func (s *BobServer) notifyTransition(atomicSwap *bob.AtomicSwap, before string, extra output.Fields) {
+	if s.notifier == nil {
+		return
+	}
+
+	snapshot := atomicSwap.Snapshot()
+	event, ok := transitionEvents[snapshot.State]
+	if !ok || snapshot.State == before {
+		return
+	}
+
+	fields := output.Fields{
+		"id":           snapshot.ID.String(),
+		"from":         before,
+		"state":        snapshot.State,
+		"siacoin":      snapshot.Siacoin.String(),
+		"ether":        snapshot.Ether.String(),
+		"anti_spam_id": snapshot.AntiSpamID.String(),
+		"deadline":     snapshot.Deadline.UTC().Format(time.RFC3339),
+	}
+	for k, v := range extra {
+		fields[k] = v
+	}
+	s.notifier.Notify(event, fields)
+}
+
+func (s *BobServer) notifyError(event string, err error) {
+	if s.notifier == nil {
+		return
+	}
+
+	s.notifier.Notify(event, output.Fields{"message": err.Error()})
+}
+
 type Client struct {
 	conn *grpc.ClientConn
 }
 
-func (c *Client) RequestNonBindingOffer(siacoin types.Currency) (*uuid.UUID, *trader.Offer, error) {
+// RequestNonBindingOffer starts a new session, which has to be passed to all
+// further requests for the swap.
+func (c *Client) RequestNonBindingOffer(siacoin types.Currency) (*Session, *trader.Offer, error) {
+	sessionKeypair, err := keypair.Generate()
+	if err != nil {
+		return nil, nil, err
+	}
+
 	in := RNBORequest{
-		Siacoin: siacoin,
+		Siacoin:    siacoin,
+		SessionKey: sessionKeypair.PubKey,
 	}
 	out := new(RNBOResponse)
-	err := grpc.Invoke(context.Background(), "/Roadie/RequestNonBindingOffer", &in, out, c.conn)
+	err = grpc.Invoke(context.Background(), "/Roadie/RequestNonBindingOffer", &in, out, c.conn)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	return &out.ID, out.Offer, nil
+	return &Session{ID: out.ID, Keypair: sessionKeypair}, out.Offer, nil
 }
 
-func (c *Client) RequestBindingOffer(id uuid.UUID, antiSpamID big.Int) (*trader.Offer, error) {
+func (c *Client) RequestNonBindingOfferForEther(ether big.Int) (*Session, *types.Currency, *trader.Offer, error) {
+	sessionKeypair, err := keypair.Generate()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	in := RNBORequest{
+		Ether:      &ether,
+		SessionKey: sessionKeypair.PubKey,
+	}
+	out := new(RNBOResponse)
+	err = grpc.Invoke(context.Background(), "/Roadie/RequestNonBindingOffer", &in, out, c.conn)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return &Session{ID: out.ID, Keypair: sessionKeypair}, &out.Siacoin, out.Offer, nil
+}
+
+func (c *Client) RequestBindingOffer(session Session, antiSpamID big.Int) (*trader.Offer, error) {
 	in := RBORequest{
-		ID:         id,
+		ID:         session.ID,
 		AntiSpamID: antiSpamID,
 	}
+	err := signRequest("RequestBindingOffer", session, &in, &in.Signature)
+	if err != nil {
+		return nil, err
+	}
+
 	out := new(RBOResponse)
-	err := grpc.Invoke(context.Background(), "/Roadie/RequestBindingOffer", &in, out, c.conn)
+	err = grpc.Invoke(context.Background(), "/Roadie/RequestBindingOffer", &in, out, c.conn)
 	if err != nil {
 		return nil, err
 	}
@@ -542,13 +925,18 @@ func (c *Client) RequestBindingOffer(id uuid.UUID, antiSpamID big.Int) (*trader.
 	return out.Offer, nil
 }
 
-func (c *Client) AcceptOffer(id uuid.UUID, alicePubKey ed25519.PublicKey) (*bob.RefundDetails, error) {
+func (c *Client) AcceptOffer(session Session, alicePubKey ed25519.PublicKey) (*bob.RefundDetails, error) {
 	in := AORequest{
-		ID:          id,
+		ID:          session.ID,
 		AlicePubKey: alicePubKey,
 	}
+	err := signRequest("AcceptOffer", session, &in, &in.Signature)
+	if err != nil {
+		return nil, err
+	}
+
 	out := new(AOResponse)
-	err := grpc.Invoke(context.Background(), "/Roadie/AcceptOffer", &in, out, c.conn)
+	err = grpc.Invoke(context.Background(), "/Roadie/AcceptOffer", &in, out, c.conn)
 	if err != nil {
 		return nil, err
 	}
@@ -556,15 +944,20 @@ func (c *Client) AcceptOffer(id uuid.UUID, alicePubKey ed25519.PublicKey) (*bob.
 	return out.RefundDetails, nil
 }
 
-func (c *Client) EnableFunding(id uuid.UUID,
+func (c *Client) EnableFunding(session Session,
 	aliceRefundNoncePoint ed25519.CurvePoint, refundSigAlice []byte) (*types.TransactionID, error) {
 	in := EFRequest{
-		ID:                    id,
+		ID:                    session.ID,
 		AliceRefundNoncePoint: aliceRefundNoncePoint,
 		RefundSigAlice:        refundSigAlice,
 	}
+	err := signRequest("EnableFunding", session, &in, &in.Signature)
+	if err != nil {
+		return nil, err
+	}
+
 	out := new(EFResponse)
-	err := grpc.Invoke(context.Background(), "/Roadie/EnableFunding", &in, out, c.conn)
+	err = grpc.Invoke(context.Background(), "/Roadie/EnableFunding", &in, out, c.conn)
 	if err != nil {
 		return nil, err
 	}
@@ -572,15 +965,20 @@ func (c *Client) EnableFunding(id uuid.UUID,
 	return out.TxID, nil
 }
 
-func (c *Client) RequestAdaptorDetails(id uuid.UUID,
+func (c *Client) RequestAdaptorDetails(session Session,
 	aliceClaimUnlockHash types.UnlockHash, aliceClaimNoncePoint ed25519.CurvePoint) (*bob.AdaptorDetails, error) {
 	in := RADRequest{
-		ID:                   id,
+		ID:                   session.ID,
 		AliceClaimUnlockHash: aliceClaimUnlockHash,
 		AliceClaimNoncePoint: aliceClaimNoncePoint,
 	}
+	err := signRequest("RequestAdaptorDetails", session, &in, &in.Signature)
+	if err != nil {
+		return nil, err
+	}
+
 	out := new(RADResponse)
-	err := grpc.Invoke(context.Background(), "/Roadie/RequestAdaptorDetails", &in, out, c.conn)
+	err = grpc.Invoke(context.Background(), "/Roadie/RequestAdaptorDetails", &in, out, c.conn)
 	if err != nil {
 		return nil, err
 	}
@@ -588,12 +986,17 @@ func (c *Client) RequestAdaptorDetails(id uuid.UUID,
 	return out.AdaptorDetails, nil
 }
 
-func (c *Client) AnnounceDeposit(id uuid.UUID) error {
+func (c *Client) AnnounceDeposit(session Session) error {
 	in := ADRequest{
-		ID: id,
+		ID: session.ID,
+	}
+	err := signRequest("AnnounceDeposit", session, &in, &in.Signature)
+	if err != nil {
+		return err
 	}
+
 	out := new(ADResponse)
-	err := grpc.Invoke(context.Background(), "/Roadie/AnnounceDeposit", &in, out, c.conn)
+	err = grpc.Invoke(context.Background(), "/Roadie/AnnounceDeposit", &in, out, c.conn)
 	if err != nil {
 		return err
 	}
@@ -601,6 +1004,17 @@ func (c *Client) AnnounceDeposit(id uuid.UUID) error {
 	return nil
 }
 
+func (c *Client) GetQuote() (*trader.Quote, error) {
+	in := GQRequest{}
+	out := new(GQResponse)
+	err := grpc.Invoke(context.Background(), "/Roadie/GetQuote", &in, out, c.conn)
+	if err != nil {
+		return nil, err
+	}
+
+	return out.Quote, nil
+}
+
 func (c *Client) Close() error {
 	return c.conn.Close()
 }
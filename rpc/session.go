@@ -0,0 +1,72 @@
+package rpc
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/HyperspaceApp/ed25519"
+	"github.com/google/uuid"
+
+	"github.com/javgh/roadie/keypair"
+)
+
+type (
+	// Session identifies a swap on the server. The client commits to the
+	// public session key when requesting the non-binding offer and signs all
+	// further requests for the swap with it, so that knowing the ID alone is
+	// not enough to interfere with the swap.
+	Session struct {
+		ID      uuid.UUID
+		Keypair keypair.Keypair
+	}
+)
+
+var (
+	ErrMissingSessionKey = errors.New("no session key provided - please upgrade")
+	ErrInvalidSessionSig = errors.New("request is not signed with the session key")
+)
+
+// signRequest signs req, which is sent to method, and stores the signature in
+// sig, which has to point to the signature field of req.
+func signRequest(method string, session Session, req interface{}, sig *[]byte) error {
+	message, err := sessionMessage(method, req, sig)
+	if err != nil {
+		return err
+	}
+
+	*sig = ed25519.Sign(session.Keypair.PrivKey, message)
+	return nil
+}
+
+func verifyRequest(method string, sessionKey ed25519.PublicKey, req interface{}, sig *[]byte) error {
+	if len(*sig) != ed25519.SignatureSize {
+		return ErrInvalidSessionSig
+	}
+
+	signature := *sig
+	message, err := sessionMessage(method, req, sig)
+	if err != nil {
+		return err
+	}
+
+	if !ed25519.Verify(sessionKey, message, signature) {
+		return ErrInvalidSessionSig
+	}
+	return nil
+}
+
+// sessionMessage returns what is signed for a request: the method name
+// followed by the request encoded as JSON, without its signature. The request
+// includes the ID of the swap, so signatures cannot be reused for other swaps
+// or methods.
+func sessionMessage(method string, req interface{}, sig *[]byte) ([]byte, error) {
+	signature := *sig
+	*sig = nil
+	data, err := json.Marshal(req)
+	*sig = signature
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]byte(method+"\n"), data...), nil
+}
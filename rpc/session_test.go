@@ -0,0 +1,73 @@
+package rpc
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/HyperspaceApp/ed25519"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"gitlab.com/NebulousLabs/Sia/types"
+
+	"github.com/javgh/roadie/keypair"
+)
+
+func TestSessionSignature(t *testing.T) {
+	sessionKeypair, err := keypair.Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	session := Session{ID: uuid.Must(uuid.NewRandom()), Keypair: sessionKeypair}
+
+	req := RBORequest{ID: session.ID, AntiSpamID: *big.NewInt(42)}
+	err = signRequest("RequestBindingOffer", session, &req, &req.Signature)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Nil(t, verifyRequest("RequestBindingOffer", session.Keypair.PubKey, &req, &req.Signature))
+	assert.NotNil(t, req.Signature, "should keep signature")
+
+	assert.Equal(t, ErrInvalidSessionSig,
+		verifyRequest("AcceptOffer", session.Keypair.PubKey, &req, &req.Signature),
+		"should not accept signature for other method")
+
+	otherKeypair, err := keypair.Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, ErrInvalidSessionSig,
+		verifyRequest("RequestBindingOffer", otherKeypair.PubKey, &req, &req.Signature),
+		"should not accept signature by other key")
+
+	tampered := req
+	tampered.AntiSpamID = *big.NewInt(43)
+	assert.Equal(t, ErrInvalidSessionSig,
+		verifyRequest("RequestBindingOffer", session.Keypair.PubKey, &tampered, &tampered.Signature),
+		"should not accept modified request")
+
+	unsigned := RBORequest{ID: session.ID, AntiSpamID: *big.NewInt(42)}
+	assert.Equal(t, ErrInvalidSessionSig,
+		verifyRequest("RequestBindingOffer", session.Keypair.PubKey, &unsigned, &unsigned.Signature),
+		"should not accept unsigned request")
+
+	// the server verifies requests after they went through the codec
+	radRequest := RADRequest{
+		ID:                   session.ID,
+		AliceClaimUnlockHash: types.UnlockHash{1, 2, 3},
+		AliceClaimNoncePoint: ed25519.CurvePoint(make([]byte, 32)),
+	}
+	err = signRequest("RequestAdaptorDetails", session, &radRequest, &radRequest.Signature)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := JSONCodec{}.Marshal(&radRequest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var received RADRequest
+	err = JSONCodec{}.Unmarshal(data, &received)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Nil(t, verifyRequest("RequestAdaptorDetails", session.Keypair.PubKey, &received, &received.Signature))
+}
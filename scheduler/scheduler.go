@@ -0,0 +1,382 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gitlab.com/NebulousLabs/Sia/types"
+
+	"github.com/javgh/roadie/alice"
+	"github.com/javgh/roadie/blockchain/ethereum"
+	"github.com/javgh/roadie/frontend"
+	"github.com/javgh/roadie/output"
+	"github.com/javgh/roadie/trader"
+)
+
+type (
+	status string
+
+	// Schedule describes a recurring purchase and keeps track of all runs so
+	// far. It is persisted as JSON, so that a restarted scheduler picks up
+	// where it left off.
+	Schedule struct {
+		Order         alice.Order
+		Interval      time.Duration
+		MaxTotalEther *big.Int // nil means no cap
+		AbsDiffRule   float64
+		RelDiffRule   float64
+		Rules         string // rule expression; see frontend.ParseRules
+		NextRun       time.Time
+		Runs          []Run
+	}
+
+	// Run records the outcome of a single purchase. A failed run still
+	// records the Ether and anti-spam fee it paid before failing, together
+	// with the anti-spam ID needed to reclaim a deposit.
+	Run struct {
+		Time        time.Time
+		Status      status
+		Msg         string
+		Server      string
+		Siacoin     types.Currency
+		Ether       big.Int
+		AntiSpamFee big.Int
+		AntiSpamID  big.Int
+		ClaimTxID   types.TransactionID
+	}
+
+	// Summary adds up all runs. Ether and AntiSpamFee cover completed runs;
+	// FailedSpend is what failed runs paid, which includes the anti-spam fee
+	// of runs that declined the binding offer.
+	Summary struct {
+		Completed   int
+		Skipped     int
+		Failed      int
+		Siacoin     types.Currency
+		Ether       big.Int
+		AntiSpamFee big.Int
+		FailedSpend big.Int
+	}
+
+	// SwapFunc performs a single swap; usually a closure around
+	// alice.PerformSwap.
+	SwapFunc func(order alice.Order, frontend frontend.Frontend) (*alice.Result, error)
+
+	budgetFrontend struct {
+		frontend  frontend.Frontend
+		remaining *big.Int
+	}
+)
+
+const (
+	statusCompleted status = "completed"
+	statusSkipped   status = "skipped"
+	statusFailed    status = "failed"
+
+	maxSleep = 10 * time.Minute
+
+	msgBudgetExhausted = "budget cap reached"
+	msgNoOffer         = "no acceptable offer"
+)
+
+var (
+	ErrNoRules         = errors.New("at least one acceptance rule is required for scheduled purchases")
+	ErrInvalidInterval = errors.New("interval needs to be positive")
+)
+
+func NewSchedule(order alice.Order, interval time.Duration, maxTotalEther *big.Int,
+	absDiffRule float64, relDiffRule float64, rules string, now time.Time) (*Schedule, error) {
+	if interval <= 0 {
+		return nil, ErrInvalidInterval
+	}
+
+	if absDiffRule == 0 && relDiffRule == 0 && rules == "" {
+		return nil, ErrNoRules
+	}
+
+	if rules != "" {
+		if absDiffRule != 0 || relDiffRule != 0 {
+			return nil, frontend.ErrConflictingRules
+		}
+
+		_, err := frontend.ParseRules(rules)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	schedule := Schedule{
+		Order:         order,
+		Interval:      interval,
+		MaxTotalEther: maxTotalEther,
+		AbsDiffRule:   absDiffRule,
+		RelDiffRule:   relDiffRule,
+		Rules:         rules,
+		NextRun:       now,
+	}
+	return &schedule, nil
+}
+
+func Load(path string) (*Schedule, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var schedule Schedule
+	err = json.Unmarshal(data, &schedule)
+	if err != nil {
+		return nil, err
+	}
+
+	return &schedule, nil
+}
+
+func (s *Schedule) Save(path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return err
+	}
+
+	// write to a temporary file first, so that a crash does not leave us with
+	// a truncated schedule
+	tmpPath := path + ".tmp"
+	err = ioutil.WriteFile(tmpPath, data, 0600)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmpPath, path)
+}
+
+func (s *Schedule) Due(now time.Time) bool {
+	return !now.Before(s.NextRun)
+}
+
+// Execute performs one scheduled purchase, records the outcome and moves
+// NextRun forward. Runs that were missed (for example while the scheduler was
+// not running) are not made up for.
+func (s *Schedule) Execute(now time.Time, exchangeRate frontend.Fetcher, swap SwapFunc) Run {
+	run := s.execute(now, exchangeRate, swap)
+	s.Runs = append(s.Runs, run)
+
+	for !s.NextRun.After(now) {
+		s.NextRun = s.NextRun.Add(s.Interval)
+	}
+
+	return run
+}
+
+func (s *Schedule) execute(now time.Time, exchangeRate frontend.Fetcher, swap SwapFunc) Run {
+	run := Run{Time: now}
+
+	var remaining *big.Int
+	if s.MaxTotalEther != nil {
+		summary := s.Summarize()
+		spent := new(big.Int).Add(&summary.Ether, &summary.AntiSpamFee)
+		spent.Add(spent, &summary.FailedSpend)
+		remaining = new(big.Int).Sub(s.MaxTotalEther, spent)
+		if remaining.Sign() != 1 {
+			run.Status = statusSkipped
+			run.Msg = msgBudgetExhausted
+			return run
+		}
+	}
+
+	var selectedFrontend frontend.Frontend
+	if s.Rules != "" {
+		rule, err := frontend.ParseRules(s.Rules)
+		if err != nil {
+			run.Status = statusFailed
+			run.Msg = err.Error()
+			return run
+		}
+		selectedFrontend = frontend.NewRuleExpressionFrontend(rule, frontend.NoBindingTolerance, exchangeRate)
+	} else {
+		selectedFrontend = frontend.NewRuleBasedFrontend(s.AbsDiffRule, s.RelDiffRule, exchangeRate)
+	}
+	if remaining != nil {
+		selectedFrontend = &budgetFrontend{frontend: selectedFrontend, remaining: remaining}
+	}
+
+	result, err := swap(s.Order, selectedFrontend)
+	if err == alice.ErrNoServers || err == alice.ErrNoOffers || (err == nil && result == nil) {
+		run.Status = statusSkipped
+		run.Msg = msgNoOffer
+		return run
+	} else if err != nil {
+		run.Status = statusFailed
+		run.Msg = err.Error()
+		if result != nil {
+			run.Server = result.Server
+			run.Siacoin = result.Siacoin
+			run.Ether = result.Ether
+			run.AntiSpamFee = result.AntiSpamFee
+			run.AntiSpamID = result.AntiSpamID
+		}
+		return run
+	}
+
+	run.Status = statusCompleted
+	run.Server = result.Server
+	run.Siacoin = result.Siacoin
+	run.Ether = result.Ether
+	run.AntiSpamFee = result.AntiSpamFee
+	run.AntiSpamID = result.AntiSpamID
+	run.ClaimTxID = result.ClaimTxID
+	return run
+}
+
+func (s *Schedule) Summarize() Summary {
+	var summary Summary
+	for _, run := range s.Runs {
+		switch run.Status {
+		case statusCompleted:
+			summary.Completed++
+			summary.Siacoin = summary.Siacoin.Add(run.Siacoin)
+			summary.Ether.Add(&summary.Ether, &run.Ether)
+			summary.AntiSpamFee.Add(&summary.AntiSpamFee, &run.AntiSpamFee)
+		case statusSkipped:
+			summary.Skipped++
+		default:
+			summary.Failed++
+			summary.FailedSpend.Add(&summary.FailedSpend, &run.Ether)
+			summary.FailedSpend.Add(&summary.FailedSpend, &run.AntiSpamFee)
+		}
+	}
+	return summary
+}
+
+// Start keeps executing the schedule stored at path until an error occurs. The
+// schedule is saved after every run.
+func Start(path string, exchangeRate frontend.Fetcher, swap SwapFunc, out *output.Output) error {
+	for {
+		schedule, err := Load(path)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		if !schedule.Due(now) {
+			out.Printf("Next purchase scheduled for %s.\n\n", schedule.NextRun.Format(time.RFC1123))
+			out.Event("schedule_waiting", output.Fields{"next_run": schedule.NextRun.UTC().Format(time.RFC3339)})
+			time.Sleep(sleepDuration(schedule.NextRun.Sub(now)))
+			continue
+		}
+
+		run := schedule.Execute(now, exchangeRate, swap)
+		err = schedule.Save(path)
+		if err != nil {
+			return err
+		}
+
+		summary := schedule.Summarize()
+		out.Printf("\n%s\n", run)
+		out.Printf("%s\n", summary)
+		out.Event("schedule_run", run.Fields())
+		out.Event("schedule_summary", summary.Fields())
+	}
+}
+
+func sleepDuration(untilNextRun time.Duration) time.Duration {
+	if untilNextRun > maxSleep {
+		return maxSleep
+	}
+	return untilNextRun
+}
+
+func (r Run) String() string {
+	timestamp := r.Time.Format(time.RFC1123)
+	if r.Status == statusFailed && r.AntiSpamFee.Sign() == 1 {
+		return fmt.Sprintf("%s: %s (%s) after spending %s (+ %s anti-spam fee) with %s; anti-spam ID %s",
+			timestamp, r.Status, r.Msg, ethereum.FormatEther(&r.Ether),
+			ethereum.FormatEther(&r.AntiSpamFee), r.Server, r.AntiSpamID.String())
+	} else if r.Status != statusCompleted {
+		return fmt.Sprintf("%s: %s (%s)", timestamp, r.Status, r.Msg)
+	}
+
+	return fmt.Sprintf("%s: bought %s for %s (+ %s anti-spam fee) from %s; claim transaction %s",
+		timestamp, r.Siacoin.HumanString(), ethereum.FormatEther(&r.Ether),
+		ethereum.FormatEther(&r.AntiSpamFee), r.Server, r.ClaimTxID)
+}
+
+func (r Run) Fields() output.Fields {
+	return output.Fields{
+		"run_time":      r.Time.UTC().Format(time.RFC3339),
+		"status":        string(r.Status),
+		"msg":           r.Msg,
+		"server":        r.Server,
+		"siacoin":       r.Siacoin.String(),
+		"ether":         r.Ether.String(),
+		"anti_spam_fee": r.AntiSpamFee.String(),
+		"anti_spam_id":  r.AntiSpamID.String(),
+		"claim_txid":    r.ClaimTxID.String(),
+	}
+}
+
+func (s Summary) Fields() output.Fields {
+	return output.Fields{
+		"completed":     s.Completed,
+		"skipped":       s.Skipped,
+		"failed":        s.Failed,
+		"siacoin":       s.Siacoin.String(),
+		"ether":         s.Ether.String(),
+		"anti_spam_fee": s.AntiSpamFee.String(),
+		"failed_spend":  s.FailedSpend.String(),
+	}
+}
+
+func (s Summary) String() string {
+	summary := fmt.Sprintf("%d completed, %d skipped, %d failed; bought %s for %s (+ %s anti-spam fees)",
+		s.Completed, s.Skipped, s.Failed, s.Siacoin.HumanString(),
+		ethereum.FormatEther(&s.Ether), ethereum.FormatEther(&s.AntiSpamFee))
+	if s.FailedSpend.Sign() == 1 {
+		summary += fmt.Sprintf("; %s spent on failed runs", ethereum.FormatEther(&s.FailedSpend))
+	}
+	return summary
+}
+
+func (f *budgetFrontend) ChooseOffer(quotes []frontend.Quote) (int, error) {
+	var affordable []frontend.Quote
+	var indices []int
+	for i, quote := range quotes {
+		if f.withinBudget(quote.Offer) {
+			affordable = append(affordable, quote)
+			indices = append(indices, i)
+		}
+	}
+
+	choice, err := f.frontend.ChooseOffer(affordable)
+	if err != nil || choice == frontend.NoChoice {
+		return frontend.NoChoice, err
+	}
+	return indices[choice], nil
+}
+
+func (f *budgetFrontend) ApproveOffer(siacoin types.Currency, offer trader.Offer, binding bool) (bool, error) {
+	if !f.withinBudget(offer) {
+		return false, nil
+	}
+
+	return f.frontend.ApproveOffer(siacoin, offer, binding)
+}
+
+func (f *budgetFrontend) CheckSimilarity(a trader.Offer, b trader.Offer) bool {
+	return f.withinBudget(b) && f.frontend.CheckSimilarity(a, b)
+}
+
+func (f *budgetFrontend) withinBudget(offer trader.Offer) bool {
+	total := new(big.Int).Add(&offer.Ether, &offer.AntiSpamFee)
+	return total.Cmp(f.remaining) != 1
+}
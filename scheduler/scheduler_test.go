@@ -0,0 +1,195 @@
+package scheduler
+
+import (
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gitlab.com/NebulousLabs/Sia/types"
+
+	"github.com/javgh/roadie/alice"
+	"github.com/javgh/roadie/frontend"
+	"github.com/javgh/roadie/trader"
+)
+
+type (
+	MockExchangeRate struct{}
+)
+
+var (
+	oneEther = big.NewInt(1e18)
+)
+
+func (r *MockExchangeRate) Fetch(id string) (*big.Rat, error) {
+	if id == "ethereum" {
+		return new(big.Rat).SetInt(oneEther), nil
+	}
+
+	return new(big.Rat).SetInt(types.SiacoinPrecision.Big()), nil
+}
+
+// fakeSwap pretends to buy siacoins for the given amount of ether, as long as
+// the frontend approves.
+func fakeSwap(ether int64) SwapFunc {
+	return func(order alice.Order, f frontend.Frontend) (*alice.Result, error) {
+		offer := trader.Offer{Available: true, Ether: *big.NewInt(ether)}
+		approved, err := f.ApproveOffer(order.Siacoin, offer, false)
+		if err != nil {
+			return nil, err
+		}
+		if !approved {
+			return nil, nil
+		}
+
+		result := alice.Result{Server: "server", Siacoin: order.Siacoin, Ether: *big.NewInt(ether)}
+		return &result, nil
+	}
+}
+
+func TestSchedule(t *testing.T) {
+	exchangeRate := &MockExchangeRate{}
+	now := time.Now()
+	order := alice.Order{Siacoin: types.NewCurrency64(100)} // worth 100 wei at mock exchange rates
+
+	_, err := NewSchedule(order, time.Hour, nil, 0, 0, "", now)
+	assert.Equal(t, ErrNoRules, err, "should require acceptance rules")
+
+	schedule, err := NewSchedule(order, time.Hour, big.NewInt(250), 0, 5.0, "", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, schedule.Due(now), "should be due right away")
+
+	run := schedule.Execute(now, exchangeRate, fakeSwap(100))
+	assert.Equal(t, statusCompleted, run.Status, "should complete first run")
+	assert.False(t, schedule.Due(now), "should not be due again immediately")
+	assert.Equal(t, now.Add(time.Hour), schedule.NextRun, "should schedule next run")
+
+	later := now.Add(3*time.Hour + time.Minute)
+	run = schedule.Execute(later, exchangeRate, fakeSwap(200))
+	assert.Equal(t, statusSkipped, run.Status, "should skip expensive offer")
+	assert.Equal(t, now.Add(4*time.Hour), schedule.NextRun, "should not make up for missed runs")
+
+	run = schedule.Execute(later, exchangeRate, fakeSwap(100))
+	assert.Equal(t, statusCompleted, run.Status, "should complete run within budget")
+
+	run = schedule.Execute(later, exchangeRate, fakeSwap(100))
+	assert.Equal(t, statusSkipped, run.Status, "should skip run exceeding remaining budget")
+
+	failingSwap := func(order alice.Order, f frontend.Frontend) (*alice.Result, error) {
+		return nil, errors.New("failure")
+	}
+	run = schedule.Execute(later, exchangeRate, failingSwap)
+	assert.Equal(t, statusFailed, run.Status, "should record failed run")
+
+	summary := schedule.Summarize()
+	assert.Equal(t, 2, summary.Completed)
+	assert.Equal(t, 2, summary.Skipped)
+	assert.Equal(t, 1, summary.Failed)
+	assert.Equal(t, types.NewCurrency64(200), summary.Siacoin)
+	assert.Equal(t, big.NewInt(200), &summary.Ether)
+	assert.Equal(t, 0, summary.FailedSpend.Sign())
+}
+
+func TestScheduleFailedSpend(t *testing.T) {
+	exchangeRate := &MockExchangeRate{}
+	now := time.Now()
+	order := alice.Order{Siacoin: types.NewCurrency64(100)}
+
+	schedule, err := NewSchedule(order, time.Hour, big.NewInt(250), 0, 5.0, "", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	failingSwap := func(order alice.Order, f frontend.Frontend) (*alice.Result, error) {
+		result := alice.Result{Server: "server", Ether: *big.NewInt(100), AntiSpamFee: *big.NewInt(10),
+			AntiSpamID: *big.NewInt(42)}
+		return &result, errors.New("failure")
+	}
+	run := schedule.Execute(now, exchangeRate, failingSwap)
+	assert.Equal(t, statusFailed, run.Status)
+	assert.Equal(t, big.NewInt(42), &run.AntiSpamID, "should record anti-spam id of failed run")
+
+	summary := schedule.Summarize()
+	assert.Equal(t, big.NewInt(110), &summary.FailedSpend, "should record spend of failed run")
+	assert.Equal(t, 0, summary.Ether.Sign())
+
+	run = schedule.Execute(now.Add(time.Hour), exchangeRate, fakeSwap(100))
+	assert.Equal(t, statusCompleted, run.Status)
+
+	run = schedule.Execute(now.Add(2*time.Hour), exchangeRate, fakeSwap(100))
+	assert.Equal(t, statusSkipped, run.Status, "should count failed spend against budget")
+}
+
+func TestScheduleDeclinedBindingOffer(t *testing.T) {
+	exchangeRate := &MockExchangeRate{}
+	now := time.Now()
+	order := alice.Order{Siacoin: types.NewCurrency64(100)}
+
+	schedule, err := NewSchedule(order, time.Hour, big.NewInt(250), 0, 500.0, "", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the non-binding offer fits the budget, but the binding offer does not
+	// and is declined after the anti-spam fee was paid
+	decliningSwap := func(order alice.Order, f frontend.Frontend) (*alice.Result, error) {
+		offer := trader.Offer{Available: true, Ether: *big.NewInt(300)}
+		approved, err := f.ApproveOffer(order.Siacoin, offer, true)
+		if err != nil {
+			return nil, err
+		}
+		assert.False(t, approved, "should decline binding offer exceeding remaining budget")
+
+		result := alice.Result{Server: "server", Siacoin: order.Siacoin, AntiSpamFee: *big.NewInt(200),
+			AntiSpamID: *big.NewInt(42)}
+		return &result, alice.ErrOfferDeclined
+	}
+	run := schedule.Execute(now, exchangeRate, decliningSwap)
+	assert.Equal(t, statusFailed, run.Status, "should record declined binding offer as failed")
+	assert.Equal(t, big.NewInt(200), &run.AntiSpamFee, "should record anti-spam fee of declined run")
+
+	summary := schedule.Summarize()
+	assert.Equal(t, big.NewInt(200), &summary.FailedSpend, "should count anti-spam fee of declined run")
+
+	run = schedule.Execute(now.Add(time.Hour), exchangeRate, fakeSwap(100))
+	assert.Equal(t, statusSkipped, run.Status, "should count anti-spam fee against budget")
+}
+
+func TestPersistence(t *testing.T) {
+	dir, err := ioutil.TempDir("", "roadie")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "schedule.json")
+
+	now := time.Now()
+	budget := big.NewInt(1e17)
+	order := alice.Order{EtherBudget: budget}
+	schedule, err := NewSchedule(order, 24*time.Hour, nil, 0, 0, "abs-diff-usd=1000", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	schedule.Execute(now, &MockExchangeRate{}, fakeSwap(100))
+
+	err = schedule.Save(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, budget, loaded.Order.EtherBudget)
+	assert.True(t, schedule.NextRun.Equal(loaded.NextRun))
+	assert.Equal(t, 1, len(loaded.Runs))
+	assert.Equal(t, statusCompleted, loaded.Runs[0].Status)
+}
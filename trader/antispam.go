@@ -0,0 +1,110 @@
+package trader
+
+import (
+	"math/big"
+	"sync"
+	"time"
+
+	"gitlab.com/NebulousLabs/Sia/types"
+)
+
+type (
+	// AntiSpamConfig configures an AntiSpamPolicy. The fee starts at Base
+	// plus PerSiacoin for every siacoin requested. It is then raised by
+	// LoadFactor for every swap in progress (0.5 is +50 % per swap) and by
+	// FailureFactor times the share of recent binding offers that failed.
+	// Factors may be nil. If Max is not nil, the fee never exceeds it.
+	AntiSpamConfig struct {
+		Base          big.Int
+		PerSiacoin    big.Int
+		LoadFactor    *big.Rat
+		FailureFactor *big.Rat
+		Max           *big.Int
+	}
+
+	// AntiSpamPolicy determines anti-spam fees, so that a server under
+	// attack raises the cost of spam while small honest buyers pay little.
+	// The server keeps it informed about its load via SetLoad.
+	AntiSpamPolicy struct {
+		mutex       sync.Mutex
+		config      AntiSpamConfig
+		inProgress  int
+		failureRate *big.Rat
+	}
+
+	antiSpamTrader struct {
+		Trader
+		policy *AntiSpamPolicy
+	}
+)
+
+func NewAntiSpamPolicy(config AntiSpamConfig) *AntiSpamPolicy {
+	return &AntiSpamPolicy{config: config, failureRate: new(big.Rat)}
+}
+
+// SetLoad updates the number of swaps in progress and the number of recent
+// binding offers that were resolved, some of which failed.
+func (p *AntiSpamPolicy) SetLoad(inProgress int, resolved int, failed int) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.inProgress = inProgress
+	if resolved > 0 {
+		p.failureRate = big.NewRat(int64(failed), int64(resolved))
+	} else {
+		p.failureRate = new(big.Rat)
+	}
+}
+
+func (p *AntiSpamPolicy) Fee(siacoin types.Currency) big.Int {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	perSiacoin := new(big.Int).Mul(&p.config.PerSiacoin, siacoin.Big())
+	perSiacoin.Quo(perSiacoin, types.SiacoinPrecision.Big())
+	fee := new(big.Rat).SetInt(new(big.Int).Add(&p.config.Base, perSiacoin))
+
+	multiplier := big.NewRat(1, 1)
+	if p.config.LoadFactor != nil {
+		load := new(big.Rat).Mul(p.config.LoadFactor, big.NewRat(int64(p.inProgress), 1))
+		multiplier.Add(multiplier, load)
+	}
+	if p.config.FailureFactor != nil {
+		multiplier.Add(multiplier, new(big.Rat).Mul(p.config.FailureFactor, p.failureRate))
+	}
+	fee.Mul(fee, multiplier)
+
+	result := new(big.Int).Quo(fee.Num(), fee.Denom())
+	if p.config.Max != nil && result.Cmp(p.config.Max) == 1 {
+		result.Set(p.config.Max)
+	}
+	return *result
+}
+
+// Wrap returns a trader that prices offers like t, but with anti-spam fees
+// determined by the policy.
+func (p *AntiSpamPolicy) Wrap(t Trader) Trader {
+	return &antiSpamTrader{Trader: t, policy: p}
+}
+
+func (t *antiSpamTrader) PrepareNonBindingOffer(siacoin types.Currency, minerFee types.Currency,
+	now time.Time) (*Offer, error) {
+	offer, err := t.Trader.PrepareNonBindingOffer(siacoin, minerFee, now)
+	if err != nil {
+		return nil, err
+	}
+
+	offer.AntiSpamFee = t.policy.Fee(siacoin)
+	return offer, nil
+}
+
+func (t *antiSpamTrader) PrepareBindingOffer(siacoin types.Currency, minerFee types.Currency,
+	now time.Time) (*Offer, *time.Time, error) {
+	offer, deadline, err := t.Trader.PrepareBindingOffer(siacoin, minerFee, now)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	offer.AntiSpamFee = t.policy.Fee(siacoin)
+	return offer, deadline, nil
+}
@@ -0,0 +1,51 @@
+package trader
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gitlab.com/NebulousLabs/Sia/types"
+)
+
+type (
+	fixedOfferTrader struct {
+		Trader
+	}
+)
+
+func (t fixedOfferTrader) PrepareNonBindingOffer(siacoin types.Currency, minerFee types.Currency,
+	now time.Time) (*Offer, error) {
+	return &Offer{Available: true, AntiSpamFee: *antiSpamFee}, nil
+}
+
+func TestAntiSpamPolicy(t *testing.T) {
+	policy := NewAntiSpamPolicy(AntiSpamConfig{
+		Base:          *big.NewInt(1e14),
+		PerSiacoin:    *big.NewInt(1e9),
+		LoadFactor:    big.NewRat(1, 2),
+		FailureFactor: big.NewRat(4, 1),
+		Max:           big.NewInt(1e15),
+	})
+	siacoin := types.SiacoinPrecision.Mul64(1000)
+
+	// 1e14 + 1000 * 1e9
+	fee := policy.Fee(siacoin)
+	assert.Equal(t, big.NewInt(101e12), &fee)
+
+	policy.SetLoad(2, 4, 1)
+	fee = policy.Fee(siacoin)
+	assert.Equal(t, big.NewInt(101e12*3), &fee, "expected +100 % for load and +100 % for failures")
+
+	policy.SetLoad(100, 4, 4)
+	fee = policy.Fee(siacoin)
+	assert.Equal(t, big.NewInt(1e15), &fee, "expected fee to be capped")
+
+	policy.SetLoad(0, 0, 0)
+	offer, err := policy.Wrap(fixedOfferTrader{}).PrepareNonBindingOffer(siacoin, minerFee, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, big.NewInt(101e12), &offer.AntiSpamFee)
+}
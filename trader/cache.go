@@ -0,0 +1,78 @@
+package trader
+
+import (
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/patrickmn/go-cache"
+	"gitlab.com/NebulousLabs/Sia/types"
+)
+
+type (
+	// CachingTrader remembers non-binding offers for a short while, so that
+	// repeated requests for the same amount do not query the Sia wallet and
+	// the exchange rates every time. Only available offers are cached,
+	// binding offers are always prepared afresh and pausing or resuming
+	// clears the cache.
+	CachingTrader struct {
+		Trader
+		cache *cache.Cache
+	}
+)
+
+func NewCachingTrader(t Trader, ttl time.Duration) *CachingTrader {
+	return &CachingTrader{Trader: t, cache: cache.New(ttl, 2*ttl)}
+}
+
+func (t *CachingTrader) PrepareNonBindingOffer(siacoin types.Currency, minerFee types.Currency,
+	now time.Time) (*Offer, error) {
+	key := fmt.Sprintf("offer/%s/%s", siacoin, minerFee)
+	if entry, ok := t.cache.Get(key); ok {
+		return entry.(*Offer).clone(), nil
+	}
+
+	offer, err := t.Trader.PrepareNonBindingOffer(siacoin, minerFee, now)
+	if err != nil {
+		return nil, err
+	}
+
+	if offer.Available {
+		t.cache.Set(key, offer.clone(), cache.DefaultExpiration)
+	}
+	return offer, nil
+}
+
+func (t *CachingTrader) CalculateSiacoin(ether big.Int, minerFee types.Currency,
+	now time.Time) (*types.Currency, error) {
+	key := fmt.Sprintf("siacoin/%s/%s", &ether, minerFee)
+	if entry, ok := t.cache.Get(key); ok {
+		siacoin := entry.(types.Currency)
+		return &siacoin, nil
+	}
+
+	siacoin, err := t.Trader.CalculateSiacoin(ether, minerFee, now)
+	if err != nil {
+		return nil, err
+	}
+
+	t.cache.Set(key, *siacoin, cache.DefaultExpiration)
+	return siacoin, nil
+}
+
+func (t *CachingTrader) PauseOrderPreparation(now time.Time) {
+	t.cache.Flush()
+	t.Trader.PauseOrderPreparation(now)
+}
+
+func (t *CachingTrader) ResumeOrderPreparation() {
+	t.cache.Flush()
+	t.Trader.ResumeOrderPreparation()
+}
+
+func (o *Offer) clone() *Offer {
+	clone := *o
+	clone.Ether = *new(big.Int).Set(&o.Ether) // do not share the underlying words
+	clone.AntiSpamFee = *new(big.Int).Set(&o.AntiSpamFee)
+	return &clone
+}
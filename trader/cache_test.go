@@ -0,0 +1,83 @@
+package trader
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gitlab.com/NebulousLabs/Sia/types"
+)
+
+type (
+	countingTrader struct {
+		Trader
+		offers int
+		paused bool
+	}
+)
+
+func (t *countingTrader) PrepareNonBindingOffer(siacoin types.Currency, minerFee types.Currency,
+	now time.Time) (*Offer, error) {
+	t.offers++
+	if t.paused {
+		return &Offer{Msg: msgPaused}, nil
+	}
+	return &Offer{Available: true, Ether: *big.NewInt(1e15), AntiSpamFee: *big.NewInt(1e14)}, nil
+}
+
+func (t *countingTrader) PauseOrderPreparation(now time.Time) {
+	t.paused = true
+}
+
+func (t *countingTrader) ResumeOrderPreparation() {
+	t.paused = false
+}
+
+func TestCachingTrader(t *testing.T) {
+	inner := &countingTrader{}
+	trader := NewCachingTrader(inner, time.Minute)
+	now := time.Now()
+
+	offer, err := trader.PrepareNonBindingOffer(types.SiacoinPrecision, minerFee, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	offer.Ether.SetInt64(0)
+	offer.AntiSpamFee = *big.NewInt(1)
+
+	offer, err = trader.PrepareNonBindingOffer(types.SiacoinPrecision, minerFee, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 1, inner.offers, "should use cached offer")
+	assert.Equal(t, big.NewInt(1e15), &offer.Ether, "should not be affected by changes to earlier offer")
+	assert.Equal(t, big.NewInt(1e14), &offer.AntiSpamFee)
+
+	_, err = trader.PrepareNonBindingOffer(types.SiacoinPrecision.Mul64(2), minerFee, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 2, inner.offers, "should not use cached offer for other amount")
+
+	trader.PauseOrderPreparation(now)
+	offer, err = trader.PrepareNonBindingOffer(types.SiacoinPrecision, minerFee, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.False(t, offer.Available, "should not use cached offer while paused")
+
+	_, err = trader.PrepareNonBindingOffer(types.SiacoinPrecision, minerFee, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 4, inner.offers, "should not cache unavailable offer")
+
+	trader.ResumeOrderPreparation()
+	offer, err = trader.PrepareNonBindingOffer(types.SiacoinPrecision, minerFee, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, offer.Available, "should not use cached offer after resuming")
+	assert.Equal(t, 5, inner.offers)
+}
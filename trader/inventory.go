@@ -0,0 +1,338 @@
+package trader
+
+import (
+	"fmt"
+	"log"
+	"math/big"
+	"sync"
+	"time"
+
+	"gitlab.com/NebulousLabs/Sia/types"
+
+	"github.com/javgh/roadie/blockchain/ethereum"
+	"github.com/javgh/roadie/blockchain/sia"
+)
+
+type (
+	// InventoryConfig configures an InventoryTrader. Premiums are fractions
+	// of the market value (0.01 is 1 %) and may be nil. The inventory premium
+	// is charged in full for a swap that would use all available siacoins and
+	// proportionally less for smaller swaps. The volume premium is charged in
+	// full once the siacoins sold within the last 24 hours reach the daily
+	// cap and is ignored if there is no cap. Floor and ceiling are USD prices
+	// of one siacoin and may be nil. A zero MaxSwap or DailyCap means no
+	// limit.
+	InventoryConfig struct {
+		BasePremium      *big.Rat
+		InventoryPremium *big.Rat
+		VolumePremium    *big.Rat
+		FloorUSD         *big.Rat
+		CeilingUSD       *big.Rat
+		MaxSwap          types.Currency
+		DailyCap         types.Currency
+		AntiSpamFee      big.Int
+	}
+
+	// InventoryTrader prices siacoins with a premium that grows with the
+	// share of the inventory a swap would use and with recent volume, so
+	// that the inventory is protected without manual intervention.
+	InventoryTrader struct {
+		config        InventoryConfig
+		rates         RateFetcher
+		volume        VolumeTracker
+		mutex         *sync.Mutex // guards paused and pauseDeadline
+		paused        bool
+		pauseDeadline *time.Time
+		ethChain      ethereum.Blockchain
+		siaChain      sia.Blockchain
+	}
+
+	RateFetcher interface {
+		Fetch(id string) (*big.Rat, error)
+	}
+
+	// VolumeTracker reports the siacoins sold since a given time. Only
+	// completed swaps are expected to be counted, so swaps in progress can
+	// exceed the daily cap slightly. It is asked on every offer, so it should
+	// not be slow. If it fails, offers are made as if nothing had been sold.
+	VolumeTracker interface {
+		Volume(since time.Time) (types.Currency, error)
+	}
+
+	// inventory describes the state relevant for pricing at one point in
+	// time.
+	inventory struct {
+		balance    types.Currency
+		volume     types.Currency
+		usdEther   *big.Rat
+		usdSiacoin *big.Rat
+		gasPrice   *big.Int
+	}
+)
+
+const (
+	msgSwapTooLarge = "The maximum amount per swap is %s."
+	msgDailyCap     = "The daily volume limit has been reached. At the moment, at most %s can be offered."
+
+	volumeWindow = 24 * time.Hour
+)
+
+func NewInventoryTrader(config InventoryConfig, rates RateFetcher, volume VolumeTracker,
+	ethChain ethereum.Blockchain, siaChain sia.Blockchain) InventoryTrader {
+	return InventoryTrader{
+		config:   config,
+		rates:    rates,
+		volume:   volume,
+		mutex:    &sync.Mutex{},
+		paused:   false,
+		ethChain: ethChain,
+		siaChain: siaChain,
+	}
+}
+
+func (t *InventoryTrader) PrepareNonBindingOffer(siacoin types.Currency, minerFee types.Currency,
+	now time.Time) (*Offer, error) {
+	offer, _, err := t.prepareOffer(siacoin, minerFee, now)
+	return offer, err
+}
+
+func (t *InventoryTrader) PrepareBindingOffer(siacoin types.Currency, minerFee types.Currency,
+	now time.Time) (*Offer, *time.Time, error) {
+	return t.prepareOffer(siacoin, minerFee, now)
+}
+
+// CalculateSiacoin determines the largest amount of siacoins that can be
+// bought for the given amount of ether. As the price depends on the amount,
+// this is done by a binary search over the possible amounts.
+func (t *InventoryTrader) CalculateSiacoin(ether big.Int, minerFee types.Currency,
+	now time.Time) (*types.Currency, error) {
+	inv, err := t.fetchInventory(now)
+	if err != nil {
+		return nil, err
+	}
+
+	limit := t.available(inv)
+	if limit.IsZero() {
+		return &types.ZeroCurrency, nil
+	}
+
+	if t.cost(limit, minerFee, inv).Cmp(&ether) != 1 {
+		return &limit, nil
+	}
+
+	low, high := big.NewInt(0), limit.Big()
+	one := big.NewInt(1)
+	for low.Cmp(high) == -1 {
+		mid := new(big.Int).Add(low, high)
+		mid.Add(mid, one)
+		mid.Rsh(mid, 1)
+
+		if t.cost(types.NewCurrency(mid), minerFee, inv).Cmp(&ether) != 1 {
+			low = mid
+		} else {
+			high = mid.Sub(mid, one)
+		}
+	}
+
+	siacoin := types.NewCurrency(low)
+	if t.cost(siacoin, minerFee, inv).Cmp(&ether) == 1 {
+		return &types.ZeroCurrency, nil
+	}
+	return &siacoin, nil
+}
+
+func (t *InventoryTrader) PauseOrderPreparation(now time.Time) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	deadline := now.Add(bindingOfferLifetime)
+	t.paused = true
+	t.pauseDeadline = &deadline
+}
+
+func (t *InventoryTrader) ResumeOrderPreparation() {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.paused = false
+}
+
+func (t *InventoryTrader) checkPaused(now time.Time) bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.pauseDeadline != nil && now.After(*t.pauseDeadline) {
+		t.paused = false
+		t.pauseDeadline = nil
+	}
+	return t.paused
+}
+
+func (t *InventoryTrader) prepareOffer(siacoin types.Currency, minerFee types.Currency,
+	now time.Time) (*Offer, *time.Time, error) {
+	offer := Offer{
+		Msg:         "",
+		Available:   false,
+		Ether:       *big.NewInt(0),
+		AntiSpamFee: t.config.AntiSpamFee,
+	}
+	deadline := now.Add(bindingOfferLifetime)
+
+	if t.checkPaused(now) {
+		offer.Msg = msgPaused
+		return &offer, &deadline, nil
+	}
+
+	if siacoin.Cmp(minSiacoin) == -1 {
+		offer.Msg = fmt.Sprintf(msgTooSmall, minSiacoin.HumanString())
+		return &offer, &deadline, nil
+	}
+
+	if !t.config.MaxSwap.IsZero() && siacoin.Cmp(t.config.MaxSwap) == 1 {
+		offer.Msg = fmt.Sprintf(msgSwapTooLarge, t.config.MaxSwap.HumanString())
+		return &offer, &deadline, nil
+	}
+
+	inv, err := t.fetchInventory(now)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !t.config.DailyCap.IsZero() && inv.volume.Add(siacoin).Cmp(t.config.DailyCap) == 1 {
+		remaining := types.ZeroCurrency
+		if inv.volume.Cmp(t.config.DailyCap) == -1 {
+			remaining = t.config.DailyCap.Sub(inv.volume)
+		}
+		offer.Msg = fmt.Sprintf(msgDailyCap, remaining.HumanString())
+		return &offer, &deadline, nil
+	}
+
+	if siacoin.Cmp(inv.balance) != -1 {
+		offer.Msg = msgTooLarge
+		return &offer, &deadline, nil
+	}
+
+	contractCost := new(big.Int).Mul(big.NewInt(gasEstimate), inv.gasPrice)
+	contractCostUSD := ethereum.ApplyRate(contractCost, inv.usdEther)
+
+	offer.Msg = fmt.Sprintf(msgOffer, ethereum.FormatEther(contractCost),
+		FormatUSD(contractCostUSD), ethereum.FormatGwei(inv.gasPrice))
+	offer.Available = true
+	offer.Ether = *t.cost(siacoin, minerFee, inv)
+
+	return &offer, &deadline, nil
+}
+
+// price returns the USD price of one siacoin for a swap of the given size.
+func (t *InventoryTrader) price(siacoin types.Currency, inv inventory) *big.Rat {
+	premium := new(big.Rat)
+	if t.config.BasePremium != nil {
+		premium.Add(premium, t.config.BasePremium)
+	}
+
+	if t.config.InventoryPremium != nil && !inv.balance.IsZero() {
+		share := new(big.Rat).SetFrac(siacoin.Big(), inv.balance.Big())
+		if share.Cmp(big.NewRat(1, 1)) == 1 {
+			share.SetInt64(1)
+		}
+		premium.Add(premium, share.Mul(share, t.config.InventoryPremium))
+	}
+
+	if t.config.VolumePremium != nil && !t.config.DailyCap.IsZero() {
+		share := new(big.Rat).SetFrac(inv.volume.Big(), t.config.DailyCap.Big())
+		if share.Cmp(big.NewRat(1, 1)) == 1 {
+			share.SetInt64(1)
+		}
+		premium.Add(premium, share.Mul(share, t.config.VolumePremium))
+	}
+
+	price := new(big.Rat).Add(big.NewRat(1, 1), premium)
+	price.Mul(price, inv.usdSiacoin)
+
+	if t.config.CeilingUSD != nil && price.Cmp(t.config.CeilingUSD) == 1 {
+		price.Set(t.config.CeilingUSD)
+	}
+	if t.config.FloorUSD != nil && price.Cmp(t.config.FloorUSD) == -1 {
+		price.Set(t.config.FloorUSD)
+	}
+
+	return price
+}
+
+// cost returns the amount of ether to charge for the given amount of
+// siacoins, including miner fees and Ethereum transaction fees.
+func (t *InventoryTrader) cost(siacoin types.Currency, minerFee types.Currency, inv inventory) *big.Int {
+	siacoinAndFees := siacoin.Add(minerFee).Add(minerFee)
+	usd := sia.ApplyRate(siacoinAndFees, t.price(siacoin, inv))
+	etherRat := new(big.Rat).Mul(new(big.Rat).Quo(usd, inv.usdEther), oneEther)
+	ether := new(big.Int).Quo(etherRat.Num(), etherRat.Denom())
+
+	contractCost := new(big.Int).Mul(big.NewInt(gasEstimate), inv.gasPrice)
+	return ether.Add(ether, contractCost)
+}
+
+// available returns the largest amount of siacoins that can currently be
+// offered.
+func (t *InventoryTrader) available(inv inventory) types.Currency {
+	if inv.balance.IsZero() {
+		return types.ZeroCurrency
+	}
+	limit := inv.balance.Sub(types.NewCurrency64(1))
+
+	if !t.config.MaxSwap.IsZero() && t.config.MaxSwap.Cmp(limit) == -1 {
+		limit = t.config.MaxSwap
+	}
+
+	if !t.config.DailyCap.IsZero() {
+		if inv.volume.Cmp(t.config.DailyCap) != -1 {
+			return types.ZeroCurrency
+		}
+
+		remaining := t.config.DailyCap.Sub(inv.volume)
+		if remaining.Cmp(limit) == -1 {
+			limit = remaining
+		}
+	}
+
+	return limit
+}
+
+func (t *InventoryTrader) fetchInventory(now time.Time) (inventory, error) {
+	var inv inventory
+
+	usableOutputs, err := t.siaChain.FetchUsableOutputs()
+	if err != nil {
+		return inv, err
+	}
+	inv.balance = types.ZeroCurrency
+	for _, usableOutput := range usableOutputs {
+		inv.balance = inv.balance.Add(usableOutput.UnspentOutput.Value)
+	}
+
+	inv.volume = types.ZeroCurrency
+	if t.volume != nil {
+		volume, err := t.volume.Volume(now.Add(-volumeWindow))
+		if err != nil {
+			log.Printf("Unable to determine recent volume: %s\n", err)
+		} else {
+			inv.volume = volume
+		}
+	}
+
+	inv.usdEther, err = t.rates.Fetch("ethereum")
+	if err != nil {
+		return inv, err
+	}
+
+	inv.usdSiacoin, err = t.rates.Fetch("siacoin")
+	if err != nil {
+		return inv, err
+	}
+
+	inv.gasPrice, err = t.ethChain.SuggestGasPrice()
+	if err != nil {
+		return inv, err
+	}
+
+	return inv, nil
+}
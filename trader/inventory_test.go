@@ -0,0 +1,171 @@
+package trader
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gitlab.com/NebulousLabs/Sia/modules"
+	"gitlab.com/NebulousLabs/Sia/types"
+
+	"github.com/javgh/roadie/blockchain/ethereum"
+	"github.com/javgh/roadie/blockchain/sia"
+)
+
+type (
+	fakeRates map[string]*big.Rat
+
+	fakeVolume types.Currency
+
+	failingVolume struct{}
+
+	fakeEthChain struct {
+		ethereum.Blockchain
+	}
+
+	fakeSiaChain struct {
+		sia.Blockchain
+		balance types.Currency
+	}
+)
+
+func (r fakeRates) Fetch(id string) (*big.Rat, error) {
+	rate, ok := r[id]
+	if !ok {
+		return nil, ErrExchangeRateNotFound
+	}
+	return rate, nil
+}
+
+func (v fakeVolume) Volume(since time.Time) (types.Currency, error) {
+	return types.Currency(v), nil
+}
+
+func (v failingVolume) Volume(since time.Time) (types.Currency, error) {
+	return types.ZeroCurrency, errors.New("unreadable ledger")
+}
+
+func (c fakeEthChain) SuggestGasPrice() (*big.Int, error) {
+	return big.NewInt(1e9), nil
+}
+
+func (c fakeSiaChain) FetchUsableOutputs() ([]sia.UsableOutput, error) {
+	return []sia.UsableOutput{{UnspentOutput: modules.UnspentOutput{Value: c.balance}}}, nil
+}
+
+func TestInventoryTrader(t *testing.T) {
+	rates := fakeRates{"ethereum": big.NewRat(200, 1), "siacoin": big.NewRat(1, 1000)}
+	siaChain := fakeSiaChain{balance: types.SiacoinPrecision.Mul64(100000)}
+	config := InventoryConfig{
+		BasePremium:      big.NewRat(1, 100),
+		InventoryPremium: big.NewRat(10, 100),
+		VolumePremium:    big.NewRat(5, 100),
+		MaxSwap:          types.SiacoinPrecision.Mul64(50000),
+		DailyCap:         types.SiacoinPrecision.Mul64(80000),
+		AntiSpamFee:      *antiSpamFee,
+	}
+	now := time.Now()
+	contractCost := big.NewInt(gasEstimate * 1e9)
+
+	etherPerSiacoin := func(trader InventoryTrader, siacoin types.Currency) *big.Rat {
+		offer, err := trader.PrepareNonBindingOffer(siacoin, types.ZeroCurrency, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.True(t, offer.Available, offer.Msg)
+		ether := new(big.Int).Sub(&offer.Ether, contractCost)
+		return new(big.Rat).SetFrac(ether, siacoin.Div(types.SiacoinPrecision).Big())
+	}
+
+	t.Run("Premiums", func(t *testing.T) {
+		trader := NewInventoryTrader(config, rates, fakeVolume(types.ZeroCurrency), fakeEthChain{}, siaChain)
+
+		// 1 % base premium plus 10 % of the share of the inventory, at
+		// 0.001 USD/SC and 200 USD/ETH
+		small := etherPerSiacoin(trader, types.SiacoinPrecision.Mul64(1000))
+		large := etherPerSiacoin(trader, types.SiacoinPrecision.Mul64(50000))
+		assert.Equal(t, big.NewRat(1011*5e9, 1), small)
+		assert.Equal(t, big.NewRat(1060*5e9, 1), large)
+
+		// another 5 % once half of the daily cap has been sold
+		busy := NewInventoryTrader(config, rates,
+			fakeVolume(types.SiacoinPrecision.Mul64(40000)), fakeEthChain{}, siaChain)
+		assert.Equal(t, big.NewRat(1036*5e9, 1),
+			etherPerSiacoin(busy, types.SiacoinPrecision.Mul64(1000)))
+	})
+
+	t.Run("FloorAndCeiling", func(t *testing.T) {
+		floored := config
+		floored.FloorUSD = big.NewRat(2, 1000)
+		trader := NewInventoryTrader(floored, rates, nil, fakeEthChain{}, siaChain)
+		assert.Equal(t, big.NewRat(1e13, 1),
+			etherPerSiacoin(trader, types.SiacoinPrecision.Mul64(1000)))
+
+		capped := config
+		capped.CeilingUSD = big.NewRat(1, 1000)
+		trader = NewInventoryTrader(capped, rates, nil, fakeEthChain{}, siaChain)
+		assert.Equal(t, big.NewRat(5e12, 1),
+			etherPerSiacoin(trader, types.SiacoinPrecision.Mul64(1000)))
+	})
+
+	t.Run("Limits", func(t *testing.T) {
+		trader := NewInventoryTrader(config, rates,
+			fakeVolume(types.SiacoinPrecision.Mul64(70000)), fakeEthChain{}, siaChain)
+
+		offer, err := trader.PrepareNonBindingOffer(types.SiacoinPrecision.Mul64(60000), minerFee, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.False(t, offer.Available, "expected no offer above maximum per swap")
+
+		offer, err = trader.PrepareNonBindingOffer(types.SiacoinPrecision.Mul64(20000), minerFee, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.False(t, offer.Available, "expected no offer above daily cap")
+
+		offer, err = trader.PrepareNonBindingOffer(types.SiacoinPrecision.Mul64(10000), minerFee, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.True(t, offer.Available, "expected offer up to daily cap")
+
+		unknown := NewInventoryTrader(config, rates, failingVolume{}, fakeEthChain{}, siaChain)
+		offer, err = unknown.PrepareNonBindingOffer(types.SiacoinPrecision.Mul64(10000), minerFee, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.True(t, offer.Available, "expected offer despite unknown volume")
+	})
+
+	t.Run("CalculateSiacoin", func(t *testing.T) {
+		trader := NewInventoryTrader(config, rates, nil, fakeEthChain{}, siaChain)
+		ether := *big.NewInt(1e17)
+
+		siacoin, err := trader.CalculateSiacoin(ether, minerFee, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.True(t, siacoin.Cmp(minSiacoin) == 1)
+
+		offer, err := trader.PrepareNonBindingOffer(*siacoin, minerFee, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.True(t, offer.Ether.Cmp(&ether) != 1, "expected offer within budget")
+
+		offer, err = trader.PrepareNonBindingOffer(siacoin.Add(types.NewCurrency64(1)), minerFee, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.True(t, offer.Ether.Cmp(&ether) == 1, "expected largest amount within budget")
+
+		siacoin, err = trader.CalculateSiacoin(*big.NewInt(1e18), minerFee, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, config.MaxSwap, *siacoin)
+	})
+}
@@ -0,0 +1,293 @@
+package trader
+
+import (
+	"errors"
+	"io"
+	"log"
+	"math/big"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	"gitlab.com/NebulousLabs/Sia/types"
+)
+
+type (
+	// PluginTrader delegates pricing to an external process, so that a
+	// strategy can be written in any language and changed without rebuilding
+	// roadie. The process is started with the given command and speaks
+	// JSON-RPC 1.0 on stdin/stdout, one request per line (see PluginRequest
+	// and PluginOffer for the parameters and results). Requests are made to
+	// the methods Plugin.PrepareNonBindingOffer, Plugin.PrepareBindingOffer,
+	// Plugin.CalculateSiacoin, Plugin.PauseOrderPreparation and
+	// Plugin.ResumeOrderPreparation. If the process exits, it is started again
+	// on the next request. A process that does not answer within
+	// pluginCallTimeout is killed and started again.
+	//
+	// Pausing is also enforced locally: while paused, no offer is requested
+	// from the plugin.
+	PluginTrader struct {
+		name          string
+		args          []string
+		mutex         sync.Mutex
+		cmd           *exec.Cmd
+		client        *rpc.Client
+		timeout       time.Duration
+		paused        bool
+		pauseDeadline *time.Time
+	}
+
+	// PluginRequest holds the parameters of all plugin methods. Amounts are
+	// decimal strings in hastings and wei, respectively.
+	PluginRequest struct {
+		Siacoin  string    `json:"siacoin,omitempty"`
+		Ether    string    `json:"ether,omitempty"`
+		MinerFee string    `json:"miner_fee,omitempty"`
+		Now      time.Time `json:"now"`
+	}
+
+	// PluginOffer is the result of Plugin.PrepareNonBindingOffer and
+	// Plugin.PrepareBindingOffer. If the deadline of a binding offer is
+	// omitted, the offer is valid for one minute.
+	PluginOffer struct {
+		Msg         string     `json:"msg"`
+		Available   bool       `json:"available"`
+		Ether       string     `json:"ether"`
+		AntiSpamFee string     `json:"anti_spam_fee"`
+		Deadline    *time.Time `json:"deadline,omitempty"`
+	}
+
+	// PluginSiacoin is the result of Plugin.CalculateSiacoin.
+	PluginSiacoin struct {
+		Siacoin string `json:"siacoin"`
+	}
+
+	pluginConn struct {
+		io.ReadCloser
+		io.WriteCloser
+	}
+)
+
+const (
+	pluginCallTimeout = 10 * time.Second
+)
+
+var (
+	ErrInvalidPluginReply = errors.New("unable to parse reply from pricing plugin")
+	ErrPluginTimeout      = errors.New("pricing plugin did not reply in time")
+)
+
+func NewPluginTrader(name string, args ...string) (*PluginTrader, error) {
+	t := PluginTrader{name: name, args: args, timeout: pluginCallTimeout}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	_, err := t.connect()
+	if err != nil {
+		return nil, err
+	}
+
+	return &t, nil
+}
+
+func (t *PluginTrader) PrepareNonBindingOffer(siacoin types.Currency, minerFee types.Currency,
+	now time.Time) (*Offer, error) {
+	offer, _, err := t.prepareOffer("Plugin.PrepareNonBindingOffer", siacoin, minerFee, now)
+	return offer, err
+}
+
+func (t *PluginTrader) PrepareBindingOffer(siacoin types.Currency, minerFee types.Currency,
+	now time.Time) (*Offer, *time.Time, error) {
+	return t.prepareOffer("Plugin.PrepareBindingOffer", siacoin, minerFee, now)
+}
+
+func (t *PluginTrader) CalculateSiacoin(ether big.Int, minerFee types.Currency,
+	now time.Time) (*types.Currency, error) {
+	req := PluginRequest{Ether: ether.String(), MinerFee: minerFee.String(), Now: now}
+	var reply PluginSiacoin
+	err := t.call("Plugin.CalculateSiacoin", req, &reply)
+	if err != nil {
+		return nil, err
+	}
+
+	siacoin, ok := new(big.Int).SetString(reply.Siacoin, 10)
+	if !ok || siacoin.Sign() == -1 {
+		return nil, ErrInvalidPluginReply
+	}
+
+	currency := types.NewCurrency(siacoin)
+	return &currency, nil
+}
+
+func (t *PluginTrader) PauseOrderPreparation(now time.Time) {
+	t.mutex.Lock()
+	deadline := now.Add(bindingOfferLifetime)
+	t.paused = true
+	t.pauseDeadline = &deadline
+	t.mutex.Unlock()
+
+	var reply struct{}
+	err := t.call("Plugin.PauseOrderPreparation", PluginRequest{Now: now}, &reply)
+	if err != nil {
+		log.Printf("Unable to notify pricing plugin about pause: %s\n", err)
+	}
+}
+
+func (t *PluginTrader) ResumeOrderPreparation() {
+	t.mutex.Lock()
+	t.paused = false
+	t.mutex.Unlock()
+
+	var reply struct{}
+	err := t.call("Plugin.ResumeOrderPreparation", PluginRequest{Now: time.Now()}, &reply)
+	if err != nil {
+		log.Printf("Unable to notify pricing plugin about resume: %s\n", err)
+	}
+}
+
+// Close stops the plugin process.
+func (t *PluginTrader) Close() error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.client == nil {
+		return nil
+	}
+
+	return t.stop()
+}
+
+func (t *PluginTrader) prepareOffer(method string, siacoin types.Currency, minerFee types.Currency,
+	now time.Time) (*Offer, *time.Time, error) {
+	deadline := now.Add(bindingOfferLifetime)
+
+	if t.checkPaused(now) {
+		offer := Offer{Msg: msgPaused, Ether: *big.NewInt(0)}
+		return &offer, &deadline, nil
+	}
+
+	req := PluginRequest{Siacoin: siacoin.String(), MinerFee: minerFee.String(), Now: now}
+	var reply PluginOffer
+	err := t.call(method, req, &reply)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	offer := Offer{Msg: reply.Msg, Available: reply.Available}
+	for _, amount := range []struct {
+		target *big.Int
+		value  string
+	}{{&offer.Ether, reply.Ether}, {&offer.AntiSpamFee, reply.AntiSpamFee}} {
+		if amount.value == "" {
+			amount.value = "0"
+		}
+		_, ok := amount.target.SetString(amount.value, 10)
+		if !ok || amount.target.Sign() == -1 {
+			return nil, nil, ErrInvalidPluginReply
+		}
+	}
+
+	if reply.Deadline != nil {
+		deadline = *reply.Deadline
+	}
+
+	return &offer, &deadline, nil
+}
+
+func (t *PluginTrader) checkPaused(now time.Time) bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.pauseDeadline != nil && now.After(*t.pauseDeadline) {
+		t.paused = false
+		t.pauseDeadline = nil
+	}
+	return t.paused
+}
+
+func (t *PluginTrader) call(method string, req PluginRequest, reply interface{}) error {
+	t.mutex.Lock()
+	client, err := t.connect()
+	t.mutex.Unlock()
+	if err != nil {
+		return err
+	}
+
+	var call *rpc.Call
+	select {
+	case call = <-client.Go(method, req, reply, nil).Done:
+	case <-time.After(t.timeout):
+		// The plugin hangs; kill it and start it again right away.
+		t.mutex.Lock()
+		defer t.mutex.Unlock()
+
+		if t.client == client {
+			t.cmd.Process.Kill()
+			t.stop()
+			_, err = t.connect()
+			if err != nil {
+				log.Printf("Unable to restart pricing plugin: %s\n", err)
+			}
+		}
+		return ErrPluginTimeout
+	}
+
+	err = call.Error
+	if err == rpc.ErrShutdown || err == io.ErrUnexpectedEOF {
+		// The plugin has exited; start it again for the next request.
+		t.mutex.Lock()
+		if t.client == client {
+			t.stop()
+		}
+		t.mutex.Unlock()
+	}
+	return err
+}
+
+// stop closes the connection to the plugin process and waits for it to
+// exit. The caller must hold the mutex.
+func (t *PluginTrader) stop() error {
+	err := t.client.Close()
+	t.cmd.Wait()
+	t.client = nil
+	t.cmd = nil
+	return err
+}
+
+// connect returns a client for the running plugin process, starting it if
+// necessary. The caller must hold the mutex.
+func (t *PluginTrader) connect() (*rpc.Client, error) {
+	if t.client != nil {
+		return t.client, nil
+	}
+
+	cmd := exec.Command(t.name, t.args...)
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	err = cmd.Start()
+	if err != nil {
+		return nil, err
+	}
+
+	t.cmd = cmd
+	t.client = jsonrpc.NewClient(pluginConn{ReadCloser: stdout, WriteCloser: stdin})
+	return t.client, nil
+}
+
+func (c pluginConn) Close() error {
+	err := c.WriteCloser.Close()
+	c.ReadCloser.Close()
+	return err
+}
@@ -0,0 +1,138 @@
+package trader
+
+import (
+	"math/big"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gitlab.com/NebulousLabs/Sia/types"
+)
+
+type (
+	testPlugin struct {
+		paused bool
+	}
+)
+
+// TestPluginHelperProcess is not a real test: it runs a simple pricing plugin
+// (1 wei per hasting) when started by TestPluginTrader.
+func TestPluginHelperProcess(t *testing.T) {
+	if os.Getenv("ROADIE_TEST_PLUGIN") != "1" {
+		return
+	}
+
+	server := rpc.NewServer()
+	server.RegisterName("Plugin", &testPlugin{})
+	server.ServeCodec(jsonrpc.NewServerCodec(pluginConn{ReadCloser: os.Stdin, WriteCloser: os.Stdout}))
+	os.Exit(0)
+}
+
+func (p *testPlugin) PrepareNonBindingOffer(req PluginRequest, reply *PluginOffer) error {
+	if p.paused {
+		reply.Msg = "paused"
+		return nil
+	}
+
+	reply.Available = true
+	reply.Ether = req.Siacoin
+	reply.AntiSpamFee = "100"
+	return nil
+}
+
+func (p *testPlugin) PrepareBindingOffer(req PluginRequest, reply *PluginOffer) error {
+	err := p.PrepareNonBindingOffer(req, reply)
+	deadline := req.Now.Add(time.Hour)
+	reply.Deadline = &deadline
+	return err
+}
+
+func (p *testPlugin) CalculateSiacoin(req PluginRequest, reply *PluginSiacoin) error {
+	if req.Ether == "0" {
+		os.Exit(1)
+	}
+	if req.Ether == "1" {
+		time.Sleep(time.Hour) // hang
+	}
+
+	reply.Siacoin = req.Ether
+	return nil
+}
+
+func (p *testPlugin) PauseOrderPreparation(req PluginRequest, reply *struct{}) error {
+	p.paused = true
+	return nil
+}
+
+func (p *testPlugin) ResumeOrderPreparation(req PluginRequest, reply *struct{}) error {
+	p.paused = false
+	return nil
+}
+
+func TestPluginTrader(t *testing.T) {
+	os.Setenv("ROADIE_TEST_PLUGIN", "1")
+	defer os.Unsetenv("ROADIE_TEST_PLUGIN")
+
+	trader, err := NewPluginTrader(os.Args[0], "-test.run=TestPluginHelperProcess")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer trader.Close()
+
+	now := time.Now()
+	siacoin := types.NewCurrency64(12345)
+
+	offer, err := trader.PrepareNonBindingOffer(siacoin, minerFee, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, offer.Available)
+	assert.Equal(t, big.NewInt(12345), &offer.Ether)
+	assert.Equal(t, big.NewInt(100), &offer.AntiSpamFee)
+
+	offer, deadline, err := trader.PrepareBindingOffer(siacoin, minerFee, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, offer.Available)
+	assert.Equal(t, now.Add(time.Hour).Unix(), deadline.Unix())
+
+	trader.PauseOrderPreparation(now)
+	offer, err = trader.PrepareNonBindingOffer(siacoin, minerFee, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.False(t, offer.Available, "expected no offer while paused")
+	assert.Equal(t, msgPaused, offer.Msg)
+
+	trader.ResumeOrderPreparation()
+	calculated, err := trader.CalculateSiacoin(*big.NewInt(500), minerFee, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, types.NewCurrency64(500), *calculated)
+
+	// The plugin exits on this request and should be restarted afterwards.
+	_, err = trader.CalculateSiacoin(*big.NewInt(0), minerFee, now)
+	assert.Error(t, err)
+
+	offer, err = trader.PrepareNonBindingOffer(siacoin, minerFee, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, offer.Available, "expected offer from restarted plugin")
+
+	// The plugin hangs on this request and should be killed and restarted.
+	trader.timeout = time.Second
+	_, err = trader.CalculateSiacoin(*big.NewInt(1), minerFee, now)
+	assert.Equal(t, ErrPluginTimeout, err)
+
+	offer, err = trader.PrepareNonBindingOffer(siacoin, minerFee, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, offer.Available, "expected offer from restarted plugin")
+}
@@ -0,0 +1,207 @@
+package trader
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"sync"
+	"time"
+
+	"gitlab.com/NebulousLabs/Sia/types"
+
+	"github.com/javgh/roadie/blockchain/ethereum"
+	"github.com/javgh/roadie/blockchain/sia"
+	"github.com/javgh/roadie/output"
+)
+
+type (
+	// Quote holds indicative prices in wei for buying a reference amount of
+	// siacoins: the market value derived from the USD exchange rates and the
+	// ask, based on what the server would currently offer including the
+	// anti-spam fee. If the server makes no offers at the moment, Available
+	// is false and Msg explains why. Premium and volatility are in basis
+	// points of the market value.
+	Quote struct {
+		Time          time.Time
+		Siacoin       types.Currency
+		Reference     big.Int
+		Available     bool
+		Msg           string
+		Ask           big.Int
+		PremiumBps    int64
+		VolatilityBps int64
+	}
+
+	// QuoteEngine derives the ask from the non-binding offer of a trader. As
+	// the price might move before a client asks for an actual offer, the ask
+	// is widened by a multiple of the volatility, measured as the standard
+	// deviation of the rates sampled within a time window.
+	QuoteEngine struct {
+		mutex            sync.Mutex
+		rates            RateFetcher
+		trader           Trader
+		siacoin          types.Currency
+		volatilityFactor float64
+		window           time.Duration
+		samples          []rateSample
+	}
+
+	rateSample struct {
+		time time.Time
+		rate *big.Rat
+	}
+)
+
+const (
+	bpsPerUnit        = 10000
+	minSampleInterval = time.Minute
+	maxSamples        = 1000
+)
+
+var (
+	quoteMinerFee = types.SiacoinPrecision // as used by the server
+)
+
+func NewQuoteEngine(rates RateFetcher, trader Trader, siacoin types.Currency, volatilityFactor float64,
+	window time.Duration) *QuoteEngine {
+	return &QuoteEngine{
+		rates:            rates,
+		trader:           trader,
+		siacoin:          siacoin,
+		volatilityFactor: volatilityFactor,
+		window:           window,
+	}
+}
+
+// Sample fetches the current reference rate and adds it to the samples used
+// to estimate volatility. Samples taken less than a minute apart are ignored.
+func (q *QuoteEngine) Sample(now time.Time) error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	_, err := q.sample(now)
+	return err
+}
+
+func (q *QuoteEngine) Quote(now time.Time) (*Quote, error) {
+	q.mutex.Lock()
+	rate, err := q.sample(now)
+	volatilityBps := q.volatilityBps()
+	q.mutex.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	quote := Quote{Time: now, Siacoin: q.siacoin, VolatilityBps: volatilityBps}
+	reference := new(big.Rat).Mul(rate, sia.ToSiacoin(q.siacoin))
+	quote.Reference.Quo(reference.Num(), reference.Denom())
+
+	offer, err := q.trader.PrepareNonBindingOffer(q.siacoin, quoteMinerFee, now)
+	if err != nil {
+		return nil, err
+	}
+	if !offer.Available {
+		quote.Msg = offer.Msg
+		return &quote, nil
+	}
+	quote.Available = true
+
+	widenBps := int64(math.Ceil(q.volatilityFactor * float64(volatilityBps)))
+	ask := new(big.Int).Add(&offer.Ether, &offer.AntiSpamFee)
+	ask.Mul(ask, big.NewInt(bpsPerUnit+widenBps))
+	ask.Add(ask, big.NewInt(bpsPerUnit-1))
+	quote.Ask.Quo(ask, big.NewInt(bpsPerUnit)) // round up
+
+	if quote.Reference.Sign() == 1 {
+		premium := new(big.Int).Sub(&quote.Ask, &quote.Reference)
+		premium.Mul(premium, big.NewInt(bpsPerUnit))
+		quote.PremiumBps = premium.Quo(premium, &quote.Reference).Int64()
+	}
+
+	return &quote, nil
+}
+
+// sample returns the current reference rate in wei per siacoin and records
+// it. The caller must hold the mutex.
+func (q *QuoteEngine) sample(now time.Time) (*big.Rat, error) {
+	usdEther, err := q.rates.Fetch("ethereum")
+	if err != nil {
+		return nil, err
+	}
+
+	usdSiacoin, err := q.rates.Fetch("siacoin")
+	if err != nil {
+		return nil, err
+	}
+
+	if usdEther.Sign() != 1 {
+		return nil, ErrParsingFailed
+	}
+	rate := new(big.Rat).Quo(usdSiacoin, usdEther)
+	rate.Mul(rate, oneEther)
+
+	cutoff := now.Add(-q.window)
+	for len(q.samples) > 0 && (q.samples[0].time.Before(cutoff) || len(q.samples) >= maxSamples) {
+		q.samples = q.samples[1:]
+	}
+
+	if len(q.samples) == 0 || now.Sub(q.samples[len(q.samples)-1].time) >= minSampleInterval {
+		q.samples = append(q.samples, rateSample{time: now, rate: rate})
+	}
+
+	return rate, nil
+}
+
+// volatilityBps returns the standard deviation of the samples relative to
+// their mean. The caller must hold the mutex.
+func (q *QuoteEngine) volatilityBps() int64 {
+	if len(q.samples) < 2 {
+		return 0
+	}
+
+	var sum float64
+	rates := make([]float64, len(q.samples))
+	for i, sample := range q.samples {
+		rates[i], _ = sample.rate.Float64()
+		sum += rates[i]
+	}
+	mean := sum / float64(len(rates))
+	if mean == 0 {
+		return 0
+	}
+
+	var squares float64
+	for _, rate := range rates {
+		squares += (rate - mean) * (rate - mean)
+	}
+	stddev := math.Sqrt(squares / float64(len(rates)-1))
+
+	return int64(math.Round(stddev / mean * bpsPerUnit))
+}
+
+func (q Quote) Fields() output.Fields {
+	fields := output.Fields{
+		"siacoin":        q.Siacoin.String(),
+		"reference":      q.Reference.String(),
+		"available":      q.Available,
+		"volatility_bps": q.VolatilityBps,
+	}
+	if q.Available {
+		fields["ask"] = q.Ask.String()
+		fields["premium_bps"] = q.PremiumBps
+	} else {
+		fields["msg"] = q.Msg
+	}
+	return fields
+}
+
+func (q Quote) String() string {
+	if !q.Available {
+		return fmt.Sprintf("no offer for %s (market value %s): %s",
+			q.Siacoin.HumanString(), ethereum.FormatEther(&q.Reference), q.Msg)
+	}
+
+	return fmt.Sprintf("ask %s for %s (%.2f %% above market value of %s, volatility %.2f %%)",
+		ethereum.FormatEther(&q.Ask), q.Siacoin.HumanString(), float64(q.PremiumBps)/100,
+		ethereum.FormatEther(&q.Reference), float64(q.VolatilityBps)/100)
+}
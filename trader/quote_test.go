@@ -0,0 +1,63 @@
+package trader
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gitlab.com/NebulousLabs/Sia/types"
+)
+
+func TestQuoteEngine(t *testing.T) {
+	rates := fakeRates{"ethereum": big.NewRat(200, 1), "siacoin": big.NewRat(1, 1000)}
+	inner := &countingTrader{}
+	engine := NewQuoteEngine(rates, inner, types.SiacoinPrecision.Mul64(200), 1, time.Hour)
+	now := time.Now()
+
+	// 200 SC at 0.001 USD/SC and 200 USD/ETH are worth 1e15 wei; the trader
+	// asks 1e15 wei plus an anti-spam fee of 1e14 wei
+	quote, err := engine.Quote(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, big.NewInt(1e15), &quote.Reference)
+	assert.True(t, quote.Available)
+	assert.Equal(t, big.NewInt(11e14), &quote.Ask)
+	assert.Equal(t, int64(1000), quote.PremiumBps)
+	assert.Equal(t, int64(0), quote.VolatilityBps)
+
+	// samples less than a minute apart are ignored
+	rates["siacoin"] = big.NewRat(11, 10000)
+	quote, err = engine.Quote(now.Add(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, big.NewInt(11e14), &quote.Reference)
+	assert.Equal(t, int64(0), quote.PremiumBps)
+
+	// rates of 5e12 and 5.5e12 wei/SC have a standard deviation of about
+	// 673 bps, by which the ask is widened
+	quote, err = engine.Quote(now.Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, int64(673), quote.VolatilityBps)
+	assert.Equal(t, big.NewInt(117403e10), &quote.Ask)
+
+	// old samples fall out of the window
+	quote, err = engine.Quote(now.Add(2 * time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, int64(0), quote.VolatilityBps)
+
+	inner.PauseOrderPreparation(now)
+	quote, err = engine.Quote(now.Add(2 * time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.False(t, quote.Available, "expected no ask while paused")
+	assert.Equal(t, msgPaused, quote.Msg)
+	assert.Equal(t, 5, inner.offers, "expected ask to be derived from trader")
+}
@@ -7,8 +7,10 @@ import (
 	"io/ioutil"
 	"math/big"
 	"net/http"
+	"sync"
 	"time"
 
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/patrickmn/go-cache"
 	"gitlab.com/NebulousLabs/Sia/types"
 
@@ -22,12 +24,14 @@ type (
 		Available   bool
 		Ether       big.Int
 		AntiSpamFee big.Int
+		Escrow      *common.Address // if set, the anti-spam fee is escrowed for this server instead of burned
 	}
 
 	FixedPremiumTrader struct {
 		premiumUSD    *big.Rat
 		antiSpamFee   big.Int
-		exchangeRate  *ExchangeRate
+		exchangeRate  RateFetcher
+		mutex         *sync.Mutex // guards paused and pauseDeadline
 		paused        bool
 		pauseDeadline *time.Time
 		ethChain      ethereum.Blockchain
@@ -39,6 +43,8 @@ type (
 			now time.Time) (offer *Offer, err error)
 		PrepareBindingOffer(siacoin types.Currency, minerFee types.Currency,
 			now time.Time) (offer *Offer, deadline *time.Time, err error)
+		CalculateSiacoin(ether big.Int, minerFee types.Currency,
+			now time.Time) (siacoin *types.Currency, err error)
 		PauseOrderPreparation(now time.Time)
 		ResumeOrderPreparation()
 	}
@@ -91,40 +97,96 @@ func NewFixedPremiumTrader(premiumUSD *big.Rat, antiSpamFee big.Int,
 		premiumUSD:   premiumUSD,
 		antiSpamFee:  antiSpamFee,
 		exchangeRate: NewExchangeRate(),
+		mutex:        &sync.Mutex{},
 		paused:       false,
 		ethChain:     ethChain,
 		siaChain:     siaChain,
 	}
 }
 
+// SetRates replaces the source of exchange rates, for example to replay
+// historical rates.
+func (t *FixedPremiumTrader) SetRates(rates RateFetcher) {
+	t.exchangeRate = rates
+}
+
 func (t *FixedPremiumTrader) PrepareNonBindingOffer(siacoin types.Currency, minerFee types.Currency,
 	now time.Time) (*Offer, error) {
-	t.checkPauseDeadline(now)
 	offer, _, err := t.prepareOffer(siacoin, minerFee, now, false)
 	return offer, err
 }
 
 func (t *FixedPremiumTrader) PrepareBindingOffer(siacoin types.Currency, minerFee types.Currency,
 	now time.Time) (*Offer, *time.Time, error) {
-	t.checkPauseDeadline(now)
 	return t.prepareOffer(siacoin, minerFee, now, true)
 }
 
+// CalculateSiacoin inverts the pricing of prepareOffer: it determines how many
+// siacoins could be bought if the given amount of ether is spent. The result
+// is rounded down, so that an offer for the returned amount will not exceed
+// the ether amount (barring changes in exchange rates or gas price).
+func (t *FixedPremiumTrader) CalculateSiacoin(ether big.Int, minerFee types.Currency,
+	now time.Time) (*types.Currency, error) {
+	usdEther, usdSiacoin, err := t.fetchRates()
+	if err != nil {
+		return nil, err
+	}
+
+	gasPrice, err := t.ethChain.SuggestGasPrice()
+	if err != nil {
+		return nil, err
+	}
+	contractCost := new(big.Int).Mul(big.NewInt(gasEstimate), gasPrice)
+
+	remaining := new(big.Int).Sub(&ether, contractCost)
+	if remaining.Sign() != 1 {
+		return &types.ZeroCurrency, nil
+	}
+
+	remainingUSD := ethereum.ApplyRate(remaining, usdEther)
+	withoutPremiumUSD := new(big.Rat).Sub(remainingUSD, t.premiumUSD)
+	if withoutPremiumUSD.Sign() != 1 {
+		return &types.ZeroCurrency, nil
+	}
+
+	hastingsRat := new(big.Rat).Mul(
+		new(big.Rat).Quo(withoutPremiumUSD, usdSiacoin), new(big.Rat).SetInt(types.SiacoinPrecision.Big()))
+	siacoinAndFees := types.NewCurrency(new(big.Int).Quo(hastingsRat.Num(), hastingsRat.Denom()))
+
+	fees := minerFee.Add(minerFee)
+	if siacoinAndFees.Cmp(fees) != 1 {
+		return &types.ZeroCurrency, nil
+	}
+
+	siacoin := siacoinAndFees.Sub(fees)
+	return &siacoin, nil
+}
+
 func (t *FixedPremiumTrader) PauseOrderPreparation(now time.Time) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
 	deadline := now.Add(bindingOfferLifetime)
 	t.paused = true
 	t.pauseDeadline = &deadline
 }
 
 func (t *FixedPremiumTrader) ResumeOrderPreparation() {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
 	t.paused = false
 }
 
-func (t *FixedPremiumTrader) checkPauseDeadline(now time.Time) {
+func (t *FixedPremiumTrader) checkPaused(now time.Time) bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
 	if t.pauseDeadline != nil && now.After(*t.pauseDeadline) {
 		t.paused = false
 		t.pauseDeadline = nil
 	}
+	return t.paused
 }
 
 func (t *FixedPremiumTrader) prepareOffer(siacoin types.Currency, minerFee types.Currency,
@@ -137,7 +199,7 @@ func (t *FixedPremiumTrader) prepareOffer(siacoin types.Currency, minerFee types
 	}
 	deadline := now.Add(bindingOfferLifetime)
 
-	if t.paused {
+	if t.checkPaused(now) {
 		offer.Msg = msgPaused
 		return &offer, &deadline, nil
 	}
@@ -157,12 +219,7 @@ func (t *FixedPremiumTrader) prepareOffer(siacoin types.Currency, minerFee types
 		return &offer, &deadline, nil
 	}
 
-	usdEther, err := t.exchangeRate.Fetch("ethereum")
-	if err != nil {
-		return nil, nil, err
-	}
-
-	usdSiacoin, err := t.exchangeRate.Fetch("siacoin")
+	usdEther, usdSiacoin, err := t.fetchRates()
 	if err != nil {
 		return nil, nil, err
 	}
@@ -189,6 +246,20 @@ func (t *FixedPremiumTrader) prepareOffer(siacoin types.Currency, minerFee types
 	return &offer, &deadline, nil
 }
 
+func (t *FixedPremiumTrader) fetchRates() (usdEther *big.Rat, usdSiacoin *big.Rat, err error) {
+	usdEther, err = t.exchangeRate.Fetch("ethereum")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	usdSiacoin, err = t.exchangeRate.Fetch("siacoin")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return usdEther, usdSiacoin, nil
+}
+
 func (t *FixedPremiumTrader) calculateSiacoinBalance() (*types.Currency, error) {
 	usableOutputs, err := t.siaChain.FetchUsableOutputs()
 	if err != nil {
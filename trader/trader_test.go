@@ -61,6 +61,31 @@ func TestTrader(t *testing.T) {
 		assert.Equal(t, 1, offer.Ether.Sign(), "expected ether amount to be positive")
 	})
 
+	t.Run("CalculateSiacoin", func(t *testing.T) {
+		ether := big.NewInt(1e17)
+		siacoin, err := trader.CalculateSiacoin(*ether, minerFee, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		offer, err := trader.PrepareNonBindingOffer(*siacoin, minerFee, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.True(t, offer.Available, "expected offer for calculated SC amount")
+		assert.True(t, offer.Ether.Cmp(ether) != 1, "expected offer to not exceed ether amount")
+	})
+
+	t.Run("CalculateSiacoinForTinyAmount", func(t *testing.T) {
+		siacoin, err := trader.CalculateSiacoin(*big.NewInt(1), minerFee, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.True(t, siacoin.IsZero(), "expected no SC for 1 wei")
+	})
+
 	t.Run("PausedOrderPreparation", func(t *testing.T) {
 		trader.PauseOrderPreparation(now)
 
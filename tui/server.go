@@ -0,0 +1,144 @@
+package tui
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/javgh/roadie/blockchain/ethereum"
+	"github.com/javgh/roadie/bob"
+)
+
+type (
+	// ServerView gives server operators an overview of all swaps in progress.
+	ServerView struct {
+		writer   io.Writer
+		snapshot func() []bob.Snapshot
+		logs     *LogBuffer
+		now      func() time.Time
+	}
+
+	// LogBuffer keeps the most recent lines written to it, so that log output
+	// can be shown as part of a view instead of scrolling over it.
+	LogBuffer struct {
+		mutex sync.Mutex
+		lines []string
+		size  int
+	}
+)
+
+var (
+	stateExplanations = map[string]string{
+		"stateInitialized":            "waiting for anti-spam payment",
+		"stateMadeNonBindingOffer":    "waiting for anti-spam payment",
+		"stateMadeBindingOffer":       "waiting for buyer to accept",
+		"stateOfferAccepted":          "waiting for buyer's refund signature",
+		"stateFunded":                 "funding broadcast; refund at deadline",
+		"stateProvidedAdaptorDetails": "waiting for deposit; refund at deadline",
+		"stateCompleted":              "deposit claimed",
+		"stateRefunded":               "refund broadcast",
+		"stateAborted":                "aborted",
+	}
+)
+
+func NewServerView(writer io.Writer, snapshot func() []bob.Snapshot, logs *LogBuffer) *ServerView {
+	view := ServerView{
+		writer:   writer,
+		snapshot: snapshot,
+		logs:     logs,
+		now:      time.Now,
+	}
+	return &view
+}
+
+// Run redraws the view every interval until stop is closed.
+func (v *ServerView) Run(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	v.Draw()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			v.Draw()
+		}
+	}
+}
+
+func (v *ServerView) Draw() {
+	var buf bytes.Buffer
+	now := v.now()
+	snapshots := v.snapshot()
+
+	buf.WriteString(clearScreen)
+	fmt.Fprintf(&buf, "%sRoadie server%s   %s   %d swap(s)\n\n", bold, reset, now.Format("15:04:05"), len(snapshots))
+
+	if len(snapshots) == 0 {
+		buf.WriteString("No swaps in progress.\n")
+	} else {
+		table := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(table, "ID\tSC\tETH\tDeadline\tState\n")
+		for _, s := range snapshots {
+			fmt.Fprintf(table, "%s\t%s\t%s\t%s\t%s\n", s.ID.String()[:8], s.Siacoin.HumanString(),
+				ethereum.FormatEther(&s.Ether), formatDeadline(s.Deadline, now), explainState(s.State))
+		}
+		table.Flush()
+	}
+
+	if v.logs != nil {
+		lines := v.logs.Lines()
+		if len(lines) > 0 {
+			buf.WriteString("\nRecent log messages\n")
+			for _, line := range lines {
+				fmt.Fprintf(&buf, "  %s\n", line)
+			}
+		}
+	}
+
+	v.writer.Write(buf.Bytes())
+}
+
+func explainState(state string) string {
+	explanation, ok := stateExplanations[state]
+	if !ok {
+		return state
+	}
+	return explanation
+}
+
+func formatDeadline(deadline time.Time, now time.Time) string {
+	if deadline.Before(now) {
+		return "passed"
+	}
+	return fmt.Sprintf("in %s", formatDuration(deadline.Sub(now)))
+}
+
+func NewLogBuffer(size int) *LogBuffer {
+	return &LogBuffer{size: size}
+}
+
+func (b *LogBuffer) Write(p []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	for _, line := range strings.Split(strings.TrimRight(string(p), "\n"), "\n") {
+		b.lines = append(b.lines, line)
+	}
+	if len(b.lines) > b.size {
+		b.lines = b.lines[len(b.lines)-b.size:]
+	}
+	return len(p), nil
+}
+
+func (b *LogBuffer) Lines() []string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	return append([]string(nil), b.lines...)
+}
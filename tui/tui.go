@@ -0,0 +1,319 @@
+// Package tui contains full-screen terminal views, drawn with plain ANSI escape
+// sequences: one for following a swap as a buyer and one for server operators.
+package tui
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math/big"
+	"sync"
+	"time"
+
+	"gitlab.com/NebulousLabs/Sia/types"
+
+	"github.com/javgh/roadie/alice"
+	"github.com/javgh/roadie/blockchain/ethereum"
+	"github.com/javgh/roadie/trader"
+)
+
+type (
+	step struct {
+		title  string
+		detail string
+	}
+
+	// SwapView shows the progress of a swap as a timeline. It implements
+	// alice.EventSink and redraws the screen on every event. Once the binding
+	// offer has been accepted (and no more prompts are expected), it also
+	// redraws periodically to keep the clock and deadlines current.
+	SwapView struct {
+		mutex         sync.Mutex
+		writer        io.Writer
+		now           func() time.Time
+		started       time.Time
+		step          int
+		completed     bool
+		server        string
+		siacoin       types.Currency
+		offer         *trader.Offer
+		antiSpamID    *big.Int
+		escrowed      bool
+		confirmations int64
+		required      int64
+		height        types.BlockHeight
+		timelock      types.BlockHeight
+		depositTime   time.Time
+		claimTxID     string
+		recent        []string
+		stop          chan struct{}
+	}
+)
+
+const (
+	clearScreen     = "\x1b[H\x1b[2J"
+	bold            = "\x1b[1m"
+	reset           = "\x1b[0m"
+	recentLines     = 5
+	refreshInterval = time.Second
+
+	// matches DEPOSIT_DURATION in the Hub contract
+	depositDuration = 2 * time.Hour
+	siaBlockTime    = 10 * time.Minute
+)
+
+var (
+	steps = []step{
+		{"Collect offers", "Asking all registered servers for non-binding offers."},
+		{"Burn anti-spam fee", "The anti-spam fee is burned, so that the server is willing to make a " +
+			"binding offer. Waiting for Ethereum confirmations."},
+		{"Receive binding offer", "The server locks in the offer for this swap."},
+		{"Agree on refund", "Both parties sign a refund transaction, so that the server can get its " +
+			"siacoins back should you never pay."},
+		{"Sia funding", "The server locks the siacoins into an output controlled by both parties. " +
+			"Waiting for Sia confirmations."},
+		{"Deposit ether", "Your ether is deposited into the Hub contract, claimable by the server " +
+			"only by revealing its adaptor secret. Waiting for Ethereum confirmations."},
+		{"Wait for adaptor secret", "The server claims the deposit, which reveals the adaptor secret " +
+			"on the Ethereum blockchain."},
+		{"Claim siacoins", "The adaptor secret completes your signature for the Sia claim transaction."},
+	}
+
+	// replaces the second step if the server holds the anti-spam fee in escrow
+	escrowStep = step{"Escrow anti-spam fee", "The anti-spam fee is held in escrow by the Hub contract, " +
+		"so that the server is willing to make a binding offer. It is credited back once the swap " +
+		"completes. Waiting for Ethereum confirmations."}
+)
+
+func NewSwapView(writer io.Writer) *SwapView {
+	view := SwapView{
+		writer:  writer,
+		now:     time.Now,
+		started: time.Now(),
+	}
+	return &view
+}
+
+func stepOf(phase alice.Phase) int {
+	switch phase {
+	case alice.PhaseBurningAntiSpamFee, alice.PhaseAntiSpamConfirmations:
+		return 1
+	case alice.PhaseBindingOfferReceived:
+		return 2
+	case alice.PhaseOfferAccepted, alice.PhaseRefundSigned:
+		return 3
+	case alice.PhaseFundingBroadcast, alice.PhaseFundingConfirmations, alice.PhaseFundingDetected:
+		return 4
+	case alice.PhaseDepositing, alice.PhaseDepositMade, alice.PhaseDepositConfirmations,
+		alice.PhaseDepositConfirmed:
+		return 5
+	case alice.PhaseAnnouncingDeposit, alice.PhaseAdaptorRevealed:
+		return 6
+	case alice.PhaseClaimBroadcast, alice.PhaseCompleted:
+		return 7
+	default:
+		return 0
+	}
+}
+
+func (v *SwapView) HandleEvent(event alice.Event) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	if event.Phase == alice.PhaseReclaiming || event.Phase == alice.PhaseReclaimed {
+		return
+	}
+
+	step := stepOf(event.Phase)
+	if step != v.step {
+		v.confirmations = 0
+		v.required = 0
+	}
+	v.step = step
+
+	switch event.Phase {
+	case alice.PhaseBurningAntiSpamFee, alice.PhaseBindingOfferReceived:
+		if event.Offer != nil {
+			v.siacoin = event.Siacoin
+			v.offer = event.Offer
+		}
+		if event.AntiSpamID != nil {
+			v.antiSpamID = event.AntiSpamID
+		}
+		if event.Phase == alice.PhaseBurningAntiSpamFee && event.Offer != nil {
+			v.escrowed = event.Offer.Escrow != nil
+		}
+	case alice.PhaseAntiSpamConfirmations, alice.PhaseFundingConfirmations, alice.PhaseDepositConfirmations:
+		v.confirmations = event.Confirmations
+		v.required = event.Required
+	case alice.PhaseOfferAccepted:
+		v.height = event.Height
+		v.timelock = event.Timelock
+		if v.stop == nil {
+			v.stop = make(chan struct{})
+			go v.refresh(v.stop)
+		}
+	case alice.PhaseDepositMade:
+		v.depositTime = v.now()
+	case alice.PhaseClaimBroadcast:
+		v.claimTxID = event.TxID
+	case alice.PhaseCompleted:
+		v.completed = true
+	}
+
+	if event.Server != "" {
+		v.server = event.Server
+	}
+
+	v.recent = append(v.recent, fmt.Sprintf("%s  %s", v.now().Format("15:04:05"), describe(event)))
+	if len(v.recent) > recentLines {
+		v.recent = v.recent[len(v.recent)-recentLines:]
+	}
+
+	v.draw()
+}
+
+// Close stops the periodic redraw.
+func (v *SwapView) Close() {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	if v.stop != nil {
+		close(v.stop)
+		v.stop = nil
+	}
+}
+
+func (v *SwapView) refresh(stop chan struct{}) {
+	ticker := time.NewTicker(refreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			v.mutex.Lock()
+			v.draw()
+			v.mutex.Unlock()
+		}
+	}
+}
+
+func (v *SwapView) draw() {
+	var buf bytes.Buffer
+	now := v.now()
+
+	buf.WriteString(clearScreen)
+	title := "Roadie swap"
+	if v.offer != nil {
+		title = fmt.Sprintf("Roadie swap: %s for %s", v.siacoin.HumanString(), ethereum.FormatEther(&v.offer.Ether))
+	}
+	fmt.Fprintf(&buf, "%s%s%s   (elapsed %s)\n", bold, title, reset, formatDuration(now.Sub(v.started)))
+	if v.server != "" {
+		fmt.Fprintf(&buf, "Server: %s\n", v.server)
+	}
+	buf.WriteString("\n")
+
+	for i := range steps {
+		s := v.stepAt(i)
+		marker := "[ ]"
+		if i < v.step || v.completed {
+			marker = "[x]"
+		} else if i == v.step {
+			marker = "[>]"
+		}
+
+		line := fmt.Sprintf(" %s %d. %s", marker, i+1, s.title)
+		if i == v.step && v.required != 0 && !v.completed {
+			line += fmt.Sprintf("  (%d/%d confirmations)", v.confirmations, v.required)
+		}
+		if i == v.step && !v.completed {
+			line = bold + line + reset
+		}
+		buf.WriteString(line + "\n")
+	}
+
+	if !v.completed {
+		fmt.Fprintf(&buf, "\nNow: %s\n", v.stepAt(v.step).detail)
+	}
+
+	if v.timelock != 0 || !v.depositTime.IsZero() {
+		buf.WriteString("\nDeadlines\n")
+	}
+	if v.timelock != 0 {
+		remaining := time.Duration(v.timelock-v.height) * siaBlockTime
+		fmt.Fprintf(&buf, "  Sia refund timelock: block %d (~ %s after block %d); from then on the server\n"+
+			"  may take back its siacoins, so the swap needs to complete before.\n",
+			v.timelock, formatDuration(remaining), v.height)
+	}
+	if !v.depositTime.IsZero() {
+		reclaimTime := v.depositTime.Add(depositDuration)
+		fmt.Fprintf(&buf, "  Hub deposit: reclaimable from about %s (in %s) if not claimed.\n",
+			reclaimTime.Format("15:04"), formatDuration(reclaimTime.Sub(now)))
+	}
+
+	fmt.Fprintf(&buf, "\nIf something goes wrong\n  %s\n", v.fallback())
+
+	if len(v.recent) > 0 {
+		buf.WriteString("\nRecent events\n")
+		for _, line := range v.recent {
+			fmt.Fprintf(&buf, "  %s\n", line)
+		}
+	}
+
+	v.writer.Write(buf.Bytes())
+}
+
+func (v *SwapView) stepAt(i int) step {
+	if i == 1 && v.escrowed {
+		return escrowStep
+	}
+	return steps[i]
+}
+
+func (v *SwapView) fallback() string {
+	switch {
+	case v.completed:
+		return fmt.Sprintf("Nothing to do: the swap completed with Sia claim transaction %s.", v.claimTxID)
+	case v.step >= 5 && v.antiSpamID != nil:
+		return fmt.Sprintf("Your ether is (or is being) deposited. Should the swap stall, reclaim the\n"+
+			"  deposit about 2 hours after it was made by running 'roadie reclaim %s'.", v.antiSpamID)
+	case v.step >= 1 && v.escrowed:
+		return "No ether has been deposited yet. Cancelling with CTRL+C is safe; only the\n" +
+			"  escrowed anti-spam fee is lost to the server."
+	case v.step >= 1:
+		return "No ether has been deposited yet. Cancelling with CTRL+C is safe; only the\n" +
+			"  anti-spam fee is lost."
+	default:
+		return "Nothing has been paid yet. Cancelling with CTRL+C is safe."
+	}
+}
+
+func describe(event alice.Event) string {
+	description := event.Phase.String()
+	if event.Phase == alice.PhaseBurningAntiSpamFee && event.Offer != nil && event.Offer.Escrow != nil {
+		description = "escrowing_anti_spam_fee"
+	}
+	switch {
+	case event.Err != nil:
+		description += fmt.Sprintf(" (%s: %s)", event.Server, event.Err)
+	case event.Rejection != "":
+		description += fmt.Sprintf(" (%s: %s)", event.Server, event.Rejection)
+	case event.Required != 0:
+		description += fmt.Sprintf(" (%d/%d)", event.Confirmations, event.Required)
+	case event.TxID != "":
+		description += fmt.Sprintf(" (%s)", event.TxID)
+	case event.Server != "":
+		description += fmt.Sprintf(" (%s)", event.Server)
+	}
+	return description
+}
+
+func formatDuration(d time.Duration) string {
+	if d < 0 {
+		d = 0
+	}
+	d = d.Round(time.Second)
+	return fmt.Sprintf("%02d:%02d:%02d", int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60)
+}
@@ -0,0 +1,99 @@
+package tui
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"gitlab.com/NebulousLabs/Sia/types"
+
+	"github.com/javgh/roadie/alice"
+	"github.com/javgh/roadie/bob"
+	"github.com/javgh/roadie/trader"
+)
+
+func lastFrame(buf *bytes.Buffer) string {
+	frames := bytes.Split(buf.Bytes(), []byte(clearScreen))
+	return string(frames[len(frames)-1])
+}
+
+func TestSwapView(t *testing.T) {
+	var buf bytes.Buffer
+	view := NewSwapView(&buf)
+	defer view.Close()
+
+	offer := trader.Offer{Available: true, Ether: *big.NewInt(1e17), AntiSpamFee: *big.NewInt(1e14)}
+	antiSpamID := big.NewInt(42)
+
+	view.HandleEvent(alice.Event{Phase: alice.PhaseRequestingOffer, Server: "server:9979"})
+	assert.Contains(t, lastFrame(&buf), "[>] 1. Collect offers")
+	assert.Contains(t, lastFrame(&buf), "Nothing has been paid yet")
+
+	view.HandleEvent(alice.Event{Phase: alice.PhaseBurningAntiSpamFee, Siacoin: types.SiacoinPrecision.Mul64(100),
+		Offer: &offer, AntiSpamID: antiSpamID})
+	view.HandleEvent(alice.Event{Phase: alice.PhaseAntiSpamConfirmations, Confirmations: 3, Required: 10})
+	frame := lastFrame(&buf)
+	assert.Contains(t, frame, "[x] 1. Collect offers")
+	assert.Contains(t, frame, "2. Burn anti-spam fee  (3/10 confirmations)")
+	assert.Contains(t, frame, "only the\n  anti-spam fee is lost")
+
+	view.HandleEvent(alice.Event{Phase: alice.PhaseOfferAccepted, Height: 1000, Timelock: 1024})
+	assert.Contains(t, lastFrame(&buf), "Sia refund timelock: block 1024")
+
+	view.HandleEvent(alice.Event{Phase: alice.PhaseDepositMade, AntiSpamID: antiSpamID})
+	frame = lastFrame(&buf)
+	assert.Contains(t, frame, "Hub deposit: reclaimable from about")
+	assert.Contains(t, frame, "roadie reclaim 42")
+
+	view.HandleEvent(alice.Event{Phase: alice.PhaseCompleted})
+	assert.Contains(t, lastFrame(&buf), "[x] 8. Claim siacoins")
+}
+
+func TestSwapViewEscrow(t *testing.T) {
+	var buf bytes.Buffer
+	view := NewSwapView(&buf)
+	defer view.Close()
+
+	escrow := common.HexToAddress("0x44f1911Df3E915b21F385892B75E36002A859dF7")
+	offer := trader.Offer{Available: true, Ether: *big.NewInt(1e17), AntiSpamFee: *big.NewInt(1e14),
+		Escrow: &escrow}
+
+	view.HandleEvent(alice.Event{Phase: alice.PhaseBurningAntiSpamFee, Siacoin: types.SiacoinPrecision.Mul64(100),
+		Offer: &offer, AntiSpamID: big.NewInt(42)})
+	frame := lastFrame(&buf)
+	assert.Contains(t, frame, "2. Escrow anti-spam fee")
+	assert.Contains(t, frame, "held in escrow by the Hub contract")
+	assert.Contains(t, frame, "escrowed anti-spam fee is lost to the server")
+	assert.NotContains(t, frame, "burn")
+}
+
+func TestServerView(t *testing.T) {
+	now := time.Now()
+	snapshots := []bob.Snapshot{
+		{ID: uuid.Must(uuid.NewRandom()), State: "stateFunded", Deadline: now.Add(time.Hour)},
+		{ID: uuid.Must(uuid.NewRandom()), State: "stateAborted", Deadline: now.Add(-time.Hour)},
+	}
+
+	logs := NewLogBuffer(2)
+	for i := 0; i < 3; i++ {
+		fmt.Fprintf(logs, "message %d\n", i)
+	}
+	assert.Equal(t, []string{"message 1", "message 2"}, logs.Lines(), "should only keep recent lines")
+
+	var buf bytes.Buffer
+	view := NewServerView(&buf, func() []bob.Snapshot { return snapshots }, logs)
+	view.now = func() time.Time { return now }
+	view.Draw()
+
+	frame := lastFrame(&buf)
+	assert.Contains(t, frame, "2 swap(s)")
+	assert.Contains(t, frame, "in 01:00:00")
+	assert.Contains(t, frame, "funding broadcast; refund at deadline")
+	assert.Contains(t, frame, "passed")
+	assert.Contains(t, frame, "message 2")
+}
@@ -0,0 +1,182 @@
+// Package webhook delivers events as JSON via HTTP POST, so that operators
+// can be alerted about swaps without watching the log.
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jpillora/backoff"
+
+	"github.com/javgh/roadie/alice"
+	"github.com/javgh/roadie/output"
+)
+
+type (
+	// Notifier posts events to a list of URLs. Events are delivered in order
+	// by a background goroutine, so that Notify never blocks the caller. A
+	// failed delivery is retried with exponential backoff. If a secret is
+	// set, each request carries the time it was sent in the
+	// X-Roadie-Timestamp header and an HMAC-SHA256 signature of timestamp and
+	// body in the X-Roadie-Signature header, so that receivers can reject
+	// replayed requests. A nil Notifier discards all events.
+	Notifier struct {
+		urls        []string
+		secret      []byte
+		client      *http.Client
+		backoff     backoff.Backoff
+		maxAttempts int
+		queue       chan []byte
+		wg          sync.WaitGroup
+	}
+)
+
+const (
+	SignatureHeader = "X-Roadie-Signature"
+	TimestampHeader = "X-Roadie-Timestamp"
+
+	queueSize      = 100
+	maxAttempts    = 5
+	backoffMin     = 2 * time.Second
+	backoffMax     = 2 * time.Minute
+	backoffFactor  = 2
+	requestTimeout = 20 * time.Second
+)
+
+func New(urls []string, secret string) *Notifier {
+	n := Notifier{
+		urls:   urls,
+		secret: []byte(secret),
+		client: &http.Client{Timeout: requestTimeout},
+		backoff: backoff.Backoff{
+			Min:    backoffMin,
+			Max:    backoffMax,
+			Factor: backoffFactor,
+		},
+		maxAttempts: maxAttempts,
+		queue:       make(chan []byte, queueSize),
+	}
+
+	n.wg.Add(1)
+	go n.deliver()
+	return &n
+}
+
+// Notify queues an event. The payload has the same format as the JSON output
+// of the command line tool: the fields plus "event" and "time".
+func (n *Notifier) Notify(event string, fields output.Fields) {
+	if n == nil {
+		return
+	}
+
+	payload := output.Fields{}
+	for k, v := range fields {
+		payload[k] = v
+	}
+	payload["event"] = event
+	payload["time"] = time.Now().UTC().Format(time.RFC3339)
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		log.Printf("Unable to encode webhook event %s: %s\n", event, err)
+		return
+	}
+
+	select {
+	case n.queue <- data:
+	default:
+		log.Printf("Webhook queue is full - dropping event %s\n", event)
+	}
+}
+
+// HandleEvent makes the notifier usable as an alice.EventSink.
+func (n *Notifier) HandleEvent(event alice.Event) {
+	n.Notify(event.Phase.EventName(), event.Fields())
+}
+
+// Close waits until all queued events have been delivered or given up on.
+func (n *Notifier) Close() {
+	if n == nil {
+		return
+	}
+
+	close(n.queue)
+	n.wg.Wait()
+}
+
+// Sign returns the signature as sent in the X-Roadie-Signature header. It
+// covers the timestamp (Unix time in seconds, as sent in the
+// X-Roadie-Timestamp header), a dot and the body.
+func Sign(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func (n *Notifier) deliver() {
+	defer n.wg.Done()
+
+	for data := range n.queue {
+		for _, url := range n.urls {
+			n.post(url, data)
+		}
+	}
+}
+
+func (n *Notifier) post(url string, data []byte) {
+	b := n.backoff
+	b.Reset()
+
+	for attempt := 1; ; attempt++ {
+		err := n.attempt(url, data)
+		if err == nil {
+			return
+		}
+
+		if attempt >= n.maxAttempts {
+			log.Printf("Giving up on webhook %s: %s\n", url, err)
+			return
+		}
+
+		duration := b.Duration()
+		log.Printf("Webhook %s failed: %s - retrying in %s\n", url, err, duration)
+		time.Sleep(duration)
+	}
+}
+
+func (n *Notifier) attempt(url string, data []byte) error {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if len(n.secret) > 0 {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set(TimestampHeader, timestamp)
+		req.Header.Set(SignatureHeader, Sign(n.secret, timestamp, data))
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
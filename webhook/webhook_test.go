@@ -0,0 +1,78 @@
+package webhook
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/javgh/roadie/output"
+)
+
+func TestNotifier(t *testing.T) {
+	var (
+		mutex    sync.Mutex
+		requests int
+		payloads []map[string]interface{}
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		requests++
+		if requests == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		timestamp := r.Header.Get(TimestampHeader)
+		assert.Equal(t, Sign([]byte("secret"), timestamp, body), r.Header.Get(SignatureHeader))
+		assert.NotEqual(t, Sign([]byte("secret"), "0", body), r.Header.Get(SignatureHeader),
+			"expected signature to cover timestamp")
+
+		sent, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		assert.WithinDuration(t, time.Now(), time.Unix(sent, 0), time.Minute)
+
+		var payload map[string]interface{}
+		err = json.Unmarshal(body, &payload)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		payloads = append(payloads, payload)
+	}))
+	defer server.Close()
+
+	n := New([]string{server.URL}, "secret")
+	n.backoff.Min = time.Millisecond
+	n.backoff.Max = time.Millisecond
+
+	n.Notify("swap_completed", output.Fields{"swap_id": "abc"})
+	n.Notify("check_failed", output.Fields{"error": "oops"})
+	n.Close()
+
+	assert.Equal(t, 3, requests, "expected first request to be retried")
+	assert.Len(t, payloads, 2)
+	assert.Equal(t, "swap_completed", payloads[0]["event"])
+	assert.Equal(t, "abc", payloads[0]["swap_id"])
+	assert.Equal(t, "check_failed", payloads[1]["event"])
+
+	var nilNotifier *Notifier
+	nilNotifier.Notify("ignored", nil)
+	nilNotifier.Close()
+}